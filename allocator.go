@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"io"
+	"math/bits"
+	"sync"
+)
+
+// An Allocator provides the buffer of a Reader, for buffers that come from
+// arenas or pools under the caller's control. It must be safe for
+// concurrent use if it is shared by Readers in different goroutines.
+type Allocator interface {
+	// Alloc returns a buffer with a capacity of at least n bytes.
+	Alloc(n int) []byte
+	// Free takes back a buffer returned by Alloc that the Reader no longer
+	// uses.
+	Free(b []byte)
+}
+
+// NewReaderAllocator returns a new Reader whose buffer, initially of
+// bufSize bytes, is allocated and grown with a. Call Release to give the
+// buffer back when the Reader is no longer used. Reset keeps the buffer
+// and the allocator.
+func NewReaderAllocator(r io.Reader, a Allocator, bufSize int) *Reader {
+	xr := NewReaderBuf(r, nil)
+	xr.reader.alloc = a
+	xr.reader.data = a.Alloc(bufSize)[:0]
+	return xr
+}
+
+// Release frees the buffer of a Reader created by NewReaderAllocator. The
+// Reader must not be used afterwards, except after a call to Reset.
+func (r *Reader) Release() {
+	if r.reader.alloc != nil && r.reader.data != nil {
+		r.reader.alloc.Free(r.reader.data[:0])
+	}
+	r.reader.data = nil
+	r.reader.offset = 0
+}
+
+// PoolAllocator is an Allocator keeping freed buffers in sync.Pools by
+// power-of-two size class. The zero value is ready to use.
+type PoolAllocator struct {
+	pools [bits.UintSize]sync.Pool
+}
+
+// Alloc returns a buffer from the pool of the size class of n, or a new
+// one.
+func (p *PoolAllocator) Alloc(n int) []byte {
+	c := bits.Len(uint(max(n, 1) - 1))
+	if b, ok := p.pools[c].Get().(*[]byte); ok {
+		return *b
+	}
+	return make([]byte, 0, 1<<c)
+}
+
+// Free puts b in the pool of its size class. Buffers whose capacity is not
+// a power of two are dropped.
+func (p *PoolAllocator) Free(b []byte) {
+	n := cap(b)
+	if n == 0 || n&(n-1) != 0 {
+		return
+	}
+	b = b[:0]
+	p.pools[bits.Len(uint(n))-1].Put(&b)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/flate"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+)
+
+// WalkZip calls fn for each file in z accepted by match, with a Reader
+// positioned at the start of the file. A nil match accepts names ending in
+// ".xml" or ".rels", which covers OOXML and ODF packages.
+//
+// A single Reader and its buffer are reused for all files; it is Reset
+// before each call, so options must be set again inside fn, and the Reader
+// must not be retained after fn returns. Deflated entries are decompressed
+// from large reads of the archive rather than the small reads zip.File.Open
+// issues.
+func WalkZip(z *zip.Reader, match func(name string) bool, fn func(name string, r *Reader) error) error {
+	if match == nil {
+		match = isXMLName
+	}
+	xr := NewReader(nil)
+	for _, f := range z.File {
+		if f.Mode().IsDir() || !match(f.Name) {
+			continue
+		}
+		rc, err := openZipFile(f)
+		if err != nil {
+			return err
+		}
+		xr.Reset(rc)
+		err = fn(f.Name, xr)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkTar is like WalkZip for the tar stream r. Only regular files are
+// passed to fn.
+func WalkTar(r io.Reader, match func(name string) bool, fn func(name string, r *Reader) error) error {
+	if match == nil {
+		match = isXMLName
+	}
+	tr := tar.NewReader(bufio.NewReaderSize(r, compressedBufSize))
+	xr := NewReader(nil)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg || !match(h.Name) {
+			continue
+		}
+		xr.Reset(tr)
+		if err := fn(h.Name, xr); err != nil {
+			return err
+		}
+	}
+}
+
+func isXMLName(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".xml", ".rels":
+		return true
+	}
+	return false
+}
+
+func openZipFile(f *zip.File) (io.ReadCloser, error) {
+	if f.Method != zip.Store && f.Method != zip.Deflate {
+		return f.Open()
+	}
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	var rc io.ReadCloser = io.NopCloser(bufio.NewReaderSize(raw, compressedBufSize))
+	if f.Method == zip.Deflate {
+		rc = flate.NewReader(bufio.NewReaderSize(raw, compressedBufSize))
+	}
+	return &zipChecksumReader{rc: rc, hash: crc32.NewIEEE(), f: f}, nil
+}
+
+// zipChecksumReader verifies the size and CRC-32 of a zip entry, as
+// zip.File.Open does.
+type zipChecksumReader struct {
+	rc   io.ReadCloser
+	hash hash.Hash32
+	n    uint64
+	f    *zip.File
+	err  error
+}
+
+func (z *zipChecksumReader) Read(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	n, err := z.rc.Read(p)
+	z.hash.Write(p[:n])
+	z.n += uint64(n)
+	if z.n > z.f.UncompressedSize64 {
+		err = zip.ErrFormat
+	} else if errors.Is(err, io.EOF) {
+		if z.n != z.f.UncompressedSize64 {
+			err = io.ErrUnexpectedEOF
+		} else if z.f.CRC32 != 0 && z.hash.Sum32() != z.f.CRC32 {
+			err = zip.ErrChecksum
+		}
+	}
+	z.err = err
+	return n, err
+}
+
+func (z *zipChecksumReader) Close() error {
+	return z.rc.Close()
+}
@@ -0,0 +1,113 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+// AttrSpan locates an attribute in the bytes of a start tag: the name is
+// b[NameStart:NameEnd] and the value, without its quotes,
+// b[ValueStart:ValueEnd].
+type AttrSpan struct {
+	NameStart, NameEnd   int
+	ValueStart, ValueEnd int
+}
+
+// AttrSpans returns the attributes of the last event returned by Event,
+// located with IndexAttrs, when it is a start tag or the end event of a
+// self-closing tag. It returns nil for other events. The spans are valid
+// until the next call to Event.
+func (r *Reader) AttrSpans() []AttrSpan {
+	if !r.IndexAttrs {
+		return nil
+	}
+	return r.attrs
+}
+
+// indexAttrs implements IndexAttrs for ev, from the quotes found by the
+// scanner unless an option rewrote the tag.
+func (r *Reader) indexAttrs(ev Event) []AttrSpan {
+	if ev.Type() != EventStart && !(ev.Type() == EventEnd && isSelfClosing(ev.Bytes)) {
+		return nil
+	}
+	b := ev.Bytes
+	if r.raw != nil || !quotesMatch(b, r.quotes) {
+		r.quotes = appendQuotes(r.quotes[:0], b)
+	}
+	spans := r.attrSpans[:0]
+	for i := 0; i+1 < len(r.quotes); i += 2 {
+		open, close := r.quotes[i], r.quotes[i+1]
+		j := open - 1
+		for j > 0 && whitespace[b[j]] {
+			j--
+		}
+		if j <= 0 || b[j] != '=' {
+			continue
+		}
+		j--
+		for j > 0 && whitespace[b[j]] {
+			j--
+		}
+		end := j + 1
+		for j > 0 && !whitespace[b[j]] {
+			j--
+		}
+		spans = append(spans, AttrSpan{j + 1, end, open + 1, close})
+	}
+	r.attrSpans = spans
+	return spans
+}
+
+// quotesMatch reports whether quotes holds pairs of matching quotes of b.
+func quotesMatch(b []byte, quotes []int) bool {
+	if len(quotes)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(quotes); i += 2 {
+		open, close := quotes[i], quotes[i+1]
+		if close >= len(b) || b[open] != '"' && b[open] != '\'' || b[close] != b[open] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendQuotes appends the offsets of the quotes delimiting the attribute
+// values of the tag b to dst.
+func appendQuotes(dst []int, b []byte) []int {
+	for i := 0; i < len(b); i++ {
+		if c := b[i]; c == '"' || c == '\'' {
+			j := i + 1
+			for j < len(b) && b[j] != c {
+				j++
+			}
+			if j == len(b) {
+				break
+			}
+			dst = append(dst, i, j)
+			i = j
+		}
+	}
+	return dst
+}
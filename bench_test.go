@@ -85,3 +85,29 @@ func countAfrica(b *testing.B) error {
 	}
 	return nil
 }
+
+func BenchmarkNameSet_Index(b *testing.B) {
+	names := gosax.NewNameSet("location", "item", "name", "description", "category", "person", "open_auction", "closed_auction")
+	name := []byte("closed_auction")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if names.Index(name) != 7 {
+			b.Fatal("unexpected index")
+		}
+	}
+}
+
+func BenchmarkUnescape(b *testing.B) {
+	text := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 200)
+	text = append(text, "Fish &amp; chips &#x263A;\r\n"...)
+	text = append(text, bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 200)...)
+	buf := make([]byte, len(text))
+	b.SetBytes(int64(len(text)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		copy(buf, text)
+		if _, err := gosax.Unescape(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// DecodeBase64 decodes the base64 character data of the element started by
+// the last EventStart and writes the result to w. If enc is nil,
+// base64.StdEncoding is used. Whitespace in the payload is ignored.
+//
+// Text is decoded straight from the Reader's buffer as it is filled,
+// so the payload never has to fit in memory.
+// On success, the Reader is positioned after the element's end tag.
+func DecodeBase64(r *Reader, w io.Writer, enc *base64.Encoding) (int64, error) {
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	d := binaryDecoder{
+		w:       w,
+		quantum: 4,
+		decode:  enc.Decode,
+		maxLen:  enc.DecodedLen,
+	}
+	return d.run(r)
+}
+
+// DecodeHex decodes the hexadecimal character data of the element started
+// by the last EventStart and writes the result to w. Whitespace in the
+// payload is ignored. It streams like DecodeBase64.
+func DecodeHex(r *Reader, w io.Writer) (int64, error) {
+	d := binaryDecoder{
+		w:       w,
+		quantum: 2,
+		decode:  hex.Decode,
+		maxLen:  hex.DecodedLen,
+	}
+	return d.run(r)
+}
+
+type binaryDecoder struct {
+	w       io.Writer
+	quantum int
+	decode  func(dst, src []byte) (int, error)
+	maxLen  func(n int) int
+
+	in    [4096]byte
+	n     int
+	out   []byte
+	total int64
+}
+
+func (d *binaryDecoder) run(r *Reader) (int64, error) {
+	if err := streamText(r, d.feed); err != nil {
+		return d.total, err
+	}
+	return d.total, d.flush(d.n)
+}
+
+func (d *binaryDecoder) feed(b []byte) error {
+	for _, c := range b {
+		if whitespace[c] {
+			continue
+		}
+		d.in[d.n] = c
+		d.n++
+		if d.n == len(d.in) {
+			if err := d.flush(d.n - d.n%d.quantum); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *binaryDecoder) flush(n int) error {
+	if n == 0 {
+		return nil
+	}
+	if d.out == nil {
+		d.out = make([]byte, d.maxLen(len(d.in)))
+	}
+	m, err := d.decode(d.out, d.in[:n])
+	if err != nil {
+		return err
+	}
+	d.n = copy(d.in[:], d.in[n:d.n])
+	written, err := d.w.Write(d.out[:m])
+	d.total += int64(written)
+	return err
+}
+
+// streamText passes the unescaped character data of the element started by
+// the last EventStart to fn, reading text directly from the buffer window.
+// Comments and processing instructions are skipped; CDATA sections are
+// passed as is. Child elements are an error.
+func streamText(r *Reader, fn func([]byte) error) error {
+	if r.last.Type() != EventStart {
+		return errors.New("gosax: text streaming requires EventStart")
+	}
+	if isSelfClosing(r.last.Bytes) {
+		if r.EmitSelfClosingTag {
+			_, err := r.Event()
+			return err
+		}
+		return nil
+	}
+	rr := &r.reader
+	for {
+		for {
+			w := rr.window()
+			i := bytes.IndexByte(w, '<')
+			chunk := w
+			if i >= 0 {
+				chunk = w[:i]
+			} else if j := bytes.LastIndexByte(chunk, '&'); j >= 0 && bytes.IndexByte(chunk[j:], ';') < 0 {
+				// keep a partial entity reference for the next round.
+				chunk = chunk[:j]
+			}
+			n := len(chunk)
+			if len(chunk) > 0 {
+				b, err := Unescape(chunk)
+				if err != nil {
+					return err
+				}
+				if err := fn(b); err != nil {
+					return err
+				}
+			}
+			rr.release(n)
+			if i >= 0 {
+				break
+			}
+			if rr.extend() == 0 {
+				if rr.err == io.EOF || rr.err == nil {
+					return io.ErrUnexpectedEOF
+				}
+				return rr.err
+			}
+		}
+		ev, err := r.Event()
+		if err != nil {
+			return err
+		}
+		switch ev.Type() {
+		case EventEnd:
+			return nil
+		case EventCData:
+			if err := fn(trim(ev.Bytes, "<![CDATA[", "]]>")); err != nil {
+				return err
+			}
+		case EventStart, EventEOF:
+			return errors.New("gosax: unexpected element in text content")
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"io"
+	"unsafe"
+)
+
+// MinMemoryBudget is the smallest budget used by NewReaderBudget.
+const MinMemoryBudget = 16 << 10
+
+// NewReaderBudget returns a Reader that reads any document from r within
+// budget bytes of memory, for deployments that need a hard bound rather
+// than defaults. Half of the budget is the buffer, allocated at once and
+// never grown, so an event that does not fit fails with a *LimitError for
+// "MaxTokenSize". The other half bounds the state kept across events: the
+// stacks of open elements and namespace bindings, the parsed DTD, and the
+// buffers of options such as UnescapeText or OmitNamespaceDecls. It is
+// checked after each event, and exceeding it fails with a *LimitError for
+// "MemoryBudget". The document type declaration and entity expansions,
+// parsed before they can be measured, are limited to an eighth and a
+// quarter of that half through MaxDocTypeSize and MaxEntityExpansion.
+//
+// The budget is kept across Reset. Limits set on the Reader apply too.
+func NewReaderBudget(r io.Reader, budget int) *Reader {
+	budget = max(budget, MinMemoryBudget)
+	xr := NewReaderSize(r, budget/2)
+	xr.budget = budget
+	return xr
+}
+
+// budgetLimits sets the limits of r derived from its budget.
+func (r *Reader) budgetLimits() {
+	maxToken := cap(r.reader.data) - minReadSize
+	if r.limits.MaxTokenSize < 0 || r.limits.MaxTokenSize > maxToken {
+		r.limits.MaxTokenSize = maxToken
+	}
+	// The parsed internal subset and the expansions of entities are only
+	// measured once allocated, so they are bounded beforehand.
+	state := r.budget - cap(r.reader.data)
+	if r.limits.MaxDocTypeSize < 0 || r.limits.MaxDocTypeSize > state/8 {
+		r.limits.MaxDocTypeSize = state / 8
+	}
+	if r.limits.MaxEntityExpansion < 0 || r.limits.MaxEntityExpansion > state/4 {
+		r.limits.MaxEntityExpansion = state / 4
+	}
+}
+
+// checkBudget checks the size of the state of r against its budget.
+func (r *Reader) checkBudget() error {
+	if cap(r.reader.data)+r.stateSize() > r.budget {
+		return r.limitError("MemoryBudget", r.budget)
+	}
+	return nil
+}
+
+// stateSize returns the number of bytes held by r besides its buffer.
+func (r *Reader) stateSize() int {
+	const intSize = int(unsafe.Sizeof(0))
+	n := cap(r.scratch) + cap(r.normalized) + cap(r.omitted) + cap(r.expanded) + cap(r.expansion) + cap(r.lenientBuf) + cap(r.lenientEnds)
+	n += cap(r.open.names) + cap(r.open.ends)*intSize
+	n += cap(r.ns.marks)*intSize + cap(r.ns.bindings)*int(unsafe.Sizeof(nsBinding{})) + r.ns.size
+	n += cap(r.quotes)*intSize + cap(r.attrSpans)*int(unsafe.Sizeof(AttrSpan{}))
+	n += cap(r.entities.open) * int(unsafe.Sizeof(""))
+	return n + r.dtdSize
+}
+
+// dtdSize returns an estimate of the number of bytes held by d.
+func dtdSize(d *DTD) int {
+	// entry approximates the overhead of a map entry with a string key.
+	const entry = 2*int(unsafe.Sizeof("")) + 16
+	n := len(d.Name)
+	for name, defs := range d.Attlists {
+		n += entry + len(name) + cap(defs)*int(unsafe.Sizeof(AttDef{}))
+		for _, a := range defs {
+			n += len(a.Name) + len(a.Type) + len(a.Default) + len(a.Value)
+		}
+	}
+	for name, v := range d.Entities {
+		n += entry + len(name) + len(v)
+	}
+	for name, v := range d.ExternalEntities {
+		n += entry + len(name) + len(v)
+	}
+	return n
+}
@@ -0,0 +1,446 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"hash"
+	"io"
+	"slices"
+)
+
+// Mode selects an XML canonicalization algorithm.
+type Mode uint8
+
+const (
+	// C14N10 is Canonical XML 1.0 without comments.
+	C14N10 Mode = iota
+	// C14N10WithComments is Canonical XML 1.0 with comments.
+	C14N10WithComments
+	// C14N11 is Canonical XML 1.1 without comments.
+	C14N11
+	// C14N11WithComments is Canonical XML 1.1 with comments.
+	C14N11WithComments
+	// ExcC14N10 is Exclusive XML Canonicalization 1.0 without comments.
+	ExcC14N10
+	// ExcC14N10WithComments is Exclusive XML Canonicalization 1.0 with comments.
+	ExcC14N10WithComments
+)
+
+var modeURIs = [...]string{
+	C14N10:                "http://www.w3.org/TR/2001/REC-xml-c14n-20010315",
+	C14N10WithComments:    "http://www.w3.org/TR/2001/REC-xml-c14n-20010315#WithComments",
+	C14N11:                "http://www.w3.org/2006/12/xml-c14n11",
+	C14N11WithComments:    "http://www.w3.org/2006/12/xml-c14n11#WithComments",
+	ExcC14N10:             "http://www.w3.org/2001/10/xml-exc-c14n#",
+	ExcC14N10WithComments: "http://www.w3.org/2001/10/xml-exc-c14n#WithComments",
+}
+
+// URI returns the algorithm identifier of m.
+func (m Mode) URI() string {
+	if int(m) < len(modeURIs) {
+		return modeURIs[m]
+	}
+	return ""
+}
+
+// ModeFromURI returns the Mode identified by uri.
+func ModeFromURI(uri string) (Mode, bool) {
+	for m, u := range modeURIs {
+		if u == uri {
+			return Mode(m), true
+		}
+	}
+	return 0, false
+}
+
+func (m Mode) exclusive() bool { return m >= ExcC14N10 }
+
+func (m Mode) comments() bool { return m%2 == 1 }
+
+// HashSubtree canonicalizes the element started by the last EventStart and
+// writes the result to h, consuming the Reader through the matching end tag.
+//
+// Namespace declarations of ancestors outside the subtree are not visible,
+// so the digest is that of the subtree as a standalone document.
+func HashSubtree(r *Reader, h hash.Hash, mode Mode) error {
+	if r.last.Type() != EventStart {
+		return errors.New("gosax: HashSubtree called without EventStart")
+	}
+	c := NewCanonicalizer(h, mode)
+	if err := c.WriteEvent(r.last); err != nil {
+		return err
+	}
+	if err := r.walkSubtree(c.WriteEvent); err != nil {
+		return err
+	}
+	return c.Flush()
+}
+
+// walkSubtree calls fn for each event following the last EventStart,
+// up to and including its matching end event.
+func (r *Reader) walkSubtree(fn func(Event) error) error {
+	if r.last.Type() == EventStart && isSelfClosing(r.last.Bytes) {
+		if !r.EmitSelfClosingTag {
+			return nil
+		}
+		ev, err := r.Event()
+		if err != nil {
+			return err
+		}
+		return fn(ev)
+	}
+	depth := 0
+	for {
+		ev, err := r.Event()
+		if err != nil {
+			return err
+		}
+		switch ev.Type() {
+		case EventStart:
+			if !isSelfClosing(ev.Bytes) {
+				depth++
+			}
+		case EventEnd:
+			if !isSelfClosing(ev.Bytes) {
+				if depth == 0 {
+					return fn(ev)
+				}
+				depth--
+			}
+		case EventEOF:
+			return io.ErrUnexpectedEOF
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// Canonicalizer writes the canonical form of a stream of events.
+//
+// Events are expected to form a document or a subtree. Comments are kept
+// only in the WithComments modes; the XML declaration and DOCTYPE are
+// dropped. Attributes declared as defaults in a DTD are not added.
+type Canonicalizer struct {
+	// InclusiveNamespaces lists prefixes that are handled with the inclusive
+	// rules in the exclusive modes. "#default" denotes the default namespace.
+	InclusiveNamespaces []string
+
+	w        io.Writer
+	mode     Mode
+	in       nsScope
+	out      nsScope
+	depth    int
+	seenRoot bool
+
+	buf     []byte
+	scratch []byte
+	attrs   []c14nAttr
+	ns      []nsBinding
+	err     error
+}
+
+type c14nAttr struct {
+	qname []byte
+	uri   string
+	local []byte
+	value [2]int
+}
+
+// NewCanonicalizer returns a Canonicalizer writing to w.
+func NewCanonicalizer(w io.Writer, mode Mode) *Canonicalizer {
+	return &Canonicalizer{
+		w:    w,
+		mode: mode,
+	}
+}
+
+// DeclareNamespace adds a namespace binding in scope for the first element,
+// such as one declared by an ancestor of a canonicalized subtree.
+// It must be called before the first event is written.
+func (c *Canonicalizer) DeclareNamespace(prefix, uri string) {
+	c.in.declare(prefix, uri)
+}
+
+// WriteEvent writes the canonical form of e.
+func (c *Canonicalizer) WriteEvent(e Event) error {
+	if c.err != nil {
+		return c.err
+	}
+	switch e.Type() {
+	case EventStart:
+		c.err = c.start(e.Bytes)
+		if c.err == nil && isSelfClosing(e.Bytes) {
+			name, _ := Name(e.Bytes)
+			c.end(name)
+		}
+	case EventEnd:
+		if !isSelfClosing(e.Bytes) {
+			name, _ := Name(e.Bytes)
+			c.end(name)
+		}
+	case EventText, EventEntityRef:
+		if c.depth > 0 && e.Unescaped() {
+			c.buf = appendC14NText(c.buf, e.Bytes)
+		} else if c.depth > 0 {
+			var b []byte
+			c.scratch = append(c.scratch[:0], e.Bytes...)
+			b, c.err = Unescape(c.scratch)
+			c.buf = appendC14NText(c.buf, b)
+		}
+	case EventCData:
+		if c.depth > 0 {
+			c.buf = appendC14NText(c.buf, trim(e.Bytes, "<![CDATA[", "]]>"))
+		}
+	case EventComment:
+		if c.mode.comments() {
+			c.misc(e.Bytes)
+		}
+	case EventProcessingInstruction, EventXMLDecl:
+		body := e.Bytes[2 : len(e.Bytes)-2]
+		target, inst := body, []byte(nil)
+		if i := bytes.IndexAny(body, " \t\r\n"); i >= 0 {
+			target, inst = body[:i], bytes.TrimLeft(body[i:], " \t\r\n")
+		}
+		if string(target) == "xml" {
+			break
+		}
+		b := append([]byte("<?"), target...)
+		if len(inst) > 0 {
+			b = append(b, ' ')
+			b = append(b, inst...)
+		}
+		b = append(b, "?>"...)
+		c.misc(b)
+	}
+	if c.err == nil && len(c.buf) >= 4096 {
+		c.err = c.Flush()
+	}
+	return c.err
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (c *Canonicalizer) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	if len(c.buf) > 0 {
+		_, c.err = c.w.Write(c.buf)
+		c.buf = c.buf[:0]
+	}
+	return c.err
+}
+
+// misc writes a comment or processing instruction. Outside the document
+// element they are separated from it by a line feed.
+func (c *Canonicalizer) misc(b []byte) {
+	if c.depth == 0 && c.seenRoot {
+		c.buf = append(c.buf, '\n')
+	}
+	c.buf = append(c.buf, b...)
+	if c.depth == 0 && !c.seenRoot {
+		c.buf = append(c.buf, '\n')
+	}
+}
+
+func (c *Canonicalizer) start(b []byte) error {
+	name, rest := Name(b)
+	c.in.push()
+	c.attrs = c.attrs[:0]
+	c.scratch = c.scratch[:0]
+	for len(rest) > 0 {
+		attr, r, err := NextAttribute(rest)
+		if err != nil {
+			return err
+		}
+		rest = r
+		if len(attr.Key) == 0 {
+			break
+		}
+		begin := len(c.scratch)
+		c.scratch = append(c.scratch, attr.Value[1:len(attr.Value)-1]...)
+		v, err := UnescapeAttr(c.scratch[begin:])
+		if err != nil {
+			return err
+		}
+		c.scratch = c.scratch[:begin+len(v)]
+		if prefix, ok := nsDecl(attr.Key); ok {
+			c.in.declare(prefix, string(v))
+			continue
+		}
+		c.attrs = append(c.attrs, c14nAttr{qname: attr.Key, value: [2]int{begin, len(c.scratch)}})
+	}
+	for i := range c.attrs {
+		a := &c.attrs[i]
+		prefix, local := splitQName(a.qname)
+		a.local = local
+		if prefix != nil {
+			a.uri, _ = c.in.lookup(string(prefix))
+		}
+	}
+
+	c.ns = c.ns[:0]
+	if c.mode.exclusive() {
+		prefix, _ := splitQName(name)
+		c.render(string(prefix))
+		for _, a := range c.attrs {
+			if p, _ := splitQName(a.qname); p != nil {
+				c.render(string(p))
+			}
+		}
+		for _, p := range c.InclusiveNamespaces {
+			if p == "#default" {
+				p = ""
+			}
+			if _, ok := c.in.lookup(p); ok {
+				c.render(p)
+			}
+		}
+	} else {
+		c.in.visible(func(b nsBinding) {
+			c.render(b.prefix)
+		})
+	}
+	slices.SortFunc(c.ns, func(a, b nsBinding) int {
+		return cmp.Compare(a.prefix, b.prefix)
+	})
+	slices.SortFunc(c.attrs, func(a, b c14nAttr) int {
+		if r := cmp.Compare(a.uri, b.uri); r != 0 {
+			return r
+		}
+		return bytes.Compare(a.local, b.local)
+	})
+
+	c.out.push()
+	c.buf = append(c.buf, '<')
+	c.buf = append(c.buf, name...)
+	for _, ns := range c.ns {
+		c.out.declare(ns.prefix, ns.uri)
+		c.buf = append(c.buf, " xmlns"...)
+		if ns.prefix != "" {
+			c.buf = append(c.buf, ':')
+			c.buf = append(c.buf, ns.prefix...)
+		}
+		c.buf = append(c.buf, '=', '"')
+		c.buf = appendC14NAttr(c.buf, []byte(ns.uri))
+		c.buf = append(c.buf, '"')
+	}
+	for _, a := range c.attrs {
+		c.buf = append(c.buf, ' ')
+		c.buf = append(c.buf, a.qname...)
+		c.buf = append(c.buf, '=', '"')
+		c.buf = appendC14NAttr(c.buf, c.scratch[a.value[0]:a.value[1]])
+		c.buf = append(c.buf, '"')
+	}
+	c.buf = append(c.buf, '>')
+	c.depth++
+	return nil
+}
+
+// render adds the namespace node for prefix to the output unless the
+// nearest output ancestor already rendered the same binding.
+func (c *Canonicalizer) render(prefix string) {
+	if prefix == "xml" || prefix == "xmlns" {
+		return
+	}
+	for _, ns := range c.ns {
+		if ns.prefix == prefix {
+			return
+		}
+	}
+	uri, _ := c.in.lookup(prefix)
+	rendered, _ := c.out.lookup(prefix)
+	if uri == rendered {
+		return
+	}
+	c.ns = append(c.ns, nsBinding{prefix, uri})
+}
+
+func (c *Canonicalizer) end(name []byte) {
+	c.buf = append(c.buf, '<', '/')
+	c.buf = append(c.buf, name...)
+	c.buf = append(c.buf, '>')
+	c.in.pop()
+	c.out.pop()
+	c.depth--
+	if c.depth == 0 {
+		c.seenRoot = true
+	}
+}
+
+func appendC14NText(dst, b []byte) []byte {
+	last := 0
+	for i, c := range b {
+		var esc string
+		switch c {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			continue
+		}
+		dst = append(dst, b[last:i]...)
+		dst = append(dst, esc...)
+		last = i + 1
+	}
+	return append(dst, b[last:]...)
+}
+
+func appendC14NAttr(dst, b []byte) []byte {
+	last := 0
+	for i, c := range b {
+		var esc string
+		switch c {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '"':
+			esc = "&quot;"
+		case '\t':
+			esc = "&#x9;"
+		case '\n':
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			continue
+		}
+		dst = append(dst, b[last:i]...)
+		dst = append(dst, esc...)
+		last = i + 1
+	}
+	return append(dst, b[last:]...)
+}
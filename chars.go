@@ -0,0 +1,72 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "unicode/utf8"
+
+// checkChars implements CheckChars for ev.
+func (r *Reader) checkChars(ev Event) error {
+	switch ev.Type() {
+	case EventText, EventCData, EventStart, EventComment, EventProcessingInstruction, EventXMLDecl:
+	default:
+		return nil
+	}
+	b := ev.Bytes
+	for i := 0; i < len(b); {
+		if c := b[i]; c < utf8.RuneSelf {
+			if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+				return syntaxError(r.EventOffset()+int64(i), b, "illegal character %U", c)
+			}
+			i++
+			continue
+		}
+		c, n := utf8.DecodeRune(b[i:])
+		if c == utf8.RuneError && n == 1 {
+			return syntaxError(r.EventOffset()+int64(i), b, "invalid UTF-8")
+		}
+		if !isXMLChar(c) {
+			return syntaxError(r.EventOffset()+int64(i), b, "illegal character %U", c)
+		}
+		i += n
+	}
+	return nil
+}
+
+// isXMLChar reports whether c matches the Char production of XML 1.0.
+func isXMLChar(c rune) bool {
+	switch {
+	case c < 0x20:
+		return c == '\t' || c == '\n' || c == '\r'
+	case c <= 0xD7FF:
+		return true
+	case c < 0xE000:
+		return false
+	case c <= 0xFFFD:
+		return true
+	}
+	return 0x10000 <= c && c <= utf8.MaxRune
+}
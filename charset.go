@@ -0,0 +1,188 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Charset is the output encoding of a Writer.
+type Charset uint8
+
+const (
+	UTF8 Charset = iota
+	ISO88591
+	USASCII
+	// UTF16BE and UTF16LE both write "UTF-16" with a byte order mark.
+	UTF16BE
+	UTF16LE
+)
+
+// String returns the name of c as used in the XML declaration.
+func (c Charset) String() string {
+	switch c {
+	case UTF8:
+		return "UTF-8"
+	case ISO88591:
+		return "ISO-8859-1"
+	case USASCII:
+		return "US-ASCII"
+	case UTF16BE, UTF16LE:
+		return "UTF-16"
+	}
+	return "Charset(" + strconv.Itoa(int(c)) + ")"
+}
+
+// maxRune returns the largest rune representable in c.
+func (c Charset) maxRune() rune {
+	switch c {
+	case ISO88591:
+		return 0xFF
+	case USASCII:
+		return 0x7F
+	}
+	return utf8.MaxRune
+}
+
+// appendCharRefs replaces the runes of dst[start:] that are not
+// representable in c with numeric character references, each wrapped in
+// prefix and suffix.
+func (c Charset) appendCharRefs(dst []byte, start int, prefix, suffix string) []byte {
+	max := c.maxRune()
+	if max == utf8.MaxRune {
+		return dst
+	}
+	i := start
+	for i < len(dst) && rune(dst[i]) <= max && dst[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(dst) {
+		return dst
+	}
+	tail := bytes.Clone(dst[i:])
+	dst = dst[:i]
+	for len(tail) > 0 {
+		r, n := utf8.DecodeRune(tail)
+		if r <= max && r != utf8.RuneError {
+			dst = append(dst, tail[:n]...)
+		} else {
+			dst = append(dst, prefix...)
+			dst = append(dst, "&#x"...)
+			dst = strconv.AppendUint(dst, uint64(r), 16)
+			dst = append(dst, ';')
+			dst = append(dst, suffix...)
+		}
+		tail = tail[n:]
+	}
+	return dst
+}
+
+// encode appends b, which is UTF-8, to dst in the encoding c.
+func (c Charset) encode(dst, b []byte) ([]byte, error) {
+	switch c {
+	case UTF8:
+		return append(dst, b...), nil
+	case UTF16BE, UTF16LE:
+		for len(b) > 0 {
+			r, n := utf8.DecodeRune(b)
+			b = b[n:]
+			r1, r2 := utf16.EncodeRune(r)
+			if r1 == utf8.RuneError {
+				dst = c.appendUnit(dst, uint16(r))
+			} else {
+				dst = c.appendUnit(dst, uint16(r1))
+				dst = c.appendUnit(dst, uint16(r2))
+			}
+		}
+		return dst, nil
+	}
+	max := c.maxRune()
+	for len(b) > 0 {
+		r, n := utf8.DecodeRune(b)
+		if r > max || (r == utf8.RuneError && n == 1) {
+			return dst, fmt.Errorf("gosax: character %U is not representable in %s", r, c)
+		}
+		dst = append(dst, byte(r))
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+func (c Charset) appendUnit(dst []byte, u uint16) []byte {
+	if c == UTF16LE {
+		return append(dst, byte(u), byte(u>>8))
+	}
+	return append(dst, byte(u>>8), byte(u))
+}
+
+// bom returns the byte order mark written at the start of output in c.
+func (c Charset) bom() []byte {
+	switch c {
+	case UTF16BE:
+		return []byte{0xFE, 0xFF}
+	case UTF16LE:
+		return []byte{0xFF, 0xFE}
+	}
+	return nil
+}
+
+// setEncodingDecl returns the pseudo-attributes of an XML declaration with
+// the encoding declaration replaced by, or extended with, name.
+func setEncodingDecl(inst []byte, name string) []byte {
+	decl := `encoding="` + name + `"`
+	if i := bytes.Index(inst, []byte("encoding")); i >= 0 {
+		j := i + len("encoding")
+		for j < len(inst) && (inst[j] == '=' || whitespace[inst[j]]) {
+			j++
+		}
+		if j < len(inst) && (inst[j] == '"' || inst[j] == '\'') {
+			if k := bytes.IndexByte(inst[j+1:], inst[j]); k >= 0 {
+				out := append([]byte{}, inst[:i]...)
+				out = append(out, decl...)
+				return append(out, inst[j+1+k+1:]...)
+			}
+		}
+		return inst
+	}
+	// The encoding declaration follows the version declaration.
+	end := len(inst)
+	if i := bytes.Index(inst, []byte("standalone")); i >= 0 {
+		end = i
+	}
+	out := append([]byte{}, trimSpace(inst[:end])...)
+	out = append(out, ' ')
+	out = append(out, decl...)
+	if end < len(inst) {
+		out = append(out, ' ')
+		out = append(out, inst[end:]...)
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command gosax-conformance runs the W3C XML Conformance Test Suite against
+// gosax and prints the pass rates.
+//
+// Usage:
+//
+//	gosax-conformance [-v] [-master xmlconf.xml] xmlconf-dir
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/orisano/gosax/conformance"
+)
+
+func main() {
+	verbose := flag.Bool("v", false, "list the failed tests")
+	master := flag.String("master", "xmlconf.xml", "master file of the suite, relative to the suite directory")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] xmlconf-dir\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+	log.SetPrefix("gosax-conformance: ")
+
+	suite := os.DirFS(flag.Arg(0))
+	tests, err := conformance.LoadSuite(suite, *master)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := conformance.Run(suite, tests, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *verbose {
+		for _, f := range report.Failures() {
+			fmt.Printf("FAIL %s [%s] %s: %s\n", f.ID, f.Type, f.Path, f.Description)
+		}
+	}
+	fmt.Print(report)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command gosax-index builds the offset index of an XML file and writes it
+// next to the file as a sidecar, so the file can be opened for random
+// access with gosax.OpenIndexed. With -binary, the index is written in the
+// binary format of gosax.IndexSnapshot, which query workers can memory-map
+// and share instead of decoding it.
+//
+// Usage:
+//
+//	gosax-index [-record path] [-id attrs] [-binary] file.xml
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/orisano/gosax"
+)
+
+func main() {
+	record := flag.String("record", "", "path of the record elements, such as feed/entry (* matches any name)")
+	idAttrs := flag.String("id", "", "comma-separated attributes holding element IDs besides xml:id")
+	bin := flag.Bool("binary", false, "write the binary snapshot format instead of JSON")
+	out := flag.String("o", "", "index file (default: file"+gosax.IndexSuffix+")")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] file.xml\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+	log.SetPrefix("gosax-index: ")
+
+	name := flag.Arg(0)
+	f, err := os.Open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	var attrs []string
+	if *idAttrs != "" {
+		attrs = strings.Split(*idAttrs, ",")
+	}
+	ix, err := gosax.BuildIndex(gosax.NewReader(f), *record, attrs...)
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+
+	if *out == "" {
+		*out = name + gosax.IndexSuffix
+	}
+	of, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(of)
+	if *bin {
+		var b []byte
+		if b, err = ix.MarshalBinary(); err == nil {
+			_, err = w.Write(b)
+		}
+	} else {
+		_, err = ix.WriteTo(w)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := of.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s: %d bytes, %d paths, %d records, %d ids\n", *out, ix.Size, len(ix.Paths), len(ix.Records), len(ix.IDs))
+}
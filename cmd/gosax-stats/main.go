@@ -0,0 +1,179 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command gosax-stats profiles the structure of a corpus of XML documents:
+// the element and attribute paths used, their counts and sizes, and the
+// declared encodings. Arguments may be files, directories, which are
+// searched for .xml files, or glob patterns. Compressed inputs are
+// decompressed automatically. With -go, it writes Go types for decoding
+// the documents with encoding/xml instead, inferred from their values.
+//
+// Usage:
+//
+//	gosax-stats [-json | -go] [-top n] path ...
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/orisano/gosax"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	goTypes := flag.Bool("go", false, "write Go types inferred from the documents")
+	top := flag.Int("top", 0, "show only the n most frequent paths (0 shows all)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] path ...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+	log.SetPrefix("gosax-stats: ")
+
+	files, err := expand(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	p := gosax.NewProfile()
+	p.InferTypes = *goTypes
+	failed := false
+	for _, name := range files {
+		if err := add(p, name); err != nil {
+			log.Printf("%s: %v", name, err)
+			failed = true
+		}
+	}
+	if *goTypes {
+		err = p.WriteGoTypes(os.Stdout)
+	} else if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(p)
+	} else {
+		err = report(os.Stdout, p, *top)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// expand returns the files named by args.
+func expand(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, err
+			}
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				files = append(files, m)
+				continue
+			}
+			err = filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".xml") {
+					files = append(files, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+func add(p *gosax.Profile, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := gosax.NewReaderAuto(f)
+	if err != nil {
+		return err
+	}
+	return p.Add(r)
+}
+
+func report(w io.Writer, p *gosax.Profile, top int) error {
+	fmt.Fprintf(w, "documents: %d\nbytes: %d\n", p.Documents, p.Bytes)
+	encodings := make([]string, 0, len(p.Encodings))
+	for enc, n := range p.Encodings {
+		encodings = append(encodings, fmt.Sprintf("%s (%d)", enc, n))
+	}
+	slices.Sort(encodings)
+	fmt.Fprintf(w, "encodings: %s\n\n", strings.Join(encodings, ", "))
+
+	paths := make([]string, 0, len(p.Paths))
+	for path := range p.Paths {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	if top > 0 && top < len(paths) {
+		slices.SortStableFunc(paths, func(a, b string) int {
+			return int(p.Paths[b].Count - p.Paths[a].Count)
+		})
+		paths = paths[:top]
+		slices.Sort(paths)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "count\tbytes\tmax\ttext\t\tpath")
+	for _, path := range paths {
+		s := p.Paths[path]
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t\t%s\n", s.Count, s.Bytes, s.MaxBytes, s.TextBytes, path)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command gosax-validate checks XML files for well-formedness and reports
+// the first error in each file with its line and column, followed by the
+// start of the malformed markup when it is known. With no file arguments, or
+// with "-", it reads the standard input.
+//
+// By default problems are only reported. With -exit-code, the command exits
+// with 1 if any input is not well-formed, which suits CI checks. I/O and
+// usage errors always exit with 2.
+//
+// Usage:
+//
+//	gosax-validate [-exit-code] [-q] [file.xml ...]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/orisano/gosax"
+)
+
+func main() {
+	exitCode := flag.Bool("exit-code", false, "exit with 1 if any input is not well-formed")
+	quiet := flag.Bool("q", false, "do not print the names of well-formed inputs")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file.xml ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	names := flag.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	status := 0
+	for _, name := range names {
+		ok, err := validateFile(name, *quiet)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gosax-validate: %v\n", err)
+			status = 2
+		} else if !ok && *exitCode && status == 0 {
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+// maxPartial is the maximum number of bytes of a malformed event shown.
+const maxPartial = 64
+
+func validateFile(name string, quiet bool) (bool, error) {
+	f, display, err := open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	off, err := validate(f)
+	if err == nil {
+		if !quiet {
+			fmt.Printf("%s: ok\n", display)
+		}
+		return true, nil
+	}
+	var partial []byte
+	var serr *gosax.SyntaxError
+	if errors.As(err, &serr) {
+		off = serr.Offset
+		partial = bytes.Clone(serr.PartialEvent[:min(len(serr.PartialEvent), maxPartial)])
+	}
+	if _, serr := f.Seek(0, io.SeekStart); serr != nil {
+		return false, serr
+	}
+	line, col, perr := position(f, off)
+	if perr != nil {
+		return false, perr
+	}
+	fmt.Printf("%s:%d:%d: %s\n", display, line, col, strings.TrimPrefix(err.Error(), "gosax: "))
+	if len(partial) > 0 {
+		fmt.Printf("\t%q\n", partial)
+	}
+	return false, nil
+}
+
+// open opens the named file. The standard input is spooled to a temporary
+// file, so that the position of an error can be computed afterwards.
+func open(name string) (*os.File, string, error) {
+	if name != "-" {
+		f, err := os.Open(name)
+		return f, name, err
+	}
+	f, err := os.CreateTemp("", "gosax-validate-*.xml")
+	if err != nil {
+		return nil, "", err
+	}
+	os.Remove(f.Name())
+	if _, err := io.Copy(f, os.Stdin); err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	return f, "<stdin>", nil
+}
+
+// validate reads r to the end and returns the first well-formedness error
+// with the offset of the event it was found at.
+func validate(r io.Reader) (int64, error) {
+	xr := gosax.NewReader(r)
+	xr.CheckWellFormed = true
+	xr.CheckComments = true
+	xr.CheckCDataEnd = true
+	xr.CheckChars = true
+	for {
+		e, err := xr.Event()
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return xr.EventOffset(), err
+		}
+		if e.Type() == gosax.EventEOF {
+			return 0, nil
+		}
+	}
+}
+
+// position returns the 1-based line and column of the byte at off.
+func position(r io.Reader, off int64) (int, int, error) {
+	br := bufio.NewReader(io.LimitReader(r, off))
+	line, col := 1, 1
+	for {
+		c, _, err := br.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return line, col, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if c == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+}
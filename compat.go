@@ -38,9 +38,39 @@ import (
 
 // StartElement converts a byte slice to an xml.StartElement.
 func StartElement(b []byte) (xml.StartElement, error) {
+	return StartElementInterner(b, nil)
+}
+
+// StartElementInterner is like StartElement, but converts the names and
+// values of the element with in. A nil in allocates new strings.
+func StartElementInterner(b []byte, in Interner) (xml.StartElement, error) {
+	return startElement(b, in, nil, nil)
+}
+
+// StartElementFunc is like StartElement, but converts only the attributes
+// whose raw key, such as "id" or "xml:lang", keep returns true for. The
+// values of other attributes are neither unescaped nor copied.
+func StartElementFunc(b []byte, keep func(key []byte) bool) (xml.StartElement, error) {
+	return startElement(b, nil, keep, nil)
+}
+
+// StartElementAttrs is like StartElementFunc, keeping the attributes whose
+// raw key is one of keys.
+func StartElementAttrs(b []byte, keys ...string) (xml.StartElement, error) {
+	return startElement(b, nil, func(key []byte) bool {
+		for _, k := range keys {
+			if k == string(key) {
+				return true
+			}
+		}
+		return false
+	}, nil)
+}
+
+func startElement(b []byte, in Interner, keep func([]byte) bool, ents Entities) (xml.StartElement, error) {
 	name, b := Name(b)
 	e := xml.StartElement{
-		Name: xmlName(name),
+		Name: xmlName(name, in),
 	}
 	for len(b) > 0 {
 		var attr Attribute
@@ -52,13 +82,16 @@ func StartElement(b []byte) (xml.StartElement, error) {
 		if len(attr.Key) == 0 {
 			break
 		}
-		value, err := Unescape(attr.Value[1 : len(attr.Value)-1])
+		if keep != nil && !keep(attr.Key) {
+			continue
+		}
+		value, err := unescapeAttr(attr.Value[1:len(attr.Value)-1], ents)
 		if err != nil {
 			return xml.StartElement{}, err
 		}
 		e.Attr = append(e.Attr, xml.Attr{
-			Name:  xmlName(attr.Key),
-			Value: string(value),
+			Name:  xmlName(attr.Key, in),
+			Value: intern(in, value),
 		})
 	}
 	return e, nil
@@ -66,9 +99,14 @@ func StartElement(b []byte) (xml.StartElement, error) {
 
 // EndElement converts a byte slice to an xml.EndElement.
 func EndElement(b []byte) xml.EndElement {
+	return EndElementInterner(b, nil)
+}
+
+// EndElementInterner is like EndElement, but converts the name with in.
+func EndElementInterner(b []byte, in Interner) xml.EndElement {
 	name, _ := Name(b)
 	return xml.EndElement{
-		Name: xmlName(name),
+		Name: xmlName(name, in),
 	}
 }
 
@@ -82,12 +120,21 @@ func Comment(b []byte) xml.Comment {
 	return trim(b, "<!--", "-->")
 }
 
-// ProcInst converts a byte slice to an xml.ProcInst.
+// ProcInst converts a byte slice to an xml.ProcInst. Like encoding/xml,
+// Inst starts after the whitespace that follows the target.
 func ProcInst(b []byte) xml.ProcInst {
-	name, b := Name(b)
+	b = trim(b, "<?", "?>")
+	i := 0
+	for i < len(b) && !whitespace[b[i]] {
+		i++
+	}
+	target := b[:i]
+	for i < len(b) && whitespace[b[i]] {
+		i++
+	}
 	return xml.ProcInst{
-		Target: string(name[1:]),
-		Inst:   b[:len(b)-1],
+		Target: string(target),
+		Inst:   b[i:],
 	}
 }
 
@@ -103,18 +150,27 @@ func Directive(b []byte) xml.Directive {
 // the direct conversion functions (StartElement, EndElement, CharData, etc.)
 // instead of Token, as they allow better control over memory allocations.
 func Token(e Event) (xml.Token, error) {
+	return TokenInterner(e, nil)
+}
+
+// TokenInterner is like Token, but converts the names and attribute values
+// of elements with in.
+func TokenInterner(e Event, in Interner) (xml.Token, error) {
 	switch e.Type() {
 	case EventStart:
-		return StartElement(e.Bytes)
+		return StartElementInterner(e.Bytes, in)
 	case EventEnd:
-		return EndElement(e.Bytes), nil
-	case EventText:
+		return EndElementInterner(e.Bytes, in), nil
+	case EventText, EventEntityRef:
+		if e.Unescaped() {
+			return xml.CharData(e.Bytes), nil
+		}
 		return CharData(e.Bytes)
 	case EventCData:
 		return xml.CharData(trim(e.Bytes, "<![CDATA[", "]]>")), nil
 	case EventComment:
 		return Comment(e.Bytes), nil
-	case EventProcessingInstruction:
+	case EventProcessingInstruction, EventXMLDecl:
 		return ProcInst(e.Bytes), nil
 	case EventDocType:
 		return Directive(e.Bytes), nil
@@ -135,39 +191,65 @@ func TokenE(e Event, err error) (xml.Token, error) {
 }
 
 // Skip advances the XML reader to the end of the current nested scope, returning an error if encountered.
+// After a self-closing start tag there is nothing to skip but the end event
+// reported with EmitSelfClosingTag. If the input ends first, Skip returns
+// io.ErrUnexpectedEOF.
 func Skip(r *Reader) error {
-	var depth int64
-	for {
-		ev, err := r.Event()
-		if err != nil {
-			return err
-		}
-		switch ev.Type() {
-		case EventStart:
-			depth++
-		case EventEnd:
-			if depth == 0 {
-				return nil
-			}
-			depth--
-		default:
-		}
-	}
+	return r.walkSubtree(discardEvent)
+}
+
+// SkipFunc is like Skip, but calls fn for each skipped event, up to and
+// including the end tag that completes the skip.
+func SkipFunc(r *Reader, fn func(Event) error) error {
+	return r.walkSubtree(fn)
+}
+
+func discardEvent(Event) error {
+	return nil
 }
 
-func xmlName(b []byte) xml.Name {
-	if i := bytes.IndexByte(b, ':'); i >= 0 {
+func xmlName(b []byte, in Interner) xml.Name {
+	// Like encoding/xml, a leading or trailing colon is part of the local name.
+	if i := bytes.IndexByte(b, ':'); i > 0 && i < len(b)-1 {
 		return xml.Name{
-			Space: string(b[:i]),
-			Local: string(b[i+1:]),
+			Space: intern(in, b[:i]),
+			Local: intern(in, b[i+1:]),
 		}
 	} else {
 		return xml.Name{
-			Local: string(b),
+			Local: intern(in, b),
 		}
 	}
 }
 
+// An Interner returns a string equal to b, which may be shared with earlier
+// calls for the same bytes, so that repeated names and values are not
+// allocated again.
+type Interner interface {
+	Intern(b []byte) string
+}
+
+// MapInterner is an Interner keeping every string it returns. It is not
+// safe for concurrent use.
+type MapInterner map[string]string
+
+// Intern returns the string equal to b, adding it to m if needed.
+func (m MapInterner) Intern(b []byte) string {
+	if s, ok := m[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	m[s] = s
+	return s
+}
+
+func intern(in Interner, b []byte) string {
+	if in == nil {
+		return string(b)
+	}
+	return in.Intern(b)
+}
+
 func trim(b []byte, prefix, suffix string) []byte {
 	return bytes.TrimSuffix(bytes.TrimPrefix(b, []byte(prefix)), []byte(suffix))
 }
@@ -0,0 +1,319 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package conformance runs the W3C XML Conformance Test Suite against the
+// gosax Reader.
+//
+// gosax is a non-validating parser that does not read external entities, so
+// only whether a document is well-formed is checked: valid and invalid tests
+// pass when the document is accepted, not-wf tests pass when it is rejected.
+// The report lists the spec corners gosax intentionally relaxes.
+package conformance
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/orisano/gosax"
+)
+
+// Test is a TEST entry of the suite.
+type Test struct {
+	ID          string
+	Type        string // valid, invalid, not-wf or error
+	Entities    string // none, parameter, general or both
+	Version     string
+	Sections    string
+	Description string
+	// Path is the path of the test document in the suite file system.
+	Path string
+}
+
+// Applicable reports whether t can be decided by a non-validating parser
+// that does not read external entities.
+func (t *Test) Applicable() bool {
+	switch t.Type {
+	case "valid", "invalid":
+	case "not-wf":
+		// The error may be in an external entity, which is not read.
+		if t.Entities != "" && t.Entities != "none" {
+			return false
+		}
+	default:
+		return false
+	}
+	return t.Version == "" || strings.Contains(t.Version, "1.0")
+}
+
+// LoadSuite reads the tests of the suite whose master file, usually
+// xmlconf.xml, is name in fsys. The test case files the master file includes
+// as external entities are read as well.
+func LoadSuite(fsys fs.FS, name string) ([]Test, error) {
+	l := &loader{fsys: fsys, root: path.Dir(name), seen: make(map[string]bool)}
+	if err := l.load(name, nil); err != nil {
+		return nil, err
+	}
+	return l.tests, nil
+}
+
+type loader struct {
+	fsys  fs.FS
+	root  string
+	seen  map[string]bool
+	tests []Test
+}
+
+func (l *loader) load(name string, bases []string) error {
+	if l.seen[name] {
+		return fmt.Errorf("conformance: %s includes itself", name)
+	}
+	l.seen[name] = true
+	defer delete(l.seen, name)
+
+	b, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		return err
+	}
+	r := gosax.NewReader(bytes.NewReader(b))
+	r.EmitEntityRefs = true
+	var dtd *gosax.DTD
+	depth := len(bases)
+	var test *Test
+	var desc []byte
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return fmt.Errorf("conformance: %s: %w", name, err)
+		}
+		switch e.Type() {
+		case gosax.EventDocType:
+			if dtd, err = gosax.ParseDTD(e.Bytes); err != nil {
+				return fmt.Errorf("conformance: %s: %w", name, err)
+			}
+		case gosax.EventEntityRef:
+			ref := string(gosax.EntityRefName(e.Bytes))
+			if test != nil {
+				desc = append(desc, e.Bytes...)
+			} else if dtd != nil {
+				if sys, ok := dtd.ExternalEntities[ref]; ok {
+					if err := l.load(path.Join(path.Dir(name), sys), bases); err != nil {
+						return err
+					}
+				}
+			}
+		case gosax.EventStart:
+			se, err := gosax.StartElement(e.Bytes)
+			if err != nil {
+				return fmt.Errorf("conformance: %s: %w", name, err)
+			}
+			switch se.Name.Local {
+			case "TESTCASES":
+				bases = append(bases, attr(se.Attr, "base"))
+			case "TEST":
+				test = &Test{
+					ID:       attr(se.Attr, "ID"),
+					Type:     attr(se.Attr, "TYPE"),
+					Entities: attr(se.Attr, "ENTITIES"),
+					Version:  attr(se.Attr, "VERSION"),
+					Sections: attr(se.Attr, "SECTIONS"),
+					Path:     l.resolve(name, bases, attr(se.Attr, "URI")),
+				}
+				desc = desc[:0]
+				if bytes.HasSuffix(e.Bytes, []byte("/>")) {
+					l.tests = append(l.tests, *test)
+					test = nil
+				}
+			}
+		case gosax.EventText, gosax.EventCData:
+			if test != nil {
+				desc = append(desc, e.Bytes...)
+			}
+		case gosax.EventEnd:
+			tag, _ := gosax.Name(e.Bytes)
+			switch string(tag) {
+			case "TESTCASES":
+				if len(bases) > depth {
+					bases = bases[:len(bases)-1]
+				}
+			case "TEST":
+				if test != nil {
+					test.Description = strings.Join(strings.Fields(string(desc)), " ")
+					l.tests = append(l.tests, *test)
+					test = nil
+				}
+			}
+		case gosax.EventEOF:
+			return nil
+		}
+	}
+}
+
+// resolve returns the path of uri, a test URI found in file. Suites use
+// xml:base relative to the master file, but older test case files give
+// URIs relative to themselves.
+func (l *loader) resolve(file string, bases []string, uri string) string {
+	p := l.root
+	for _, b := range bases {
+		p = path.Join(p, b)
+	}
+	p = path.Join(p, uri)
+	if _, err := fs.Stat(l.fsys, p); err != nil {
+		if q := path.Join(path.Dir(file), uri); q != p {
+			if _, err := fs.Stat(l.fsys, q); err == nil {
+				return q
+			}
+		}
+	}
+	return p
+}
+
+func attr(attrs []xml.Attr, local string) string {
+	for _, a := range attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Result is the outcome of a test.
+type Result struct {
+	Test
+	Passed bool
+	// Err is the error reported for the document, if any.
+	Err error
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Results []Result
+	// Skipped counts the tests that are not applicable.
+	Skipped int
+}
+
+// Failures returns the results of the tests that did not pass.
+func (r *Report) Failures() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if !res.Passed {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// String formats the pass rates by test type.
+func (r *Report) String() string {
+	type count struct{ passed, total int }
+	counts := make(map[string]*count)
+	var all count
+	for _, res := range r.Results {
+		c := counts[res.Type]
+		if c == nil {
+			c = &count{}
+			counts[res.Type] = c
+		}
+		c.total++
+		all.total++
+		if res.Passed {
+			c.passed++
+			all.passed++
+		}
+	}
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	var sb strings.Builder
+	line := func(name string, c count) {
+		fmt.Fprintf(&sb, "%-8s %5d/%-5d %6.2f%%\n", name, c.passed, c.total, 100*float64(c.passed)/float64(max(c.total, 1)))
+	}
+	for _, t := range types {
+		line(t, *counts[t])
+	}
+	line("total", all)
+	fmt.Fprintf(&sb, "skipped  %5d\n", r.Skipped)
+	return sb.String()
+}
+
+// Run runs the applicable tests, reading the documents from fsys. A nil
+// check uses WellFormed.
+func Run(fsys fs.FS, tests []Test, check func(io.Reader) error) (*Report, error) {
+	if check == nil {
+		check = WellFormed
+	}
+	var report Report
+	for _, t := range tests {
+		if !t.Applicable() {
+			report.Skipped++
+			continue
+		}
+		f, err := fsys.Open(t.Path)
+		if err != nil {
+			return nil, err
+		}
+		err = check(f)
+		f.Close()
+		res := Result{Test: t, Err: err}
+		if t.Type == "not-wf" {
+			res.Passed = err != nil
+		} else {
+			res.Passed = err == nil
+		}
+		report.Results = append(report.Results, res)
+	}
+	return &report, nil
+}
+
+// WellFormed reads r with the checks the Reader offers and reports the
+// first error.
+func WellFormed(r io.Reader) error {
+	xr := gosax.NewReader(r)
+	xr.CheckWellFormed = true
+	xr.CheckComments = true
+	xr.CheckCDataEnd = true
+	xr.CheckChars = true
+	for {
+		e, err := xr.Event()
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if e.Type() == gosax.EventEOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package conformance_test
+
+import (
+	"fmt"
+	"log"
+	"testing/fstest"
+
+	"github.com/orisano/gosax/conformance"
+)
+
+func Example() {
+	suite := fstest.MapFS{
+		"xmlconf.xml": {Data: []byte(`<!DOCTYPE TESTSUITE [
+<!ENTITY sample SYSTEM "sample/sample.xml">
+]>
+<TESTSUITE PROFILE="sample">&sample;</TESTSUITE>`)},
+		"sample/sample.xml": {Data: []byte(`<TESTCASES PROFILE="sample" xml:base="sample/">
+<TEST TYPE="valid" ENTITIES="none" ID="ok" URI="ok.xml" SECTIONS="2.1">Empty element</TEST>
+<TEST TYPE="not-wf" ENTITIES="none" ID="mismatch" URI="mismatch.xml" SECTIONS="3">Mismatched end tag</TEST>
+<TEST TYPE="not-wf" ENTITIES="none" ID="dup-attr" URI="dup.xml" SECTIONS="3.1">Duplicate attribute</TEST>
+<TEST TYPE="error" ENTITIES="none" ID="err" URI="ok.xml" SECTIONS="4">Processor-dependent</TEST>
+</TESTCASES>`)},
+		"sample/ok.xml":       {Data: []byte(`<doc/>`)},
+		"sample/mismatch.xml": {Data: []byte(`<doc></dot>`)},
+		"sample/dup.xml":      {Data: []byte(`<doc a="1" a="2"/>`)},
+	}
+	tests, err := conformance.LoadSuite(suite, "xmlconf.xml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := conformance.Run(suite, tests, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(report)
+	for _, f := range report.Failures() {
+		fmt.Printf("FAIL %s (%s): %s\n", f.ID, f.Sections, f.Description)
+	}
+	// Output:
+	// not-wf       1/2      50.00%
+	// valid        1/1     100.00%
+	// total        2/3      66.67%
+	// skipped      1
+	// FAIL dup-attr (3.1): Duplicate attribute
+}
@@ -0,0 +1,868 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Unmarshal parses the XML document data and stores the result in the value
+// pointed to by v, following the same rules as encoding/xml.Unmarshal.
+// Only the first element of data is decoded.
+func Unmarshal(data []byte, v any) error {
+	r := NewReaderBuf(bytes.NewReader(data), make([]byte, 0, len(data)+minReadSize))
+	return NewDecoderReader(r).Decode(v)
+}
+
+// A Decoder reads Go values from an XML input stream.
+type Decoder struct {
+	d decodeState
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderReader(NewReader(r))
+}
+
+// NewDecoderReader returns a new Decoder reading events from r.
+func NewDecoderReader(r *Reader) *Decoder {
+	return &Decoder{d: decodeState{r: r}}
+}
+
+// SetLimits bounds the resources used to decode the input, as described by
+// Limits, with defaults for the zero fields. It sets the Limits of the
+// Reader, so it must be called before the first call to Decode. MaxBytes is
+// not enforced by a Decoder.
+func (d *Decoder) SetLimits(l Limits) {
+	d.d.ns.setLimits(l)
+	d.d.r.Limits = l.resolve()
+}
+
+// Reader returns the Reader the Decoder reads from, so that decoding can be
+// interleaved with reading events.
+func (d *Decoder) Reader() *Reader {
+	return d.d.r
+}
+
+// Decode reads the next element and stores it in the value pointed to by v.
+// Events before the element, such as the XML declaration, comments and
+// text, are skipped. It returns io.EOF when no element is left.
+//
+// Struct fields are mapped with the same tags as encoding/xml.Unmarshal:
+// element names, "a>b" parent chains, attr, chardata, cdata, innerxml,
+// comment, any and XMLName fields. In addition, a "*" step in an element
+// path matches any element name, as in "meta>*>value". An element that a
+// field maps takes precedence over fields mapping elements below it. Types
+// implementing xml.Unmarshaler, xml.UnmarshalerAttr or
+// encoding.TextUnmarshaler are decoded with their own methods.
+func (d *Decoder) Decode(v any) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return errors.New("gosax: Decode requires a non-nil pointer")
+	}
+	return d.d.decode(val.Elem())
+}
+
+// DecodeElement stores the element started by the last event of the Reader,
+// which must be an EventStart, in the value pointed to by v.
+func (d *Decoder) DecodeElement(v any) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return errors.New("gosax: DecodeElement requires a non-nil pointer")
+	}
+	start := d.d.r.last
+	if start.Type() != EventStart {
+		return errors.New("gosax: DecodeElement called without EventStart")
+	}
+	if err := d.d.declare(start.Bytes); err != nil {
+		return err
+	}
+	return d.d.unmarshal(val.Elem(), start.Bytes)
+}
+
+type decodeState struct {
+	r  *Reader
+	ns nsScope
+	// types holds the type information compiled by a DecoderPlan.
+	types map[reflect.Type]*typeInfo
+	hooks *decodeHooks
+	// settle is set while the namespace frame of a self-closing tag has not
+	// been popped yet.
+	settle bool
+	// saved collects the raw content of elements decoded into innerxml
+	// fields.
+	saved []*[]byte
+	buf   []byte
+}
+
+// reset prepares d for reading from r, keeping its buffers.
+func (d *decodeState) reset(r *Reader) {
+	d.r = r
+	d.ns.reset()
+	d.settle = false
+	d.saved = d.saved[:0]
+}
+
+// decode decodes the next element into val.
+func (d *decodeState) decode(val reflect.Value) error {
+	for {
+		ev, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch ev.Type() {
+		case EventStart:
+			return d.unmarshal(val, ev.Bytes)
+		case EventEOF:
+			return io.EOF
+		}
+	}
+}
+
+// typeInfo returns the typeInfo of typ.
+func (d *decodeState) typeInfo(typ reflect.Type) (*typeInfo, error) {
+	if tinfo, ok := d.types[typ]; ok {
+		return tinfo, nil
+	}
+	return getTypeInfo(typ)
+}
+
+// next reads the next event, keeping the namespace scope up to date.
+func (d *decodeState) next() (Event, error) {
+	if d.settle {
+		d.ns.pop()
+		d.settle = false
+	}
+	ev, err := d.r.Event()
+	if err != nil {
+		return ev, err
+	}
+	for _, s := range d.saved {
+		if ev.Type() != EventEnd || !isSelfClosing(ev.Bytes) {
+			*s = append(*s, d.r.Raw()...)
+		}
+	}
+	switch ev.Type() {
+	case EventStart:
+		if err := d.declare(ev.Bytes); err != nil {
+			return ev, err
+		}
+	case EventEnd:
+		if !isSelfClosing(ev.Bytes) && len(d.ns.marks) > 0 {
+			d.ns.pop()
+		}
+	}
+	return ev, nil
+}
+
+// declare pushes the namespace frame of the start tag b.
+func (d *decodeState) declare(b []byte) error {
+	d.ns.push()
+	d.settle = isSelfClosing(b)
+	_, attrs := Name(b)
+	if bytes.Contains(attrs, []byte("xmlns")) {
+		for len(attrs) > 0 {
+			attr, rest, err := NextAttribute(attrs)
+			if err != nil || len(attr.Key) == 0 {
+				break
+			}
+			attrs = rest
+			if prefix, ok := nsDecl(attr.Key); ok && len(attr.Value) >= 2 {
+				if name, max := d.ns.checkLimits(prefix); name != "" {
+					return &LimitError{Limit: name, Max: int64(max), Offset: d.r.EventOffset()}
+				}
+				uri, err := UnescapeAttr(bytes.Clone(attr.Value[1 : len(attr.Value)-1]))
+				if err == nil {
+					d.ns.declare(prefix, string(uri))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// name returns the namespace URI and local name of the tag b.
+func (d *decodeState) name(b []byte) (string, []byte) {
+	qname, _ := Name(b)
+	prefix, local := splitQName(qname)
+	uri, _ := d.ns.lookup(string(prefix))
+	return uri, local
+}
+
+// skip consumes the element started by start.
+func (d *decodeState) skip(start []byte) error {
+	if isSelfClosing(start) {
+		if d.r.EmitSelfClosingTag {
+			_, err := d.next()
+			return err
+		}
+		return nil
+	}
+	depth := 0
+	for {
+		ev, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch ev.Type() {
+		case EventStart:
+			if !isSelfClosing(ev.Bytes) {
+				depth++
+			}
+		case EventEnd:
+			if isSelfClosing(ev.Bytes) {
+				break
+			}
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		case EventEOF:
+			return io.ErrUnexpectedEOF
+		}
+	}
+}
+
+// content reads the content of the element started by start, calling fn
+// for each event up to its end tag. fn must consume the elements it is
+// given; elements it does not handle are skipped when it returns false.
+func (d *decodeState) content(start []byte, fn func(ev Event) (bool, error)) error {
+	if isSelfClosing(start) {
+		if d.r.EmitSelfClosingTag {
+			_, err := d.next()
+			return err
+		}
+		return nil
+	}
+	for {
+		ev, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch ev.Type() {
+		case EventEnd:
+			if !isSelfClosing(ev.Bytes) {
+				return nil
+			}
+			continue
+		case EventEOF:
+			return io.ErrUnexpectedEOF
+		}
+		handled, err := fn(ev)
+		if err != nil {
+			return err
+		}
+		if !handled && ev.Type() == EventStart {
+			if err := d.skip(ev.Bytes); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+var (
+	unmarshalerType     = reflect.TypeFor[xml.Unmarshaler]()
+	unmarshalerAttrType = reflect.TypeFor[xml.UnmarshalerAttr]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// unmarshal decodes the element started by start into val.
+func (d *decodeState) unmarshal(val reflect.Value, start []byte) error {
+	return d.unmarshalXSD(val, start, "")
+}
+
+// unmarshalXSD is unmarshal for a field tagged with the XSD type xsd.
+func (d *decodeState) unmarshalXSD(val reflect.Value, start []byte, xsd string) error {
+	if val.Kind() == reflect.Interface && !val.IsNil() {
+		if e := val.Elem(); e.Kind() == reflect.Pointer && !e.IsNil() {
+			val = e
+		}
+	}
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+
+	if fn := d.hooks.lookup(val.Type(), xsd); fn != nil {
+		text, err := d.text(start)
+		if err != nil {
+			return err
+		}
+		return fn(val, text)
+	}
+
+	if val.CanAddr() {
+		pv := val.Addr()
+		if pv.Type().Implements(unmarshalerType) {
+			return d.unmarshalInterface(pv.Interface().(xml.Unmarshaler), start)
+		}
+		if pv.Type().Implements(textUnmarshalerType) {
+			text, err := d.text(start)
+			if err != nil {
+				return err
+			}
+			return pv.Interface().(encoding.TextUnmarshaler).UnmarshalText(text)
+		}
+	}
+
+	switch val.Kind() {
+	case reflect.Interface:
+		return d.skip(start)
+	case reflect.Slice:
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		n := val.Len()
+		val.Grow(1)
+		val.SetLen(n + 1)
+		if err := d.unmarshalXSD(val.Index(n), start, xsd); err != nil {
+			val.SetLen(n)
+			return err
+		}
+		return nil
+	case reflect.Struct:
+		if val.Type() == nameType {
+			uri, local := d.name(start)
+			val.Set(reflect.ValueOf(xml.Name{Space: uri, Local: string(local)}))
+			return d.skip(start)
+		}
+		return d.unmarshalStruct(val, start)
+	}
+	text, err := d.text(start)
+	if err != nil {
+		return err
+	}
+	return setValue(val, text)
+}
+
+// text returns the character data of the element started by start, skipping
+// child elements. The result is valid until the next call to text.
+func (d *decodeState) text(start []byte) ([]byte, error) {
+	buf := d.buf[:0]
+	err := d.content(start, func(ev Event) (bool, error) {
+		var err error
+		buf, err = d.appendCharData(buf, ev)
+		return false, err
+	})
+	d.buf = buf
+	return buf, err
+}
+
+// appendCharData appends the character data of ev to dst.
+func (d *decodeState) appendCharData(dst []byte, ev Event) ([]byte, error) {
+	return appendCharData(dst, ev)
+}
+
+// appendCharData appends the character data of ev to dst.
+func appendCharData(dst []byte, ev Event) ([]byte, error) {
+	switch ev.Type() {
+	case EventText, EventEntityRef:
+		if ev.Unescaped() {
+			return append(dst, ev.Bytes...), nil
+		}
+		n := len(dst)
+		dst = append(dst, ev.Bytes...)
+		v, err := Unescape(dst[n:])
+		if err != nil {
+			return dst[:n], err
+		}
+		return dst[:n+len(v)], nil
+	case EventCData:
+		return append(dst, trim(ev.Bytes, "<![CDATA[", "]]>")...), nil
+	}
+	return dst, nil
+}
+
+func (d *decodeState) unmarshalStruct(val reflect.Value, start []byte) error {
+	tinfo, err := d.typeInfo(val.Type())
+	if err != nil {
+		return err
+	}
+	if tinfo.xmlname != nil {
+		uri, local := d.name(start)
+		finfo := tinfo.xmlname
+		if finfo.name != "" && finfo.name != string(local) {
+			return fmt.Errorf("gosax: expected element type <%s> but have <%s>", finfo.name, local)
+		}
+		if finfo.xmlns != "" && finfo.xmlns != uri {
+			return fmt.Errorf("gosax: expected element <%s> in name space %s but have %s", finfo.name, finfo.xmlns, nsOrNone(uri))
+		}
+		if v := finfo.valueAlloc(val); v.Type() == nameType {
+			v.Set(reflect.ValueOf(xml.Name{Space: uri, Local: string(local)}))
+		}
+	}
+	if err := d.unmarshalAttrs(tinfo, val, start); err != nil {
+		return err
+	}
+
+	var chardata, comment, innerxml, anyElem *fieldInfo
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		switch finfo.flags & fMode {
+		case fCharData, fCDATA:
+			if chardata == nil {
+				chardata = finfo
+			}
+		case fComment:
+			if comment == nil {
+				comment = finfo
+			}
+		case fInnerXML:
+			if innerxml == nil {
+				innerxml = finfo
+			}
+		case fAny, fAny | fElement:
+			if anyElem == nil {
+				anyElem = finfo
+			}
+		}
+	}
+
+	var saved []byte
+	if innerxml != nil && !isSelfClosing(start) {
+		d.saved = append(d.saved, &saved)
+	}
+	var text, comments []byte
+	err = d.content(start, func(ev Event) (bool, error) {
+		var err error
+		switch ev.Type() {
+		case EventStart:
+			consumed, err := d.unmarshalPath(tinfo, val, nil, ev.Bytes)
+			if err != nil || consumed {
+				return true, err
+			}
+			if anyElem != nil {
+				return true, d.unmarshal(anyElem.valueAlloc(val), ev.Bytes)
+			}
+		case EventText, EventEntityRef, EventCData:
+			if chardata != nil {
+				text, err = d.appendCharData(text, ev)
+			}
+		case EventComment:
+			if comment != nil {
+				comments = append(comments, trim(ev.Bytes, "<!--", "-->")...)
+			}
+		}
+		return false, err
+	})
+	if innerxml != nil && !isSelfClosing(start) {
+		d.saved = d.saved[:len(d.saved)-1]
+		if err == nil {
+			// Drop the end tag of the element.
+			saved = saved[:bytes.LastIndexByte(saved, '<')]
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if chardata != nil {
+		if err := d.setValue(chardata.valueAlloc(val), text, chardata.xsd); err != nil {
+			return err
+		}
+	}
+	if comment != nil {
+		if err := setValue(comment.valueAlloc(val), comments); err != nil {
+			return err
+		}
+	}
+	if innerxml != nil {
+		if err := setValue(innerxml.valueAlloc(val), saved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalPath decodes the element started by start into the field of val
+// whose path it is, given the names of the elements on the way from val,
+// which match the leading parents of the field. It reports whether the
+// element was consumed.
+func (d *decodeState) unmarshalPath(tinfo *typeInfo, val reflect.Value, path []string, start []byte) (bool, error) {
+	uri, local := d.name(start)
+	recurse := false
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		if finfo.flags&fElement == 0 || len(finfo.parents) < len(path) || finfo.xmlns != "" && finfo.xmlns != uri {
+			continue
+		}
+		if !matchSteps(finfo.parents[:len(path)], path) {
+			continue
+		}
+		if len(finfo.parents) == len(path) {
+			if matchStep(finfo.name, local) {
+				return true, d.unmarshalXSD(finfo.valueAlloc(val), start, finfo.xsd)
+			}
+		} else if matchStep(finfo.parents[len(path)], local) {
+			recurse = true
+		}
+	}
+	if !recurse {
+		return false, nil
+	}
+	path = append(path[:len(path):len(path)], string(local))
+	return true, d.content(start, func(ev Event) (bool, error) {
+		if ev.Type() != EventStart {
+			return false, nil
+		}
+		return d.unmarshalPath(tinfo, val, path, ev.Bytes)
+	})
+}
+
+// matchStep reports whether the element name matches a step of a field
+// path, where "*" matches any name.
+func matchStep(step string, name []byte) bool {
+	return step == "*" || step == string(name)
+}
+
+func matchSteps(steps, names []string) bool {
+	for i, step := range steps {
+		if step != "*" && step != names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *decodeState) unmarshalAttrs(tinfo *typeInfo, val reflect.Value, start []byte) error {
+	var anyAttr *fieldInfo
+	hasAttrs := false
+	for i := range tinfo.fields {
+		switch tinfo.fields[i].flags & fMode {
+		case fAttr:
+			hasAttrs = true
+		case fAny | fAttr:
+			hasAttrs = true
+			if anyAttr == nil {
+				anyAttr = &tinfo.fields[i]
+			}
+		}
+	}
+	if !hasAttrs {
+		return nil
+	}
+	_, b := Name(start)
+	for len(b) > 0 {
+		attr, rest, err := NextAttribute(b)
+		if err != nil {
+			return err
+		}
+		if len(attr.Key) == 0 {
+			break
+		}
+		b = rest
+		if len(attr.Value) < 2 {
+			return fmt.Errorf("gosax: attribute %s without value", attr.Key)
+		}
+		prefix, local := splitQName(attr.Key)
+		var uri string
+		if len(prefix) > 0 {
+			uri, _ = d.ns.lookup(string(prefix))
+		}
+		value, err := UnescapeAttr(bytes.Clone(attr.Value[1 : len(attr.Value)-1]))
+		if err != nil {
+			return err
+		}
+		var finfo *fieldInfo
+		for i := range tinfo.fields {
+			f := &tinfo.fields[i]
+			if f.flags&fMode == fAttr && f.name == string(local) && (f.xmlns == "" || f.xmlns == uri) {
+				finfo = f
+				break
+			}
+		}
+		if finfo == nil {
+			if anyAttr == nil {
+				continue
+			}
+			finfo = anyAttr
+		}
+		name := xml.Name{Space: uri, Local: string(local)}
+		if _, ok := nsDecl(attr.Key); ok {
+			name = xml.Name{Space: string(prefix), Local: string(local)}
+		}
+		if err := d.setAttr(finfo.valueAlloc(val), name, value, finfo.xsd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decodeState) setAttr(val reflect.Value, name xml.Name, value []byte, xsd string) error {
+	if val.Type() == attrType {
+		val.Set(reflect.ValueOf(xml.Attr{Name: name, Value: string(value)}))
+		return nil
+	}
+	if val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	if fn := d.hooks.lookup(val.Type(), xsd); fn != nil {
+		return fn(val, value)
+	}
+	if val.CanAddr() {
+		pv := val.Addr()
+		if pv.Type().Implements(unmarshalerAttrType) {
+			return pv.Interface().(xml.UnmarshalerAttr).UnmarshalXMLAttr(xml.Attr{Name: name, Value: string(value)})
+		}
+		if pv.Type().Implements(textUnmarshalerType) {
+			return pv.Interface().(encoding.TextUnmarshaler).UnmarshalText(value)
+		}
+	}
+	if val.Kind() == reflect.Slice && val.Type().Elem().Kind() != reflect.Uint8 {
+		n := val.Len()
+		val.Grow(1)
+		val.SetLen(n + 1)
+		if err := d.setAttr(val.Index(n), name, value, xsd); err != nil {
+			val.SetLen(n)
+			return err
+		}
+		return nil
+	}
+	return setValue(val, value)
+}
+
+// setValue is setValue for a field tagged with the XSD type xsd.
+func (d *decodeState) setValue(val reflect.Value, b []byte, xsd string) error {
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	if fn := d.hooks.lookup(val.Type(), xsd); fn != nil {
+		return fn(val, b)
+	}
+	return setValue(val, b)
+}
+
+// setValue stores the text b in val.
+func setValue(val reflect.Value, b []byte) error {
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	if val.CanAddr() {
+		if tu, ok := val.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText(b)
+		}
+	}
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if len(trimSpace(b)) == 0 {
+			val.SetInt(0)
+			return nil
+		}
+		x, err := ParseInt(b, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if len(trimSpace(b)) == 0 {
+			val.SetUint(0)
+			return nil
+		}
+		x, err := ParseUint(b, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		if len(trimSpace(b)) == 0 {
+			val.SetFloat(0)
+			return nil
+		}
+		x, err := ParseFloat(b, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetFloat(x)
+	case reflect.Bool:
+		if len(trimSpace(b)) == 0 {
+			val.SetBool(false)
+			return nil
+		}
+		x, err := ParseBool(b)
+		if err != nil {
+			return err
+		}
+		val.SetBool(x)
+	case reflect.String:
+		val.SetString(string(b))
+	case reflect.Slice:
+		if val.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("gosax: cannot unmarshal into %s", val.Type())
+		}
+		if len(b) == 0 {
+			val.SetBytes(nil)
+		} else {
+			val.SetBytes(bytes.Clone(b))
+		}
+	default:
+		return fmt.Errorf("gosax: cannot unmarshal into %s", val.Type())
+	}
+	return nil
+}
+
+// unmarshalInterface decodes the element started by start with the
+// UnmarshalXML method of u, through an encoding/xml Decoder reading the
+// element's events.
+func (d *decodeState) unmarshalInterface(u xml.Unmarshaler, start []byte) error {
+	se, err := StartElement(start)
+	if err != nil {
+		return err
+	}
+	tr := &tokenReader{d: d, start: se, selfClosing: isSelfClosing(start)}
+	dec := xml.NewTokenDecoder(tr)
+	// Let dec see the start element, so that it accepts its end element.
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if err := u.UnmarshalXML(dec, t.(xml.StartElement)); err != nil {
+		return err
+	}
+	if !tr.done {
+		return d.skipTo(tr.depth)
+	}
+	return nil
+}
+
+// skipTo consumes events until depth nested elements have been closed.
+func (d *decodeState) skipTo(depth int) error {
+	for depth > 0 {
+		ev, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch ev.Type() {
+		case EventStart:
+			if !isSelfClosing(ev.Bytes) {
+				depth++
+			}
+		case EventEnd:
+			if !isSelfClosing(ev.Bytes) {
+				depth--
+			}
+		case EventEOF:
+			return io.ErrUnexpectedEOF
+		}
+	}
+	return nil
+}
+
+// tokenReader is an xml.TokenReader of the element started by start.
+type tokenReader struct {
+	d           *decodeState
+	start       xml.StartElement
+	selfClosing bool
+	started     bool
+	depth       int
+	done        bool
+	pending     *xml.EndElement
+}
+
+func (t *tokenReader) Token() (xml.Token, error) {
+	if t.done {
+		return nil, io.EOF
+	}
+	if t.pending != nil {
+		end := *t.pending
+		t.pending = nil
+		return end, nil
+	}
+	if !t.started {
+		t.started = true
+		if t.selfClosing {
+			if t.d.r.EmitSelfClosingTag {
+				if _, err := t.d.next(); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			t.depth = 1
+		}
+		return t.start, nil
+	}
+	if t.depth == 0 {
+		t.done = true
+		return xml.EndElement{Name: t.start.Name}, nil
+	}
+	for {
+		ev, err := t.d.next()
+		if err != nil {
+			return nil, err
+		}
+		switch ev.Type() {
+		case EventStart:
+			if !isSelfClosing(ev.Bytes) {
+				t.depth++
+			} else if !t.d.r.EmitSelfClosingTag {
+				// Report the end of the self-closing tag on the next call.
+				se, err := StartElement(ev.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				t.pending = &xml.EndElement{Name: se.Name}
+				return se, nil
+			}
+		case EventEnd:
+			if !isSelfClosing(ev.Bytes) {
+				t.depth--
+				if t.depth == 0 {
+					t.done = true
+				}
+			}
+		case EventEOF:
+			return nil, io.ErrUnexpectedEOF
+		}
+		tok, err := Token(ev)
+		if err != nil {
+			return nil, err
+		}
+		return xml.CopyToken(tok), nil
+	}
+}
+
+func nsOrNone(ns string) string {
+	if ns == "" {
+		return "no name space"
+	}
+	return ns
+}
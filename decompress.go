@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Decompressor wraps a compressed stream with a reader of its contents.
+type Decompressor func(r io.Reader) (io.Reader, error)
+
+type decompressor struct {
+	magic []byte
+	fn    Decompressor
+}
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = []decompressor{
+		{magic: []byte("\x1f\x8b"), fn: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{magic: []byte("BZh"), fn: func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil }},
+	}
+)
+
+// compressedBufSize is the read size used on the compressed side. The
+// decompressed side is read by the Reader's own buffer.
+const compressedBufSize = 256 * 1024
+
+// RegisterDecompressor registers fn for streams starting with magic, so that
+// NewReaderAuto and Decompress recognize it. It is typically called from an
+// init function, e.g. for zstd ("\x28\xb5\x2f\xfd"). A later registration
+// with the same magic replaces the earlier one.
+func RegisterDecompressor(magic string, fn Decompressor) {
+	if magic == "" {
+		panic("gosax: RegisterDecompressor with empty magic")
+	}
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	for i, d := range decompressors {
+		if string(d.magic) == magic {
+			decompressors[i].fn = fn
+			return
+		}
+	}
+	decompressors = append(decompressors, decompressor{magic: []byte(magic), fn: fn})
+}
+
+// Decompress sniffs the magic bytes of r and returns a reader of the
+// decompressed contents. Input with no known magic is returned as is.
+func Decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, compressedBufSize)
+	decompressorsMu.RLock()
+	n := 0
+	for _, d := range decompressors {
+		n = max(n, len(d.magic))
+	}
+	decompressorsMu.RUnlock()
+	head, err := br.Peek(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	decompressorsMu.RLock()
+	var fn Decompressor
+	for _, d := range decompressors {
+		if bytes.HasPrefix(head, d.magic) {
+			fn = d.fn
+			break
+		}
+	}
+	decompressorsMu.RUnlock()
+	if fn == nil {
+		return br, nil
+	}
+	return fn(br)
+}
+
+// NewReaderAuto is like NewReader, but transparently decompresses gzip and
+// bzip2 input, and any format added with RegisterDecompressor.
+func NewReaderAuto(r io.Reader) (*Reader, error) {
+	dr, err := Decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(dr), nil
+}
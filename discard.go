@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "io"
+
+// Discard skips the next n events and returns the number of events
+// skipped. If the document ends first, it returns io.EOF with the number of
+// events before EventEOF.
+func (r *Reader) Discard(n int) (int, error) {
+	for i := 0; i < n; i++ {
+		// Event takes its fast path itself when no option needs more.
+		ev, err := r.Event()
+		if err != nil {
+			return i, err
+		}
+		if ev.Type() == EventEOF {
+			return i, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// DiscardUntilEnd skips to the end tag of the element the Reader is in,
+// which is the element started by the last event if it is a start tag.
+// The end tag is the last event read. Events in between are not
+// postprocessed and not reported to the Observer.
+func (r *Reader) DiscardUntilEnd() error {
+	if r.last.Type() == EventStart && isSelfClosing(r.last.Bytes) && r.EmitSelfClosingTag {
+		if _, err := r.Event(); err != nil {
+			return err
+		}
+	}
+	return r.skipToEnd()
+}
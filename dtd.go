@@ -0,0 +1,574 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DTD holds the declarations of a document type declaration that gosax
+// uses: attribute list and general entity declarations of the internal
+// subset. External subsets are not read.
+type DTD struct {
+	// Name is the name of the document element.
+	Name string
+	// Attlists maps element names to their attribute definitions.
+	Attlists map[string][]AttDef
+	// Entities maps the names of internal general entities to their
+	// replacement text.
+	Entities map[string]string
+	// ExternalEntities maps the names of external general entities to
+	// their system identifiers.
+	ExternalEntities map[string]string
+}
+
+// AttDef is an attribute definition of an ATTLIST declaration.
+type AttDef struct {
+	Name string
+	// Type is the attribute type, such as CDATA, ID or IDREFS.
+	// Enumerated types are given as written, such as "(a|b)" or
+	// "NOTATION (a|b)".
+	Type string
+	// Default is #REQUIRED, #IMPLIED, #FIXED or empty.
+	Default string
+	// Value is the default value, if any, with character and predefined
+	// entity references expanded.
+	Value    string
+	HasValue bool
+
+	// literal is the default value as written in the DTD.
+	literal []byte
+}
+
+// ParseDTD parses the document type declaration b, as returned by an
+// EventDocType event.
+func ParseDTD(b []byte) (*DTD, error) {
+	b = trim(b, "<!", ">")
+	if len(b) < 7 || !bytes.EqualFold(b[:7], []byte("DOCTYPE")) {
+		return nil, fmt.Errorf("gosax: not a document type declaration: %q", b)
+	}
+	p := &dtdParser{b: b[7:]}
+	d := &DTD{
+		Attlists:         make(map[string][]AttDef),
+		Entities:         make(map[string]string),
+		ExternalEntities: make(map[string]string),
+	}
+	p.space()
+	d.Name = string(p.name())
+	i := bytes.IndexByte(p.b, '[')
+	if i < 0 {
+		return d, nil
+	}
+	// Skip the external ID, whose literals cannot contain '['.
+	p.b = p.b[i+1:]
+	for {
+		p.space()
+		switch {
+		case len(p.b) == 0:
+			return nil, fmt.Errorf("gosax: unterminated internal subset")
+		case p.b[0] == ']':
+			return d, nil
+		case bytes.HasPrefix(p.b, []byte("<!--")):
+			j := bytes.Index(p.b[4:], []byte("-->"))
+			if j < 0 {
+				return nil, fmt.Errorf("gosax: unterminated comment in DTD")
+			}
+			p.b = p.b[4+j+3:]
+		case bytes.HasPrefix(p.b, []byte("<?")):
+			j := bytes.Index(p.b, []byte("?>"))
+			if j < 0 {
+				return nil, fmt.Errorf("gosax: unterminated processing instruction in DTD")
+			}
+			p.b = p.b[j+2:]
+		case p.b[0] == '%':
+			// Parameter entity references are not expanded.
+			j := bytes.IndexByte(p.b, ';')
+			if j < 0 {
+				return nil, fmt.Errorf("gosax: invalid parameter entity reference in DTD")
+			}
+			p.b = p.b[j+1:]
+		case bytes.HasPrefix(p.b, []byte("<!ATTLIST")):
+			p.b = p.b[len("<!ATTLIST"):]
+			if err := p.attlist(d); err != nil {
+				return nil, err
+			}
+		case bytes.HasPrefix(p.b, []byte("<!ENTITY")):
+			p.b = p.b[len("<!ENTITY"):]
+			if err := p.entity(d); err != nil {
+				return nil, err
+			}
+		case bytes.HasPrefix(p.b, []byte("<!")):
+			// ELEMENT and NOTATION declarations are skipped.
+			if err := p.skipDecl(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("gosax: invalid markup in DTD: %.20q", p.b)
+		}
+	}
+}
+
+type dtdParser struct {
+	b []byte
+}
+
+func (p *dtdParser) space() {
+	for len(p.b) > 0 && whitespace[p.b[0]] {
+		p.b = p.b[1:]
+	}
+}
+
+// name consumes a name or name token.
+func (p *dtdParser) name() []byte {
+	i := 0
+	for i < len(p.b) && !whitespace[p.b[i]] && bytes.IndexByte([]byte(`>[]()|"'`), p.b[i]) < 0 {
+		i++
+	}
+	n := p.b[:i]
+	p.b = p.b[i:]
+	return n
+}
+
+// literal consumes a quoted literal and returns its content.
+func (p *dtdParser) literal() ([]byte, error) {
+	if len(p.b) == 0 || (p.b[0] != '"' && p.b[0] != '\'') {
+		return nil, fmt.Errorf("gosax: expected literal in DTD: %.20q", p.b)
+	}
+	j := bytes.IndexByte(p.b[1:], p.b[0])
+	if j < 0 {
+		return nil, fmt.Errorf("gosax: unterminated literal in DTD")
+	}
+	v := p.b[1 : 1+j]
+	p.b = p.b[1+j+1:]
+	return v, nil
+}
+
+// end consumes the '>' closing a declaration.
+func (p *dtdParser) end() error {
+	p.space()
+	if len(p.b) == 0 || p.b[0] != '>' {
+		return fmt.Errorf("gosax: expected '>' in DTD: %.20q", p.b)
+	}
+	p.b = p.b[1:]
+	return nil
+}
+
+func (p *dtdParser) skipDecl() error {
+	for i := 0; i < len(p.b); i++ {
+		switch p.b[i] {
+		case '"', '\'':
+			j := bytes.IndexByte(p.b[i+1:], p.b[i])
+			if j < 0 {
+				return fmt.Errorf("gosax: unterminated literal in DTD")
+			}
+			i += j + 1
+		case '>':
+			p.b = p.b[i+1:]
+			return nil
+		}
+	}
+	return fmt.Errorf("gosax: unterminated declaration in DTD")
+}
+
+func (p *dtdParser) attlist(d *DTD) error {
+	p.space()
+	elem := string(p.name())
+	for {
+		p.space()
+		if len(p.b) > 0 && p.b[0] == '>' {
+			p.b = p.b[1:]
+			return nil
+		}
+		var def AttDef
+		def.Name = string(p.name())
+		if def.Name == "" {
+			return fmt.Errorf("gosax: invalid ATTLIST declaration for %s", elem)
+		}
+		p.space()
+		start := p.b
+		if bytes.HasPrefix(p.b, []byte("NOTATION")) {
+			p.b = p.b[len("NOTATION"):]
+			p.space()
+		}
+		if len(p.b) > 0 && p.b[0] == '(' {
+			j := bytes.IndexByte(p.b, ')')
+			if j < 0 {
+				return fmt.Errorf("gosax: unterminated enumeration in ATTLIST %s", elem)
+			}
+			p.b = p.b[j+1:]
+		} else {
+			p.name()
+		}
+		def.Type = string(start[:len(start)-len(p.b)])
+		p.space()
+		if len(p.b) > 0 && p.b[0] == '#' {
+			def.Default = string(p.name())
+			p.space()
+		}
+		if def.Default == "" || def.Default == "#FIXED" {
+			v, err := p.literal()
+			if err != nil {
+				return err
+			}
+			v = bytes.Clone(v)
+			u, err := UnescapeAttr(bytes.Clone(v))
+			if err != nil {
+				// Keep references to entities declared in the DTD as written.
+				u = v
+			}
+			def.Value = string(u)
+			def.HasValue = true
+			def.literal = v
+		}
+		// The first declaration of an attribute is binding.
+		dup := false
+		for _, a := range d.Attlists[elem] {
+			if a.Name == def.Name {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			d.Attlists[elem] = append(d.Attlists[elem], def)
+		}
+	}
+}
+
+func (p *dtdParser) entity(d *DTD) error {
+	p.space()
+	param := false
+	if len(p.b) > 0 && p.b[0] == '%' {
+		param = true
+		p.b = p.b[1:]
+		p.space()
+	}
+	name := string(p.name())
+	p.space()
+	if len(p.b) > 0 && (p.b[0] == '"' || p.b[0] == '\'') {
+		v, err := p.literal()
+		if err != nil {
+			return err
+		}
+		if _, ok := d.Entities[name]; !ok && !param && !isExternal(d, name) {
+			d.Entities[name] = string(v)
+		}
+		return p.end()
+	}
+	if !param {
+		if _, ok := d.Entities[name]; !ok && !isExternal(d, name) {
+			d.ExternalEntities[name] = p.systemID()
+		}
+	}
+	return p.skipDecl()
+}
+
+func isExternal(d *DTD, name string) bool {
+	_, ok := d.ExternalEntities[name]
+	return ok
+}
+
+// systemID returns the system literal of the external ID that follows,
+// without consuming it.
+func (p *dtdParser) systemID() string {
+	q := *p
+	keyword := string(q.name())
+	q.space()
+	if keyword == "PUBLIC" {
+		if _, err := q.literal(); err != nil {
+			return ""
+		}
+		q.space()
+	} else if keyword != "SYSTEM" {
+		return ""
+	}
+	v, err := q.literal()
+	if err != nil {
+		return ""
+	}
+	return string(v)
+}
+
+// AttDef returns the definition of the attribute attr of the element elem.
+func (d *DTD) AttDef(elem, attr string) (AttDef, bool) {
+	for _, def := range d.Attlists[elem] {
+		if def.Name == attr {
+			return def, true
+		}
+	}
+	return AttDef{}, false
+}
+
+// Tokenized reports whether the attribute type is not CDATA, so that its
+// values are normalized further.
+func (a AttDef) Tokenized() bool {
+	return a.Type != "CDATA"
+}
+
+// NormalizeAttr applies the normalization of the attribute attr of the
+// element elem to its unescaped value, as returned by UnescapeAttr, in
+// place: the values of tokenized attributes lose leading and trailing
+// spaces, and runs of spaces are collapsed into one.
+func (d *DTD) NormalizeAttr(elem, attr string, value []byte) []byte {
+	if def, ok := d.AttDef(elem, attr); ok && def.Tokenized() {
+		return collapseSpaces(value[:0], value, false)
+	}
+	return value
+}
+
+// collapseSpaces appends v to dst with leading and trailing spaces removed
+// and runs of spaces collapsed into one. If raw is set, v is an attribute
+// value as written: line breaks, tabs and references to a space count as
+// spaces too.
+func collapseSpaces(dst, v []byte, raw bool) []byte {
+	space := false
+	start := len(dst)
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c == ' ' || raw && whitespace[c] {
+			space = true
+			continue
+		}
+		if raw {
+			if n := spaceRef(v[i:]); n > 0 {
+				space = true
+				i += n - 1
+				continue
+			}
+		}
+		if space && len(dst) > start {
+			dst = append(dst, ' ')
+		}
+		space = false
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// spaceRef returns the length of the character reference to a space at the
+// start of v, or 0.
+func spaceRef(v []byte) int {
+	if len(v) < 4 || v[0] != '&' || v[1] != '#' {
+		return 0
+	}
+	end := bytes.IndexByte(v, ';')
+	if end < 3 {
+		return 0
+	}
+	if r, err := charRef(v[1:end]); err != nil || r != ' ' {
+		return 0
+	}
+	return end + 1
+}
+
+// needsCollapse reports whether collapseSpaces changes the raw value v.
+func needsCollapse(v []byte) bool {
+	for i, c := range v {
+		if whitespace[c] && (c != ' ' || i == 0 || i == len(v)-1 || v[i+1] == ' ') {
+			return true
+		}
+		if c == '&' && spaceRef(v[i:]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// appendStartTag appends the start tag b to dst, with the values of the
+// tokenized attributes normalized if normalize is set, and with the default
+// attributes of its element that b does not specify if defaults is set. It
+// reports whether the tag changed.
+func (d *DTD) appendStartTag(dst, b []byte, defaults, normalize bool) ([]byte, bool, error) {
+	name, rest := Name(b)
+	defs := d.Attlists[string(name)]
+	if len(defs) == 0 {
+		return dst, false, nil
+	}
+	start := len(dst)
+	last := 0
+	for attrs := rest; normalize && len(attrs) > 0; {
+		attr, next, err := NextAttribute(attrs)
+		if err != nil {
+			return dst[:start], false, err
+		}
+		attrs = next
+		if len(attr.Key) == 0 {
+			break
+		}
+		if len(attr.Value) < 2 {
+			continue
+		}
+		v := attr.Value[1 : len(attr.Value)-1]
+		if def, ok := d.AttDef(string(name), string(attr.Key)); !ok || !def.Tokenized() || !needsCollapse(v) {
+			continue
+		}
+		i := cap(b) - cap(v)
+		dst = append(dst, b[last:i]...)
+		dst = collapseSpaces(dst, v, true)
+		last = i + len(v)
+	}
+	var missing []int
+	for i, def := range defs {
+		if !defaults || !def.HasValue {
+			continue
+		}
+		found := false
+		for attrs := rest; len(attrs) > 0; {
+			attr, next, err := NextAttribute(attrs)
+			if err != nil {
+				return dst[:start], false, err
+			}
+			attrs = next
+			if len(attr.Key) == 0 {
+				break
+			}
+			if string(attr.Key) == def.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, i)
+		}
+	}
+	if last == 0 && len(missing) == 0 {
+		return dst, false, nil
+	}
+	end := len(b) - 1
+	if isSelfClosing(b) {
+		end--
+	}
+	dst = append(dst, b[last:end]...)
+	for _, i := range missing {
+		dst = append(dst, ' ')
+		dst = append(dst, defs[i].Name...)
+		dst = append(dst, '=', '"')
+		v := defs[i].literal
+		if normalize && defs[i].Tokenized() {
+			v = collapseSpaces(nil, v, true)
+		}
+		for _, c := range v {
+			if c == '"' {
+				dst = append(dst, "&quot;"...)
+			} else {
+				dst = append(dst, c)
+			}
+		}
+		dst = append(dst, '"')
+	}
+	return append(dst, b[end:]...), true, nil
+}
+
+// ExpandEntity returns the replacement text of the internal general entity
+// name, with the references to other internal entities in it expanded in
+// turn. Character and predefined entity references are kept as written.
+// It fails with a *LimitError once the expansion exceeds
+// l.MaxEntityExpansion bytes, and for recursive references or references
+// to entities that are not internal.
+func (d *DTD) ExpandEntity(name string, l Limits) (string, error) {
+	x := entityExpander{entities: d.Entities, max: l.resolve().MaxEntityExpansion}
+	b, err := x.expand(nil, name)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// An entityExpander expands references to internal entities. It counts the
+// bytes produced across calls, so that the total expansion of a document
+// is bounded whatever the nesting of its entities.
+type entityExpander struct {
+	entities map[string]string
+	// max is the maximum of n, or negative for no limit.
+	max int
+	n   int
+	// open holds the names of the entities being expanded.
+	open []string
+}
+
+// expand appends the expansion of the entity name to dst.
+func (x *entityExpander) expand(dst []byte, name string) ([]byte, error) {
+	v, ok := x.entities[name]
+	if !ok {
+		return nil, fmt.Errorf("gosax: reference to undeclared or external entity &%s;", name)
+	}
+	for _, o := range x.open {
+		if o == name {
+			return nil, fmt.Errorf("gosax: recursive reference to entity &%s;", name)
+		}
+	}
+	// Each reference counts as a byte, so that entities expanding to
+	// nothing cannot be nested into unbounded work.
+	if err := x.count(1); err != nil {
+		return nil, err
+	}
+	x.open = append(x.open, name)
+	defer func() { x.open = x.open[:len(x.open)-1] }()
+	for len(v) > 0 {
+		i := strings.IndexByte(v, '&')
+		if i != 0 {
+			if i < 0 {
+				i = len(v)
+			}
+			if err := x.count(i); err != nil {
+				return nil, err
+			}
+			dst = append(dst, v[:i]...)
+			v = v[i:]
+			continue
+		}
+		end := refEnd(unsafeBytes(v[1:]))
+		if end <= 0 || v[1] == '#' || predefinedEntity(unsafeBytes(v[1:1+end])) != 0 {
+			n := 1
+			if end > 0 {
+				n = end + 2
+			}
+			if err := x.count(n); err != nil {
+				return nil, err
+			}
+			dst = append(dst, v[:n]...)
+			v = v[n:]
+			continue
+		}
+		var err error
+		dst, err = x.expand(dst, v[1:1+end])
+		if err != nil {
+			return nil, err
+		}
+		v = v[end+2:]
+	}
+	return dst, nil
+}
+
+func (x *entityExpander) count(n int) error {
+	x.n += n
+	if x.max >= 0 && x.n > x.max {
+		return &LimitError{Limit: "MaxEntityExpansion", Max: int64(x.max), Offset: -1}
+	}
+	return nil
+}
@@ -0,0 +1,527 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Marshal returns the XML encoding of v.
+// It follows the same struct tag conventions as encoding/xml.Marshal.
+func Marshal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	if err := NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// An Encoder writes Go values as XML to an output stream.
+type Encoder struct {
+	w *Writer
+}
+
+// NewEncoder returns a new Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: NewWriter(w)}
+}
+
+// Writer returns the Writer the Encoder writes to, so that encoded values
+// can be interleaved with events and hand-written markup.
+func (e *Encoder) Writer() *Writer {
+	return e.w
+}
+
+// Encode writes the XML encoding of v and flushes the output.
+func (e *Encoder) Encode(v any) error {
+	if err := e.w.Encode(v); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// Encode writes the XML encoding of v.
+//
+// Struct fields are mapped with the same tags as encoding/xml.Marshal:
+// element names, "a>b" parent chains, attr, chardata, cdata, innerxml,
+// comment, any, omitempty and XMLName fields.
+// Types implementing xml.Marshaler, xml.MarshalerAttr or
+// encoding.TextMarshaler are encoded with their own methods.
+func (w *Writer) Encode(v any) error {
+	s := encodeState{w: w}
+	return s.marshalValue(reflect.ValueOf(v), nil, "", "")
+}
+
+type encodeState struct {
+	w  *Writer
+	ns []string
+	// prefixes holds the prefixes declared for attributes.
+	prefixes nsScope
+}
+
+func (s *encodeState) marshalValue(val reflect.Value, finfo *fieldInfo, xmlns, name string) error {
+	if !val.IsValid() {
+		return nil
+	}
+	if finfo != nil && finfo.flags&fOmitEmpty != 0 && isEmptyValue(val) {
+		return nil
+	}
+	for val.Kind() == reflect.Interface || val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	typ := val.Type()
+
+	if m, ok := implements[xml.Marshaler](val); ok {
+		xmlns, name = elementName(typ, finfo, xmlns, name)
+		return s.marshalInterface(m, xml.StartElement{Name: xml.Name{Space: xmlns, Local: name}})
+	}
+	if tm, ok := implements[encoding.TextMarshaler](val); ok {
+		xmlns, name = elementName(typ, finfo, xmlns, name)
+		if name == "" {
+			return fmt.Errorf("gosax: unsupported type: %s", typ)
+		}
+		b, err := tm.MarshalText()
+		if err != nil {
+			return err
+		}
+		if err := s.writeStart(xmlns, name); err != nil {
+			return err
+		}
+		if err := s.w.Text(b); err != nil {
+			return err
+		}
+		return s.writeEnd()
+	}
+
+	kind := val.Kind()
+	if (kind == reflect.Slice || kind == reflect.Array) && typ.Elem().Kind() != reflect.Uint8 {
+		for i, n := 0, val.Len(); i < n; i++ {
+			if err := s.marshalValue(val.Index(i), finfo, xmlns, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tinfo, err := getTypeInfo(typ)
+	if err != nil {
+		return err
+	}
+	if name == "" && tinfo.xmlname != nil {
+		if tinfo.xmlname.name != "" {
+			xmlns, name = tinfo.xmlname.xmlns, tinfo.xmlname.name
+		} else if n, ok := tinfo.xmlname.value(val).Interface().(xml.Name); ok && n.Local != "" {
+			xmlns, name = n.Space, n.Local
+		}
+	}
+	xmlns, name = elementName(typ, finfo, xmlns, name)
+	if name == "" {
+		return fmt.Errorf("gosax: unsupported type: %s", typ)
+	}
+
+	if err := s.writeStart(xmlns, name); err != nil {
+		return err
+	}
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		if finfo.flags&fAttr == 0 {
+			continue
+		}
+		fv := finfo.value(val)
+		if !fv.IsValid() || finfo.flags&fOmitEmpty != 0 && isEmptyValue(fv) {
+			continue
+		}
+		if err := s.marshalAttr(finfo.xmlns, finfo.name, fv); err != nil {
+			return err
+		}
+	}
+	if kind == reflect.Struct {
+		if err := s.marshalStruct(tinfo, val); err != nil {
+			return err
+		}
+	} else {
+		b, err := appendSimple(nil, val)
+		if err != nil {
+			return err
+		}
+		if err := s.w.Text(b); err != nil {
+			return err
+		}
+	}
+	return s.writeEnd()
+}
+
+// elementName applies the remaining precedence rules for element names:
+// an explicit name, the field name or tag, and finally the type name.
+func elementName(typ reflect.Type, finfo *fieldInfo, xmlns, name string) (string, string) {
+	if name == "" && finfo != nil {
+		xmlns, name = finfo.xmlns, finfo.name
+	}
+	if name == "" {
+		name = typ.Name()
+		if i := strings.IndexByte(name, '['); i >= 0 {
+			name = name[:i]
+		}
+	}
+	return xmlns, name
+}
+
+func (s *encodeState) writeStart(xmlns, name string) error {
+	if err := s.w.StartElement([]byte(name)); err != nil {
+		return err
+	}
+	cur := ""
+	if len(s.ns) > 0 {
+		cur = s.ns[len(s.ns)-1]
+	}
+	if xmlns != "" && xmlns != cur {
+		if err := s.w.Attr([]byte("xmlns"), []byte(xmlns)); err != nil {
+			return err
+		}
+		cur = xmlns
+	}
+	s.ns = append(s.ns, cur)
+	s.prefixes.push()
+	return nil
+}
+
+func (s *encodeState) writeEnd() error {
+	s.ns = s.ns[:len(s.ns)-1]
+	s.prefixes.pop()
+	return s.w.EndElement()
+}
+
+func (s *encodeState) marshalAttr(xmlns, name string, val reflect.Value) error {
+	for val.Kind() == reflect.Interface || val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Type() == attrType {
+		attr := val.Interface().(xml.Attr)
+		if attr.Name.Local == "" {
+			return nil
+		}
+		return s.writeAttr(attr.Name.Space, attr.Name.Local, []byte(attr.Value))
+	}
+	if m, ok := implements[xml.MarshalerAttr](val); ok {
+		attr, err := m.MarshalXMLAttr(xml.Name{Space: xmlns, Local: name})
+		if err != nil {
+			return err
+		}
+		if attr.Name.Local == "" {
+			return nil
+		}
+		return s.writeAttr(attr.Name.Space, attr.Name.Local, []byte(attr.Value))
+	}
+	if tm, ok := implements[encoding.TextMarshaler](val); ok {
+		b, err := tm.MarshalText()
+		if err != nil {
+			return err
+		}
+		return s.writeAttr(xmlns, name, b)
+	}
+	if (val.Kind() == reflect.Slice || val.Kind() == reflect.Array) && val.Type().Elem().Kind() != reflect.Uint8 {
+		for i, n := 0, val.Len(); i < n; i++ {
+			if err := s.marshalAttr(xmlns, name, val.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	b, err := appendSimple(nil, val)
+	if err != nil {
+		return err
+	}
+	return s.writeAttr(xmlns, name, b)
+}
+
+func (s *encodeState) writeAttr(xmlns, name string, value []byte) error {
+	switch xmlns {
+	case "":
+		return s.w.Attr([]byte(name), value)
+	case xmlURL:
+		return s.w.Attr([]byte("xml:"+name), value)
+	}
+	prefix, ok := s.prefix(xmlns)
+	if !ok {
+		if err := s.w.Attr([]byte("xmlns:"+prefix), []byte(xmlns)); err != nil {
+			return err
+		}
+		s.prefixes.declare(prefix, xmlns)
+	}
+	return s.w.Attr([]byte(prefix+":"+name), value)
+}
+
+// prefix returns the prefix bound to uri in scope and true, or else a
+// prefix not in use to declare for it.
+func (s *encodeState) prefix(uri string) (string, bool) {
+	for _, b := range s.prefixes.bindings {
+		if b.uri == uri {
+			return b.prefix, true
+		}
+	}
+	base := nsPrefix(uri)
+	prefix := base
+	for n := 1; ; n++ {
+		if _, ok := s.prefixes.lookup(prefix); !ok {
+			return prefix, false
+		}
+		prefix = base + "_" + strconv.Itoa(n)
+	}
+}
+
+// nsPrefix derives a prefix from the last path segment of a namespace URI.
+func nsPrefix(uri string) string {
+	uri = strings.TrimRight(uri, "/")
+	if i := strings.LastIndexAny(uri, "/:"); i >= 0 {
+		uri = uri[i+1:]
+	}
+	if uri == "" || !isNameString(uri) || strings.HasPrefix(strings.ToLower(uri), "xml") {
+		return "ns"
+	}
+	return uri
+}
+
+func isNameString(s string) bool {
+	for i, c := range []byte(s) {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func (s *encodeState) marshalStruct(tinfo *typeInfo, val reflect.Value) error {
+	var parents []string
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		if finfo.flags&fAttr != 0 {
+			continue
+		}
+		fv := finfo.value(val)
+		if !fv.IsValid() {
+			continue
+		}
+		switch finfo.flags & fMode {
+		case fCDATA, fCharData:
+			for fv.Kind() == reflect.Interface || fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			var b []byte
+			var err error
+			if tm, ok := implements[encoding.TextMarshaler](fv); ok {
+				b, err = tm.MarshalText()
+			} else if fv.Kind() != reflect.Pointer && fv.Kind() != reflect.Interface {
+				b, err = appendSimple(nil, fv)
+			}
+			if err != nil {
+				return err
+			}
+			if finfo.flags&fMode == fCDATA {
+				err = s.w.CData(b)
+			} else {
+				err = s.w.Text(b)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		case fComment:
+			b, err := bytesOf(fv)
+			if err != nil {
+				return fmt.Errorf("gosax: bad type for comment field of %s", val.Type())
+			}
+			if len(b) == 0 {
+				continue
+			}
+			if err := s.w.Comment(b); err != nil {
+				return err
+			}
+			continue
+		case fInnerXML:
+			b, err := bytesOf(fv)
+			if err != nil {
+				// Like encoding/xml, unsupported innerxml types are encoded as elements.
+				break
+			}
+			if err := s.w.Raw(b); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if slices.Contains(finfo.parents, "*") {
+			return fmt.Errorf("gosax: cannot marshal field %s with wildcard path", strings.Join(finfo.parents, ">")+">"+finfo.name)
+		}
+		// element fields: keep shared parent chains open between fields.
+		n := 0
+		for n < len(parents) && n < len(finfo.parents) && parents[n] == finfo.parents[n] {
+			n++
+		}
+		for len(parents) > n {
+			if err := s.writeEnd(); err != nil {
+				return err
+			}
+			parents = parents[:len(parents)-1]
+		}
+		for _, p := range finfo.parents[n:] {
+			if err := s.writeStart(finfo.xmlns, p); err != nil {
+				return err
+			}
+			parents = append(parents, p)
+		}
+		var err error
+		if finfo.flags&fAny != 0 || finfo.name == "*" {
+			err = s.marshalValue(fv, nil, "", "")
+		} else {
+			err = s.marshalValue(fv, finfo, "", "")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for range parents {
+		if err := s.writeEnd(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalInterface lets m write itself through an encoding/xml Encoder
+// whose output is spliced into the Writer.
+func (s *encodeState) marshalInterface(m xml.Marshaler, start xml.StartElement) error {
+	if err := s.w.Raw(nil); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(rawWriter{s.w})
+	if err := m.MarshalXML(enc, start); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("gosax: %T.MarshalXML wrote invalid XML: %w", m, err)
+	}
+	return nil
+}
+
+type rawWriter struct {
+	w *Writer
+}
+
+func (r rawWriter) Write(p []byte) (int, error) {
+	if err := r.w.Raw(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// implements reports whether val or, if addressable, its address implements T.
+func implements[T any](val reflect.Value) (T, bool) {
+	typ := reflect.TypeFor[T]()
+	if val.CanInterface() && val.Type().Implements(typ) {
+		return val.Interface().(T), true
+	}
+	if val.CanAddr() {
+		pv := val.Addr()
+		if pv.CanInterface() && pv.Type().Implements(typ) {
+			return pv.Interface().(T), true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func bytesOf(val reflect.Value) ([]byte, error) {
+	switch {
+	case val.Kind() == reflect.String:
+		return []byte(val.String()), nil
+	case val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8:
+		return val.Bytes(), nil
+	}
+	return nil, fmt.Errorf("gosax: unsupported type: %s", val.Type())
+}
+
+// appendSimple appends the text representation of a basic value to dst.
+func appendSimple(dst []byte, val reflect.Value) ([]byte, error) {
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(dst, val.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(dst, val.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(dst, val.Float(), 'g', -1, val.Type().Bits()), nil
+	case reflect.String:
+		return append(dst, val.String()...), nil
+	case reflect.Bool:
+		return strconv.AppendBool(dst, val.Bool()), nil
+	case reflect.Array:
+		if val.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		for i := 0; i < val.Len(); i++ {
+			dst = append(dst, byte(val.Index(i).Uint()))
+		}
+		return dst, nil
+	case reflect.Slice:
+		if val.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		return append(dst, val.Bytes()...), nil
+	}
+	return nil, fmt.Errorf("gosax: unsupported type: %s", val.Type())
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Entities resolves references to entities other than the predefined ones,
+// such as the HTML entities "nbsp" and "copy", which Unescape rejects.
+type Entities interface {
+	// Entity returns the replacement text of the entity name.
+	Entity(name []byte) (string, bool)
+}
+
+// EntityMap is an Entities mapping entity names to characters.
+type EntityMap map[string]rune
+
+// Entity returns the character of the entity name as a string.
+func (m EntityMap) Entity(name []byte) (string, bool) {
+	c, ok := m[string(name)]
+	if !ok {
+		return "", false
+	}
+	return string(c), true
+}
+
+// EntityFunc is an Entities calling a function.
+type EntityFunc func(name []byte) (string, bool)
+
+// Entity returns f(name).
+func (f EntityFunc) Entity(name []byte) (string, bool) {
+	return f(name)
+}
+
+func resolveEntity(ents Entities, name []byte) (string, bool) {
+	if ents == nil {
+		return "", false
+	}
+	return ents.Entity(name)
+}
+
+// UnescapeEntities is like Unescape, but resolves references to other
+// entities with ents. It works in place unless a replacement text is
+// longer than its reference.
+func UnescapeEntities(b []byte, ents Entities) ([]byte, error) {
+	return unescape(b, ents)
+}
+
+// UnescapeAttrEntities is like UnescapeAttr, but resolves references to
+// other entities with ents, like UnescapeEntities.
+func UnescapeAttrEntities(b []byte, ents Entities) ([]byte, error) {
+	return unescapeAttr(b, ents)
+}
+
+// CharDataEntities is like CharData, but resolves references to other
+// entities with ents.
+func CharDataEntities(b []byte, ents Entities) (xml.CharData, error) {
+	return unescape(b, ents)
+}
+
+// StartElementEntities is like StartElement, but resolves references to
+// other entities in attribute values with ents.
+func StartElementEntities(b []byte, ents Entities) (xml.StartElement, error) {
+	return startElement(b, nil, nil, ents)
+}
+
+// TokenEntities is like Token, but resolves references to other entities
+// in text and attribute values with ents.
+func TokenEntities(e Event, ents Entities) (xml.Token, error) {
+	switch e.Type() {
+	case EventStart:
+		return StartElementEntities(e.Bytes, ents)
+	case EventText, EventEntityRef:
+		return CharDataEntities(e.Bytes, ents)
+	}
+	return Token(e)
+}
+
+// unescapeGrow continues unescape into a new slice, from the reference at
+// b[p:] on, when b[:cur] holds the text unescaped so far.
+func unescapeGrow(b []byte, cur, p int, ents Entities) ([]byte, error) {
+	dst := make([]byte, cur, len(b)+utf8.UTFMax)
+	copy(dst, b[:cur])
+	b = b[p:]
+	for {
+		i := bytes.IndexAny(b, "&\r")
+		if i < 0 {
+			return append(dst, b...), nil
+		}
+		dst = append(dst, b[:i]...)
+		b = b[i:]
+		if b[0] == '\r' {
+			dst = append(dst, '\n')
+			b = b[1:]
+			if len(b) > 0 && b[0] == '\n' {
+				b = b[1:]
+			}
+			continue
+		}
+		end := refEnd(b[1:])
+		if end <= 1 {
+			return nil, fmt.Errorf("invalid escape sequence")
+		}
+		escaped := b[1 : 1+end]
+		if escaped[0] == '#' {
+			x, err := charRef(escaped)
+			if err != nil {
+				return nil, err
+			}
+			dst = utf8.AppendRune(dst, x)
+		} else if c := predefinedEntity(escaped); c != 0 {
+			dst = append(dst, c)
+		} else {
+			v, ok := resolveEntity(ents, escaped)
+			if !ok {
+				return nil, fmt.Errorf("invalid escape sequence: %q", string(escaped))
+			}
+			dst = append(dst, v...)
+		}
+		b = b[end+2:]
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"io"
+)
+
+// EntityRefName returns the name of the reference held by an
+// EventEntityRef, such as "amp" for "&amp;" or "#160" for "&#160;".
+func EntityRefName(b []byte) []byte {
+	return trim(b, "&", ";")
+}
+
+// stateInsideTextRefs is stateInsideText for EmitEntityRefs.
+func (r *Reader) stateInsideTextRefs() (Event, error) {
+	r.state = (*Reader).stateInsideText
+	rr := &r.reader
+	end, err := readTextRef(rr)
+	if err == io.EOF && r.Follow {
+		return Event{}, err
+	}
+	if err == io.EOF && end == 0 {
+		r.state = (*Reader).stateDone
+		return Event{
+			value: uint64(EventEOF),
+		}, nil
+	}
+	if err != nil && err != io.EOF {
+		return Event{}, err
+	}
+	w := rr.window()
+	if end > 0 {
+		rr.offset += end
+		return Event{
+			Bytes: w[:end],
+			value: uint64(EventText),
+		}, nil
+	}
+	if w[0] == '<' {
+		return r.stateInsideMarkup()
+	}
+	offset := 1
+	for {
+		if i := bytes.IndexAny(w[offset:], ";<"); i >= 0 {
+			if w[offset+i] == '<' {
+				return Event{}, syntaxError(rr.inputOffset(), w[:offset+i], "unterminated entity reference")
+			}
+			rr.offset += offset + i + 1
+			return Event{
+				Bytes: w[:offset+i+1],
+				value: uint64(EventEntityRef),
+			}, nil
+		}
+		offset = len(w)
+		if rr.extend() == 0 {
+			if rr.err == io.EOF && !r.Follow {
+				return Event{}, syntaxError(rr.inputOffset(), w, "unterminated entity reference")
+			}
+			return Event{}, rr.err
+		}
+		w = rr.window()
+	}
+}
+
+// readTextRef is readText stopping at '&' too.
+func readTextRef(r *byteReader) (int, error) {
+	offset := 0
+	for {
+		w := r.window()
+		if i := bytes.IndexAny(w[offset:], "<&"); i >= 0 {
+			return offset + i, nil
+		}
+		offset = len(w)
+		if r.extend() == 0 {
+			return offset, r.err
+		}
+	}
+}
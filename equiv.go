@@ -0,0 +1,270 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+)
+
+// EquivalentStart reports whether the start tags a and b are equivalent
+// under XML semantics: the element and attribute names are compared as
+// namespace URI and local name, attribute order and quoting are ignored,
+// and attribute values are compared after normalization.
+//
+// Prefixes are resolved with the declarations on each tag; undeclared
+// prefixes must match literally.
+func EquivalentStart(a, b []byte) (bool, error) {
+	var sa, sb nsScope
+	ka, err := appendStartKey(nil, &sa, a)
+	if err != nil {
+		return false, err
+	}
+	kb, err := appendStartKey(nil, &sb, b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ka, kb), nil
+}
+
+// EquivalentSubtree reports whether the elements started by the last
+// EventStart of a and b are equivalent. Start tags are compared as by
+// EquivalentStart with the namespace declarations of the subtree in scope,
+// adjacent text and CDATA sections are compared as character data, and
+// comments are ignored. "<a/>" is equivalent to "<a></a>".
+//
+// Both Readers are consumed through the matching end tags, or up to the
+// first difference.
+func EquivalentSubtree(a, b *Reader) (bool, error) {
+	if a.last.Type() != EventStart || b.last.Type() != EventStart {
+		return false, errors.New("gosax: EquivalentSubtree called without EventStart")
+	}
+	sa, sb := &equivSide{r: a}, &equivSide{r: b}
+	for {
+		oka, err := sa.next()
+		if err != nil {
+			return false, err
+		}
+		okb, err := sb.next()
+		if err != nil {
+			return false, err
+		}
+		if oka != okb || !bytes.Equal(sa.key, sb.key) {
+			return false, nil
+		}
+		if !oka {
+			return true, nil
+		}
+	}
+}
+
+// equivSide produces the normalized items of a subtree for comparison.
+type equivSide struct {
+	r       *Reader
+	scope   nsScope
+	started bool
+	depth   int
+	done    bool
+	endNext bool
+	pending Event
+	unread  bool
+	key     []byte
+}
+
+// next sets s.key to the next item of the subtree. It reports false after
+// the end of the subtree.
+func (s *equivSide) next() (bool, error) {
+	s.key = s.key[:0]
+	if !s.started {
+		s.started = true
+		return true, s.start(s.r.last.Bytes, true)
+	}
+	if s.endNext {
+		s.endNext = false
+		s.key = append(s.key, 'E')
+		if s.depth < 0 {
+			s.done = true
+		}
+		return true, nil
+	}
+	if s.done {
+		return false, nil
+	}
+	text := false
+	for {
+		ev, err := s.event()
+		if err != nil {
+			return false, err
+		}
+		switch ev.Type() {
+		case EventText, EventEntityRef:
+			if !text {
+				s.key = append(s.key, 'T')
+				text = true
+			}
+			n := len(s.key)
+			s.key = append(s.key, ev.Bytes...)
+			v, err := Unescape(s.key[n:])
+			if err != nil {
+				return false, err
+			}
+			s.key = s.key[:n+len(v)]
+			continue
+		case EventCData:
+			if !text {
+				s.key = append(s.key, 'T')
+				text = true
+			}
+			s.key = append(s.key, trim(ev.Bytes, "<![CDATA[", "]]>")...)
+			continue
+		case EventComment:
+			continue
+		}
+		if text {
+			s.pending, s.unread = ev, true
+			return true, nil
+		}
+		switch ev.Type() {
+		case EventStart:
+			return true, s.start(ev.Bytes, false)
+		case EventEnd:
+			if isSelfClosing(ev.Bytes) {
+				// Reported by EmitSelfClosingTag; the end was already produced.
+				continue
+			}
+			s.scope.pop()
+			s.key = append(s.key, 'E')
+			if s.depth == 0 {
+				s.done = true
+			}
+			s.depth--
+			return true, nil
+		case EventEOF:
+			return false, io.ErrUnexpectedEOF
+		default:
+			s.key = append(s.key, 'P')
+			s.key = append(s.key, ev.Bytes...)
+			return true, nil
+		}
+	}
+}
+
+func (s *equivSide) start(b []byte, root bool) error {
+	var err error
+	s.key = append(s.key, 'S')
+	s.key, err = appendStartKey(s.key, &s.scope, b)
+	if err != nil {
+		return err
+	}
+	if !isSelfClosing(b) {
+		if !root {
+			s.depth++
+		}
+		return nil
+	}
+	s.scope.pop()
+	s.endNext = true
+	if root {
+		// The subtree ends with this tag.
+		s.depth = -1
+		if s.r.EmitSelfClosingTag {
+			_, err = s.r.Event()
+		}
+	}
+	return err
+}
+
+func (s *equivSide) event() (Event, error) {
+	if s.unread {
+		s.unread = false
+		return s.pending, nil
+	}
+	return s.r.Event()
+}
+
+// appendStartKey pushes a frame for the start tag b onto s, declares its
+// namespaces and appends a normalized form of the tag to dst.
+func appendStartKey(dst []byte, s *nsScope, b []byte) ([]byte, error) {
+	name, rest := Name(b)
+	s.push()
+	type attr struct{ key, value []byte }
+	var attrs []attr
+	for len(rest) > 0 {
+		a, r, err := NextAttribute(rest)
+		if err != nil {
+			return dst, err
+		}
+		rest = r
+		if len(a.Key) == 0 {
+			break
+		}
+		if len(a.Value) < 2 {
+			return dst, errors.New("gosax: invalid attribute value")
+		}
+		v, err := UnescapeAttr(bytes.Clone(a.Value[1 : len(a.Value)-1]))
+		if err != nil {
+			return dst, err
+		}
+		if prefix, ok := nsDecl(a.Key); ok {
+			s.declare(prefix, string(v))
+			continue
+		}
+		attrs = append(attrs, attr{a.Key, v})
+	}
+
+	dst = appendExpandedName(dst, s, name, true)
+	keys := make([][]byte, len(attrs))
+	for i, a := range attrs {
+		k := appendExpandedName(nil, s, a.key, false)
+		k = append(k, '=')
+		keys[i] = append(k, a.value...)
+	}
+	slices.SortFunc(keys, bytes.Compare)
+	for _, k := range keys {
+		dst = append(dst, 0)
+		dst = append(dst, k...)
+	}
+	return dst, nil
+}
+
+// appendExpandedName appends the namespace URI and local part of the
+// qualified name qname. Unprefixed attributes are in no namespace.
+func appendExpandedName(dst []byte, s *nsScope, qname []byte, element bool) []byte {
+	prefix, local := splitQName(qname)
+	if prefix != nil || element {
+		if uri, ok := s.lookup(string(prefix)); ok {
+			dst = append(dst, uri...)
+		} else if prefix != nil {
+			dst = append(dst, 1)
+			dst = append(dst, prefix...)
+		}
+	}
+	dst = append(dst, '}')
+	return append(dst, local...)
+}
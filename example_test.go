@@ -27,11 +27,26 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package gosax_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unsafe"
 
 	"github.com/orisano/gosax"
 )
@@ -198,6 +213,36 @@ func ExampleUnescape() {
 	// "Line1\nLine2\nLine3\nLine4\nLine5\n"
 }
 
+func ExampleUnescape_references() {
+	b, err := gosax.Unescape([]byte("&#x000000000041;&#0000000066; &amp; &quot;"))
+	fmt.Printf("%q %v\n", b, err)
+	// Output:
+	// "AB & \"" <nil>
+}
+
+func ExampleUnescapeAttr() {
+	b, _ := gosax.UnescapeAttr([]byte("a\r\nb\tc&#10;d"))
+	fmt.Printf("%q", b)
+	// Output:
+	// "a b c\nd"
+}
+
+func ExampleProcInst() {
+	for _, s := range []string{`<?pi  data?>`, `<?pi?>`} {
+		p := gosax.ProcInst([]byte(s))
+		fmt.Printf("%q %q\n", p.Target, p.Inst)
+	}
+	e, err := gosax.StartElement([]byte(`<:a b:="1">`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%q %q\n", e.Name, e.Attr[0].Name)
+	// Output:
+	// "pi" "data"
+	// "pi" ""
+	// {"" ":a"} {"" "b:"}
+}
+
 func ExampleStartElement() {
 	xmlData := `<root><element
 	foo="bar"
@@ -237,3 +282,2779 @@ func ExampleStartElement() {
 	// EndElement element
 	// EndElement root
 }
+
+func ExampleScanner() {
+	s := gosax.NewScanner(strings.NewReader("alpha;beta;gamma"), nil)
+	for {
+		i := s.IndexByte(';')
+		if i < 0 {
+			fmt.Println(string(s.Window()))
+			break
+		}
+		fmt.Println(string(s.Window()[:i]))
+		s.Advance(i + 1)
+	}
+	// Output:
+	// alpha
+	// beta
+	// gamma
+}
+
+func ExampleReader_Remaining() {
+	reader := strings.NewReader(`<hello version="1"/>` + "\x00\x01binary payload")
+
+	r := gosax.NewReader(reader)
+	e, err := r.Event()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(e.Bytes))
+	fmt.Println(r.Buffered())
+
+	rest, err := io.ReadAll(r.Remaining())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%q\n", rest)
+	// Output:
+	// <hello version="1"/>
+	// 16
+	// "\x00\x01binary payload"
+}
+
+func ExampleReader_SubtreeReader() {
+	xmlData := `<root><payload id="1"><a>x</a><b/></payload><next/></root>`
+	reader := strings.NewReader(xmlData)
+
+	r := gosax.NewReader(reader)
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() != gosax.EventStart {
+			continue
+		}
+		if name, _ := gosax.Name(e.Bytes); string(name) == "payload" {
+			sr, err := r.SubtreeReader()
+			if err != nil {
+				log.Fatal(err)
+			}
+			b, err := io.ReadAll(sr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(b))
+		} else {
+			fmt.Println(string(e.Bytes))
+		}
+	}
+	// Output:
+	// <root>
+	// <payload id="1"><a>x</a><b/></payload>
+	// <next/>
+}
+
+func ExampleWriter() {
+	var sb strings.Builder
+	w := gosax.NewWriter(&sb)
+	w.StartElement([]byte("root"))
+	w.Attr([]byte("title"), []byte(`"quoted" & <escaped>`))
+	w.StartElement([]byte("element"))
+	w.Text([]byte("a < b"))
+	w.EndElement()
+	w.EndElement()
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sb.String())
+	// Output:
+	// <root title="&quot;quoted&quot; &amp; &lt;escaped&gt;"><element>a &lt; b</element></root>
+}
+
+func ExampleMarshal() {
+	type Item struct {
+		ID    int      `xml:"id,attr"`
+		Name  string   `xml:"name"`
+		Tags  []string `xml:"tags>tag"`
+		Notes string   `xml:"notes,omitempty"`
+	}
+	b, err := gosax.Marshal(struct {
+		XMLName xml.Name `xml:"items"`
+		Items   []Item   `xml:"item"`
+	}{
+		Items: []Item{{ID: 1, Name: "first", Tags: []string{"a", "b"}}},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+	// Output:
+	// <items><item id="1"><name>first</name><tags><tag>a</tag><tag>b</tag></tags></item></items>
+}
+
+func ExampleMarshal_namespacedAttrs() {
+	type Link struct {
+		Href string `xml:"http://www.w3.org/1999/xlink href,attr"`
+	}
+	b, err := gosax.Marshal(struct {
+		XMLName xml.Name `xml:"doc"`
+		Href    string   `xml:"http://www.w3.org/1999/xlink href,attr"`
+		Title   string   `xml:"http://www.w3.org/1999/xlink title,attr"`
+		Lang    string   `xml:"http://example.com/ns lang,attr"`
+		Link    Link     `xml:"link"`
+	}{
+		Href:  "a.xml",
+		Title: "A",
+		Lang:  "en",
+		Link:  Link{Href: "b.xml"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+	// Output:
+	// <doc xmlns:xlink="http://www.w3.org/1999/xlink" xlink:href="a.xml" xlink:title="A" xmlns:ns="http://example.com/ns" ns:lang="en"><link xlink:href="b.xml"></link></doc>
+}
+
+func ExampleMarshal_marshaler() {
+	type Reading struct {
+		XMLName xml.Name `xml:"reading"`
+		Level   level    `xml:"level,attr"`
+		Temp    celsius  `xml:"temp"`
+	}
+	v := Reading{Level: 1, Temp: 21.5}
+	b, err := gosax.Marshal(v)
+	if err != nil {
+		log.Fatal(err)
+	}
+	want, err := xml.Marshal(v)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+	fmt.Println(bytes.Equal(b, want))
+	// Output:
+	// <reading level="high"><temp unit="C">21.5</temp></reading>
+	// true
+}
+
+// celsius implements xml.Marshaler, writing the unit as an attribute.
+type celsius float64
+
+func (c celsius) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "unit"}, Value: "C"})
+	return e.EncodeElement(strconv.FormatFloat(float64(c), 'f', 1, 64), start)
+}
+
+// level implements xml.MarshalerAttr, writing its name.
+type level int
+
+func (l level) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{Name: name, Value: [...]string{"low", "high"}[l]}, nil
+}
+
+func ExampleParseInt() {
+	xmlData := `<point x="12" y="-7"/>`
+	r := gosax.NewReader(strings.NewReader(xmlData))
+	e, err := r.Event()
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, b := gosax.Name(e.Bytes)
+	for len(b) > 0 {
+		var attr gosax.Attribute
+		attr, b, err = gosax.NextAttribute(b)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(attr.Key) == 0 {
+			break
+		}
+		v, err := gosax.ParseInt(attr.Value[1:len(attr.Value)-1], 10, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(attr.Key), v)
+	}
+	// Output:
+	// x 12
+	// y -7
+}
+
+func ExampleDecodeBase64() {
+	xmlData := `<file name="hello.txt">
+	SGVsbG8s
+	IFdvcmxkIQ==
+</file>`
+	r := gosax.NewReader(strings.NewReader(xmlData))
+	if _, err := r.Event(); err != nil {
+		log.Fatal(err)
+	}
+	var sb strings.Builder
+	if _, err := gosax.DecodeBase64(r, &sb, nil); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sb.String())
+	// Output:
+	// Hello, World!
+}
+
+func ExampleHashSubtree() {
+	xmlData := `<feed>
+	<record id="1" lang="en"><title>Same</title></record>
+	<record lang='en'  id='1' ><title>Same</title></record>
+</feed>`
+	r := gosax.NewReader(strings.NewReader(xmlData))
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if name, _ := gosax.Name(e.Bytes); e.Type() == gosax.EventStart && string(name) == "record" {
+			h := sha256.New()
+			if err := gosax.HashSubtree(r, h, gosax.C14N10); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%x\n", h.Sum(nil)[:8])
+		}
+	}
+	// Output:
+	// fc8e8475717f25a8
+	// fc8e8475717f25a8
+}
+
+// canonicalize writes the canonical form of the document read by r.
+func canonicalize(r *gosax.Reader, mode gosax.Mode) string {
+	var sb strings.Builder
+	c := gosax.NewCanonicalizer(&sb, mode)
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if err := c.WriteEvent(e); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := c.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	return sb.String()
+}
+
+// The examples below are the test vectors of section 3 of the W3C
+// Canonical XML 1.0 recommendation.
+
+const c14nPIs = `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<!DOCTYPE doc SYSTEM "doc.dtd">
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->`
+
+func ExampleCanonicalizer() {
+	r := gosax.NewReader(strings.NewReader(c14nPIs))
+	fmt.Println(canonicalize(r, gosax.C14N10))
+	// Output:
+	// <?xml-stylesheet href="doc.xsl"
+	//    type="text/xsl"   ?>
+	// <doc>Hello, world!</doc>
+	// <?pi-without-data?>
+}
+
+func ExampleCanonicalizer_withComments() {
+	r := gosax.NewReader(strings.NewReader(c14nPIs))
+	fmt.Println(canonicalize(r, gosax.C14N10WithComments))
+	// Output:
+	// <?xml-stylesheet href="doc.xsl"
+	//    type="text/xsl"   ?>
+	// <doc>Hello, world!<!-- Comment 1 --></doc>
+	// <?pi-without-data?>
+	// <!-- Comment 2 -->
+	// <!-- Comment 3 -->
+}
+
+func ExampleCanonicalizer_whitespace() {
+	const data = `<doc>
+   <clean>   </clean>
+   <dirty>   A   B   </dirty>
+   <mixed>
+      A
+      <clean>   </clean>
+      B
+      <dirty>   A   B   </dirty>
+      C
+   </mixed>
+</doc>`
+	r := gosax.NewReader(strings.NewReader(data))
+	fmt.Println(canonicalize(r, gosax.C14N10) == data)
+	// Output:
+	// true
+}
+
+func ExampleCanonicalizer_tags() {
+	const data = `<!DOCTYPE doc [<!ATTLIST e9 attr CDATA "default">]>
+<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.ApplyDTDDefaults = true
+	fmt.Println(canonicalize(r, gosax.C14N10))
+	// Output:
+	// <doc>
+	//    <e1></e1>
+	//    <e2></e2>
+	//    <e3 id="elem3" name="elem3"></e3>
+	//    <e4 id="elem4" name="elem4"></e4>
+	//    <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+	//    <e6 xmlns:a="http://www.w3.org">
+	//       <e7 xmlns="http://www.ietf.org">
+	//          <e8 xmlns="">
+	//             <e9 xmlns:a="http://www.ietf.org" attr="default"></e9>
+	//          </e8>
+	//       </e7>
+	//    </e6>
+	// </doc>
+}
+
+func ExampleCanonicalizer_chars() {
+	const data = `<!DOCTYPE doc [
+<!ATTLIST normId id ID #IMPLIED>
+<!ATTLIST normNames attr NMTOKENS #IMPLIED>
+]>
+<doc>
+   <text>First line&#x0d;&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+   <normNames attr='   A   &#x20;&#13;&#xa;&#9;   B   '/>
+   <normId id=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+</doc>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.NormalizeDTDAttrs = true
+	fmt.Println(canonicalize(r, gosax.C14N10))
+	// Output:
+	// <doc>
+	//    <text>First line&#xD;
+	// Second line</text>
+	//    <value>2</value>
+	//    <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>
+	//    <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>
+	//    <norm attr=" '    &#xD;&#xA;&#x9;   ' "></norm>
+	//    <normNames attr="A &#xD;&#xA;&#x9; B"></normNames>
+	//    <normId id="' &#xD;&#xA;&#x9; '"></normId>
+	// </doc>
+}
+
+func ExampleCanonicalizer_encoding() {
+	const data = "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<doc>\xa9</doc>"
+	r := gosax.NewReader(strings.NewReader(data))
+	r.DetectEncoding = true
+	fmt.Println(canonicalize(r, gosax.C14N10))
+	// Output:
+	// <doc>©</doc>
+}
+
+func ExampleParser_Stop() {
+	xmlData := `<batch><item>1</item><item>2</item><item>3</item></batch>`
+	var p *gosax.Parser
+	p = gosax.NewParser(gosax.NewReader(strings.NewReader(xmlData)), func(e gosax.Event) error {
+		if e.Type() == gosax.EventText {
+			fmt.Println("item", string(e.Bytes))
+			p.Stop(true)
+		}
+		return nil
+	})
+	err := p.Parse()
+	for err == gosax.ErrSuspended {
+		fmt.Println("suspended")
+		err = p.Resume()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// item 1
+	// suspended
+	// item 2
+	// suspended
+	// item 3
+	// suspended
+}
+
+func ExampleWriter_Charset() {
+	var sb strings.Builder
+	w := gosax.NewWriter(&sb)
+	w.Charset = gosax.ISO88591
+	w.XMLDecl()
+	w.StartElement([]byte("price"))
+	w.Attr([]byte("currency"), []byte("€"))
+	w.Text([]byte("12,50"))
+	w.EndElement()
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sb.String())
+	// Output:
+	// <?xml version="1.0" encoding="ISO-8859-1"?><price currency="&#x20ac;">12,50</price>
+}
+
+func ExampleMetrics() {
+	var m gosax.Metrics
+	r := gosax.NewReader(strings.NewReader(`<a><b>1</b><b>2</b></a>`))
+	r.Observer = &m
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	fmt.Println(m.String())
+	// Output:
+	// {"events": 9, "bytes": 23, "refills": 2, "errors": 0}
+}
+
+func ExampleXInclude() {
+	files := map[string]string{
+		"book.xml": `<book xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="ch1.xml"/><xi:include href="ch2.xml"><xi:fallback><missing/></xi:fallback></xi:include></book>`,
+		"ch1.xml":  `<?xml version="1.0"?><chapter>One</chapter>`,
+	}
+	open := func(href string) (io.ReadCloser, error) {
+		s, ok := files[href]
+		if !ok {
+			return nil, fmt.Errorf("%s: not found", href)
+		}
+		return io.NopCloser(strings.NewReader(s)), nil
+	}
+	r := gosax.NewXInclude(gosax.NewReader(strings.NewReader(files["book.xml"])), open)
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Print(string(e.Bytes))
+	}
+	fmt.Println()
+	// Output:
+	// <book xmlns:xi="http://www.w3.org/2001/XInclude"><chapter>One</chapter><missing/></book>
+}
+
+func ExampleBuildIDIndex() {
+	xmlData := `<spec><section xml:id="intro">Intro</section><section xml:id="terms">Terms</section></spec>`
+	ix, err := gosax.BuildIDIndex(gosax.NewReader(strings.NewReader(xmlData)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	off, _ := ix.Lookup("terms")
+	r := gosax.NewReader(io.NewSectionReader(strings.NewReader(xmlData), off, int64(len(xmlData))-off))
+	r.Event()
+	e, _ := r.Event()
+	fmt.Println(off, string(e.Bytes))
+	// Output:
+	// 45 Terms
+}
+
+func ExampleChain() {
+	xmlData := `<user><name>gopher</name><!-- internal --><password>hunter2</password></user>`
+
+	stripComments := gosax.FilterFunc(func(e gosax.Event, emit func(gosax.Event)) error {
+		if e.Type() != gosax.EventComment {
+			emit(e)
+		}
+		return nil
+	})
+	redactPasswords := func(r gosax.EventReader) gosax.EventReader {
+		inPassword := false
+		return gosax.Chain(r, gosax.FilterFunc(func(e gosax.Event, emit func(gosax.Event)) error {
+			switch e.Type() {
+			case gosax.EventStart, gosax.EventEnd:
+				if name, _ := gosax.Name(e.Bytes); string(name) == "password" {
+					inPassword = e.Type() == gosax.EventStart
+				}
+			case gosax.EventText:
+				if inPassword {
+					e.Bytes = []byte("***")
+				}
+			}
+			emit(e)
+			return nil
+		}))
+	}
+
+	var sb strings.Builder
+	r := gosax.Chain(gosax.NewReader(strings.NewReader(xmlData)), stripComments, redactPasswords)
+	if err := gosax.CopyEvents(gosax.NewWriter(&sb), r); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sb.String())
+	// Output:
+	// <user><name>gopher</name><password>***</password></user>
+}
+
+func ExampleEquivalentStart() {
+	eq, err := gosax.EquivalentStart(
+		[]byte(`<p:item xmlns:p="urn:x" id="1" p:lang="en">`),
+		[]byte(`<q:item q:lang='en' id='1' xmlns:q="urn:x">`),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(eq)
+	// Output:
+	// true
+}
+
+func ExampleRecordEvents() {
+	var cache bytes.Buffer
+	if err := gosax.RecordEvents(&cache, gosax.NewReader(strings.NewReader(`<a><b>cached</b></a>`))); err != nil {
+		log.Fatal(err)
+	}
+	r := gosax.ReplayEvents(&cache)
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Print(string(e.Bytes))
+	}
+	fmt.Println()
+	// Output:
+	// <a><b>cached</b></a>
+}
+
+func ExampleBuildIndex() {
+	xmlData := `<feed><entry xml:id="a"><title>First</title></entry><entry xml:id="b"><title>Second</title></entry></feed>`
+	ix, err := gosax.BuildIndex(gosax.NewReader(strings.NewReader(xmlData)), "feed/entry")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, rec := range ix.Records {
+		fmt.Println(xmlData[rec.Offset : rec.Offset+rec.Length])
+	}
+	fmt.Println(ix.Paths["feed/entry/title"].Count, ix.IDs["b"])
+	// Output:
+	// <entry xml:id="a"><title>First</title></entry>
+	// <entry xml:id="b"><title>Second</title></entry>
+	// 2 52
+}
+
+func ExampleProcessSubtrees() {
+	xmlData := `<orders><order id="1"><total>10</total></order><order id="2"><total>25</total></order><order id="3"><total>7</total></order></orders>`
+	r := gosax.NewReader(strings.NewReader(xmlData))
+	err := gosax.ProcessSubtrees(r, "orders/order", 4, func(subtree []byte) (int, error) {
+		// Runs concurrently on a private copy of the subtree.
+		return len(subtree), nil
+	}, func(n int) error {
+		// Runs in input order.
+		fmt.Println(n)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// 39
+	// 39
+	// 38
+}
+
+func ExampleWriter_Escaping() {
+	var sb strings.Builder
+	w := gosax.NewWriter(&sb)
+	w.Escaping = gosax.EscapeAggressive
+	w.CDATAThreshold = 16
+	w.StartElement([]byte("snippet"))
+	w.Attr([]byte("title"), []byte("Größe"))
+	w.Text([]byte("if a < b && b > c {}"))
+	w.EndElement()
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sb.String())
+	// Output:
+	// <snippet title="Gr&#xf6;&#xdf;e"><![CDATA[if a < b && b > c {}]]></snippet>
+}
+
+func ExampleReader_ApplyDTDDefaults() {
+	xmlData := `<!DOCTYPE memo [
+  <!ATTLIST memo priority (low|high) "low" lang CDATA #IMPLIED>
+]>
+<memo><memo priority="high"/></memo>`
+	r := gosax.NewReader(strings.NewReader(xmlData))
+	r.ApplyDTDDefaults = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventStart {
+			fmt.Println(string(e.Bytes))
+		}
+	}
+	// Output:
+	// <memo priority="low">
+	// <memo priority="high"/>
+}
+
+func ExampleIDRefChecker() {
+	xmlData := `<!DOCTYPE book [
+  <!ATTLIST chapter id ID #REQUIRED>
+  <!ATTLIST xref to IDREF #REQUIRED>
+]>
+<book>
+  <chapter id="intro"><xref to="usage"/></chapter>
+  <chapter id="usage"><xref to="intro"/><xref to="appendix"/></chapter>
+</book>`
+	c := &gosax.IDRefChecker{}
+	problems, err := c.Check(gosax.NewReader(strings.NewReader(xmlData)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	// Output:
+	// offset 192: <xref to>: reference to undefined ID "appendix"
+}
+
+func ExampleConfig() {
+	r := gosax.NewReader(strings.NewReader("<list>\n  <item/>\n  <item> two </item>\n</list>"))
+	gosax.Config{TrimText: true, ExpandEmptyElements: true, CheckEndNames: true}.Apply(r)
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Printf("%q\n", e.Bytes)
+	}
+	// Output:
+	// "<list>"
+	// "<item/>"
+	// "<item/>"
+	// "<item>"
+	// "two"
+	// "</item>"
+	// "</list>"
+}
+
+func ExampleEventType_String() {
+	r := gosax.NewReader(strings.NewReader(`<a>text</a>`))
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(e.Type())
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	// Output:
+	// EventStart
+	// EventText
+	// EventEnd
+	// EventEOF
+}
+
+func ExampleSkip() {
+	r := gosax.NewReader(strings.NewReader(`<root><img src="a.png"/><p>skipped <br/> text</p><p>cut`))
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventStart && string(e.Bytes) != "<root>" {
+			fmt.Printf("%s %v\n", e.Bytes, gosax.Skip(r))
+			continue
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	// Output:
+	// <img src="a.png"/> <nil>
+	// <p> <nil>
+	// <p> unexpected EOF
+}
+
+func ExampleSkipFunc() {
+	r := gosax.NewReader(strings.NewReader(`<root><debug><x>y</x>noise</debug><data>1</data></root>`))
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventStart && string(e.Bytes) == "<debug>" {
+			skipped := 0
+			err := gosax.SkipFunc(r, func(e gosax.Event) error {
+				skipped++
+				return nil
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println("skipped", skipped, "events")
+			continue
+		}
+		fmt.Printf("%s\n", e.Bytes)
+	}
+	// Output:
+	// <root>
+	// skipped 5 events
+	// <data>
+	// 1
+	// </data>
+	// </root>
+}
+
+func ExampleNewReaderAuto() {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	io.WriteString(zw, "<feed><entry/></feed>")
+	zw.Close()
+
+	r, err := gosax.NewReaderAuto(&compressed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Printf("%s\n", e.Bytes)
+	}
+	// Output:
+	// <feed>
+	// <entry/>
+	// </feed>
+}
+
+func ExampleWalkZip() {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"word/document.xml": "<document><body/></document>",
+		"word/media/a.png":  "not xml",
+	} {
+		w, _ := zw.Create(name)
+		io.WriteString(w, body)
+	}
+	zw.Close()
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = gosax.WalkZip(z, nil, func(name string, r *gosax.Reader) error {
+		for {
+			e, err := r.Event()
+			if err != nil {
+				return err
+			}
+			if e.Type() == gosax.EventEOF {
+				return nil
+			}
+			fmt.Printf("%s: %s\n", name, e.Bytes)
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// word/document.xml: <document>
+	// word/document.xml: <body/>
+	// word/document.xml: </document>
+}
+
+func ExampleReader_Resync() {
+	r := gosax.NewReader(strings.NewReader(`<log><entry>1</entry><!garbage><entry>2</entry></log>`))
+	for {
+		e, err := r.Event()
+		if err != nil {
+			n, err := r.Resync()
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println("skipped", n, "bytes")
+			continue
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Printf("%s\n", e.Bytes)
+	}
+	// Output:
+	// <log>
+	// <entry>
+	// 1
+	// </entry>
+	// skipped 10 bytes
+	// <entry>
+	// 2
+	// </entry>
+	// </log>
+}
+
+func ExampleReader_EmitEntityRefs() {
+	r := gosax.NewReader(strings.NewReader(`<p>Fish &amp; Chips&nbsp;&#163;5</p>`))
+	r.EmitEntityRefs = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventEntityRef {
+			fmt.Printf("%v %s\n", e.Type(), gosax.EntityRefName(e.Bytes))
+			continue
+		}
+		fmt.Printf("%v %q\n", e.Type(), e.Bytes)
+	}
+	// Output:
+	// EventStart "<p>"
+	// EventText "Fish "
+	// EventEntityRef amp
+	// EventText " Chips"
+	// EventEntityRef nbsp
+	// EventEntityRef #163
+	// EventText "5"
+	// EventEnd "</p>"
+}
+
+func ExampleReader_UnescapeText() {
+	r := gosax.NewReader(strings.NewReader("<p>1 &lt; 2\r\n&amp; 3 &gt; 2</p>"))
+	r.UnescapeText = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Printf("%q\n", e.Bytes)
+	}
+	// Output:
+	// "<p>"
+	// "1 < 2\n& 3 > 2"
+	// "</p>"
+}
+
+func ExampleEvent_Unescaped() {
+	r := gosax.NewReader(strings.NewReader("<p>&amp;lt; <![CDATA[<b>]]></p>"))
+	r.UnescapeText = true
+	r.ExpandCDATA = true
+	var sb strings.Builder
+	w := gosax.NewWriter(&sb)
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventText {
+			t, err := gosax.Token(e)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%v %q\n", e.Unescaped(), t)
+		}
+		if err := w.WriteEvent(e); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sb.String())
+	// Output:
+	// true "&lt; "
+	// true "<b>"
+	// <p>&amp;lt; &lt;b&gt;</p>
+}
+
+func ExampleReader_Raw() {
+	r := gosax.NewReader(strings.NewReader("<p>  fish &amp; chips  </p>"))
+	r.TrimText = true
+	r.UnescapeText = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventText {
+			fmt.Printf("%q %q\n", e.Bytes, r.Raw())
+		}
+	}
+	// Output:
+	// "fish & chips" "  fish &amp; chips  "
+}
+
+func ExampleNewProjection() {
+	const doc = `<feed>
+<title>News</title>
+<entry><id>1</id><title>First</title><content><p>long</p></content></entry>
+<entry><id>2</id><title>Second</title><link href="/2"/></entry>
+</feed>`
+	r := gosax.NewReader(strings.NewReader(doc))
+	p := gosax.NewProjection(r, "feed/entry/id", "feed/entry/title")
+	for {
+		e, err := p.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Printf("%s ", e.Bytes)
+	}
+	fmt.Println()
+	// Output:
+	// <feed> <entry> <id> 1 </id> <title> First </title> </entry> <entry> <id> 2 </id> <title> Second </title> </entry> </feed>
+}
+
+func ExampleRepairTruncated() {
+	truncated := `<export><item id="1">done</item><item id="2"><name>Fish &amp; Ch`
+	var sb strings.Builder
+	n, err := gosax.RepairTruncated(&sb, strings.NewReader(truncated))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sb.String())
+	fmt.Println("closed", n, "elements")
+	// Output:
+	// <export><item id="1">done</item><item id="2"><name>Fish &amp; Ch</name></item></export>
+	// closed 3 elements
+}
+
+func ExampleReader_Follow() {
+	var file bytes.Buffer // stands in for a file that is being appended to
+	r := gosax.NewReader(&file)
+	r.Follow = true
+	for _, chunk := range []string{"<log><entry>sta", "rted</entry><en", "try>stopped</entry>"} {
+		file.WriteString(chunk)
+		for {
+			e, err := r.Event()
+			if err == gosax.ErrNeedMoreData {
+				break
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%s ", e.Bytes)
+		}
+		fmt.Println("| waiting")
+	}
+	// Output:
+	// <log> <entry> | waiting
+	// started </entry> | waiting
+	// <entry> stopped </entry> | waiting
+}
+
+// stalledReader returns (0, nil) from every Read.
+type stalledReader struct{}
+
+func (stalledReader) Read([]byte) (int, error) { return 0, nil }
+
+func ExampleNewReader_noProgress() {
+	r := gosax.NewReader(io.MultiReader(strings.NewReader("<a>"), stalledReader{}))
+	for {
+		e, err := r.Event()
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		fmt.Printf("%s\n", e.Bytes)
+	}
+	// Output:
+	// <a>
+	// multiple Read calls return no data or error
+}
+
+func ExampleEvent_Seq() {
+	r := gosax.NewReader(strings.NewReader(`<a><b>x</b></a>`))
+	r.SeqNumbers = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(e.Seq(), e.Type())
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	// Output:
+	// 1 EventStart
+	// 2 EventStart
+	// 3 EventText
+	// 4 EventEnd
+	// 5 EventEnd
+	// 6 EventEOF
+}
+
+func ExampleUnmarshal() {
+	type Entry struct {
+		ID    int      `xml:"id,attr"`
+		Title string   `xml:"title"`
+		Tags  []string `xml:"tags>tag"`
+	}
+	type Feed struct {
+		XMLName xml.Name `xml:"feed"`
+		Entries []Entry  `xml:"entry"`
+	}
+	data := []byte(`<feed>
+  <entry id="1"><title>Fish &amp; Chips</title><tags><tag>food</tag><tag>uk</tag></tags></entry>
+  <entry id="2"><title>Ramen</title></entry>
+</feed>`)
+	var feed Feed
+	if err := gosax.Unmarshal(data, &feed); err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range feed.Entries {
+		fmt.Printf("%d %q %q\n", e.ID, e.Title, e.Tags)
+	}
+	// Output:
+	// 1 "Fish & Chips" ["food" "uk"]
+	// 2 "Ramen" []
+}
+
+func ExampleUnmarshal_paths() {
+	type Record struct {
+		Title   string   `xml:"metadata>titleInfo>title"`
+		Authors []string `xml:"metadata>*>author"`
+		IDs     []string `xml:"identifiers>*"`
+	}
+	data := []byte(`<record>
+  <metadata>
+    <titleInfo><title>Gosax</title></titleInfo>
+    <primary><author>Ann</author></primary>
+    <secondary><author>Bob</author></secondary>
+  </metadata>
+  <identifiers><isbn>123</isbn><doi>10.1/x</doi></identifiers>
+</record>`)
+	var r Record
+	if err := gosax.Unmarshal(data, &r); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%q %q %q\n", r.Title, r.Authors, r.IDs)
+	// Output:
+	// "Gosax" ["Ann" "Bob"] ["123" "10.1/x"]
+}
+
+func ExampleUnmarshal_errors() {
+	type Item struct {
+		XMLName xml.Name          `xml:"item"`
+		ID      int8              `xml:"id,attr"`
+		Count   int               `xml:"count"`
+		OK      bool              `xml:"ok"`
+		Meta    map[string]string `xml:"meta"`
+	}
+	for _, data := range []string{
+		`<other/>`,
+		`<item id="300"/>`,
+		`<item><count>abc</count></item>`,
+		`<item><ok>maybe</ok></item>`,
+		`<item><meta>x</meta></item>`,
+		`<item><count>1</count>`,
+		`<item id=1/>`,
+		``,
+	} {
+		var item Item
+		err := gosax.Unmarshal([]byte(data), &item)
+		fmt.Println(err)
+	}
+	var item Item
+	fmt.Println(gosax.Unmarshal([]byte(`<item/>`), item))
+	// Output:
+	// gosax: expected element type <item> but have <other>
+	// strconv.ParseInt: parsing "300": value out of range
+	// strconv.ParseInt: parsing "abc": invalid syntax
+	// strconv.ParseBool: parsing "maybe": invalid syntax
+	// gosax: cannot unmarshal into map[string]string
+	// unexpected EOF
+	// invalid attribute value: 1
+	// EOF
+	// gosax: Decode requires a non-nil pointer
+}
+
+func ExampleDecoder_Decode_checkEndNames() {
+	type Item struct {
+		Count int `xml:"count"`
+	}
+	const data = `<item><count>1</oops></item>`
+	for _, check := range []bool{false, true} {
+		r := gosax.NewReader(strings.NewReader(data))
+		r.CheckEndNames = check
+		var item Item
+		err := gosax.NewDecoderReader(r).Decode(&item)
+		fmt.Println(item.Count, err)
+	}
+	// Output:
+	// 1 <nil>
+	// 0 gosax: end tag </oops> does not match start tag <count>
+}
+
+func ExampleCompileDecoder() {
+	type Item struct {
+		ID    int    `xml:"id,attr"`
+		Title string `xml:"title"`
+	}
+	type Feed struct {
+		Items []Item `xml:"item"`
+	}
+	plan, err := gosax.CompileDecoder[Feed]()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, data := range []string{
+		`<feed><item id="1"><title>a</title></item></feed>`,
+		`<feed><item id="2"><title>b</title></item><item id="3"><title>c</title></item></feed>`,
+	} {
+		var f Feed
+		if err := plan.Unmarshal([]byte(data), &f); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(f.Items)
+	}
+
+	type Bad struct {
+		X string `xml:"x>"`
+	}
+	_, err = gosax.CompileDecoder[Bad]()
+	fmt.Println(err != nil)
+	// Output:
+	// [{1 a}]
+	// [{2 b} {3 c}]
+	// true
+}
+
+func ExampleNewNameSet() {
+	const (
+		location = iota
+		item
+	)
+	names := gosax.NewNameSet("location", "item")
+
+	r := gosax.NewReader(strings.NewReader(`<site><item><location>Africa</location></item></site>`))
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() != gosax.EventStart {
+			continue
+		}
+		switch names.IndexTag(e.Bytes) {
+		case location:
+			fmt.Println("location")
+		case item:
+			fmt.Println("item")
+		}
+	}
+	// Output:
+	// item
+	// location
+}
+
+func ExampleDecodeRequest() {
+	type Order struct {
+		ID   string `xml:"id,attr"`
+		Note string `xml:"note"`
+	}
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader("<order id=\"7\"><note>caf\xe9</note></order>"))
+	req.Header.Set("Content-Type", "application/xml; charset=ISO-8859-1")
+	var o Order
+	if err := gosax.DecodeRequest(req, &o, gosax.Limits{}); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(o.ID, o.Note)
+
+	req = httptest.NewRequest("POST", "/orders", strings.NewReader(`<order id="8"><note>too long</note></order>`))
+	req.Header.Set("Content-Type", "text/xml")
+	var tooLarge *http.MaxBytesError
+	err := gosax.DecodeRequest(req, &o, gosax.Limits{MaxBytes: 16})
+	fmt.Println(errors.As(err, &tooLarge))
+
+	req = httptest.NewRequest("POST", "/orders", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	err = gosax.DecodeRequest(req, &o, gosax.Limits{})
+	fmt.Println(errors.Is(err, gosax.ErrUnsupportedMediaType))
+	// Output:
+	// 7 café
+	// true
+	// true
+}
+
+func ExampleTable_WriteCSV() {
+	const data = `<catalog>
+  <book id="b1"><title>Go &amp; XML</title><author><name>Ann</name></author></book>
+  <book id="b2"><title><![CDATA[Streams, "quoted"]]></title></book>
+  <book id="b3"/>
+</catalog>`
+	t := &gosax.Table{
+		Row: "catalog/book",
+		Columns: []gosax.Column{
+			{Name: "id", Path: "@id"},
+			{Name: "title", Path: "title"},
+			{Name: "author", Path: "author/name"},
+		},
+	}
+	n, err := t.WriteCSV(os.Stdout, gosax.NewReader(strings.NewReader(data)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(n, "rows")
+	// Output:
+	// id,title,author
+	// b1,Go & XML,Ann
+	// b2,"Streams, ""quoted""",
+	// b3,,
+	// 3 rows
+}
+
+func ExampleSyntaxError() {
+	r := gosax.NewReader(strings.NewReader("<root>\n<item></iten></root>"))
+	r.CheckEndNames = true
+	for {
+		e, err := r.Event()
+		var serr *gosax.SyntaxError
+		if errors.As(err, &serr) {
+			fmt.Printf("%v at %d: %q\n", serr, serr.Offset, serr.PartialEvent)
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	// Output:
+	// gosax: end tag </iten> does not match start tag <item> at 13: "</iten>"
+}
+
+func ExampleMapInterner() {
+	in := gosax.MapInterner{}
+	r := gosax.NewReader(strings.NewReader(`<list><v status="active"/><v status="active"/></list>`))
+	var values []string
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() != gosax.EventStart {
+			continue
+		}
+		se, err := gosax.StartElementInterner(e.Bytes, in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, a := range se.Attr {
+			values = append(values, a.Value)
+		}
+	}
+	fmt.Println(values, unsafe.StringData(values[0]) == unsafe.StringData(values[1]))
+	// Output:
+	// [active active] true
+}
+
+func ExampleProfile() {
+	p := gosax.NewProfile()
+	for _, doc := range []string{
+		`<feed><entry id="1"><title>Hello</title></entry></feed>`,
+		`<?xml version="1.0" encoding="ISO-8859-1"?><feed><entry id="2"/><entry id="3"/></feed>`,
+	} {
+		if err := p.Add(gosax.NewReader(strings.NewReader(doc))); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Println(p.Documents, p.Encodings)
+	for _, path := range []string{"feed", "feed/entry", "feed/entry/@id", "feed/entry/title"} {
+		fmt.Printf("%s %+v\n", path, *p.Paths[path])
+	}
+	// Output:
+	// 2 map[ISO-8859-1:1 UTF-8:1]
+	// feed {Count:2 Bytes:98 MaxBytes:55 TextBytes:0}
+	// feed/entry {Count:3 Bytes:72 MaxBytes:42 TextBytes:0}
+	// feed/entry/@id {Count:3 Bytes:3 MaxBytes:1 TextBytes:0}
+	// feed/entry/title {Count:1 Bytes:20 MaxBytes:20 TextBytes:5}
+}
+
+func ExampleNewReaderAllocator() {
+	var pool gosax.PoolAllocator
+	var wg sync.WaitGroup
+	counts := make([]int, 4)
+	for i := range counts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc := strings.Repeat("<item>"+strings.Repeat("x", 1000)+"</item>", 100)
+			r := gosax.NewReaderAllocator(strings.NewReader("<list>"+doc+"</list>"), &pool, 4096)
+			defer r.Release()
+			for {
+				e, err := r.Event()
+				if err != nil {
+					log.Fatal(err)
+				}
+				if e.Type() == gosax.EventEOF {
+					break
+				}
+				if e.Type() == gosax.EventStart {
+					counts[i]++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Println(counts)
+	// Output:
+	// [101 101 101 101]
+}
+
+func ExampleStartElementAttrs() {
+	b := []byte(`<item id="42" class="a" style="b" data-x="c" title="Fish &amp; Chips">`)
+	se, err := gosax.StartElementAttrs(b, "id", "title")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, a := range se.Attr {
+		fmt.Printf("%s=%q\n", a.Name.Local, a.Value)
+	}
+	// Output:
+	// id="42"
+	// title="Fish & Chips"
+}
+
+func ExampleReader_TagWhitespace() {
+	const data = "<item\fid=\"1\" kind=\"a\"/>"
+	for _, mode := range []gosax.TagWhitespaceMode{gosax.TagWhitespaceLenient, gosax.TagWhitespaceStrict} {
+		r := gosax.NewReader(strings.NewReader(data))
+		r.TagWhitespace = mode
+		e, err := r.Event()
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		se, err := gosax.StartElement(e.Bytes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(se.Name.Local, se.Attr)
+		fmt.Printf("%q\n", r.Raw())
+	}
+	// Output:
+	// item [{{ id} 1} {{ kind} a}]
+	// "<item\fid=\"1\"\u00a0kind=\"a\"/>"
+	// gosax: invalid whitespace "\f" in tag at offset 5
+}
+
+// acuteComposer stands in for norm.NFC of golang.org/x/text/unicode/norm,
+// composing only "e" followed by U+0301 COMBINING ACUTE ACCENT.
+type acuteComposer struct{}
+
+func (acuteComposer) IsNormal(b []byte) bool {
+	return !bytes.Contains(b, []byte("e\u0301"))
+}
+
+func (acuteComposer) Append(out []byte, src ...byte) []byte {
+	return append(out, bytes.ReplaceAll(src, []byte("e\u0301"), []byte("\u00e9"))...)
+}
+
+func ExampleReader_Normalizer() {
+	r := gosax.NewReader(strings.NewReader("<name>Rene\u0301</name>"))
+	r.Normalizer = acuteComposer{}
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventText {
+			fmt.Printf("%+q %+q\n", e.Bytes, r.Raw())
+		}
+	}
+	// Output:
+	// "Ren\u00e9" "Rene\u0301"
+}
+
+func ExampleReader_ForEach() {
+	const data = `<feed xmlns:m="urn:media">
+  <entry id="1"><title>First</title><m:thumb url="a.png"/></entry>
+  <ad/>
+  <entry id="2"><title>Second</title></entry>
+  <entry id="3"/>
+</feed>`
+	type Entry struct {
+		ID    string `xml:"id,attr"`
+		Title string `xml:"title"`
+		Thumb struct {
+			URL string `xml:"url,attr"`
+		} `xml:"urn:media thumb"`
+	}
+	r := gosax.NewReader(strings.NewReader(data))
+	err := r.ForEach("feed/entry", func(s *gosax.Subtree) error {
+		if id, _ := gosax.StartElementAttrs(s.Start().Bytes, "id"); id.Attr[0].Value == "3" {
+			b, err := s.Bytes()
+			fmt.Printf("raw: %s\n", b)
+			return err
+		}
+		var e Entry
+		if err := s.Decode(&e); err != nil {
+			return err
+		}
+		fmt.Printf("%+v\n", e)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// {ID:1 Title:First Thumb:{URL:a.png}}
+	// {ID:2 Title:Second Thumb:{URL:}}
+	// raw: <entry id="3"/>
+}
+
+func ExampleDecoder_RegisterXSDType() {
+	type Cents int64
+	type Invoice struct {
+		Issued time.Time `xml:"issued" xsd:"xs:dateTime"`
+		Paid   bool      `xml:"paid,attr"`
+		Total  Cents     `xml:"total"`
+	}
+	const data = `<invoice paid="Y"><issued>2024-05-01T10:30:00</issued><total>12.34</total></invoice>`
+
+	d := gosax.NewDecoder(strings.NewReader(data))
+	d.RegisterXSDType("xs:dateTime", func(v reflect.Value, text []byte) error {
+		t, err := time.Parse("2006-01-02T15:04:05", string(text))
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	})
+	gosax.RegisterTypeFunc(d, func(text []byte) (bool, error) {
+		return string(text) == "Y", nil
+	})
+	gosax.RegisterTypeFunc(d, func(text []byte) (Cents, error) {
+		units, frac, _ := strings.Cut(string(text), ".")
+		n, err := strconv.ParseInt(units+frac, 10, 64)
+		return Cents(n), err
+	})
+	var inv Invoice
+	if err := d.Decode(&inv); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(inv.Issued.Format(time.DateTime), inv.Paid, inv.Total)
+	// Output:
+	// 2024-05-01 10:30:00 true 1234
+}
+
+func ExampleReader_DiscardUntilEnd() {
+	r := gosax.NewReader(strings.NewReader(`<msg><hdr>v1</hdr><body><a/><b>x</b></body><sig>s</sig></msg>`))
+	// Skip <msg> and the whole <hdr> element.
+	if _, err := r.Discard(4); err != nil {
+		log.Fatal(err)
+	}
+	e, _ := r.Event()
+	fmt.Printf("%s\n", e.Bytes)
+	// Skip the rest of <body>.
+	if err := r.DiscardUntilEnd(); err != nil {
+		log.Fatal(err)
+	}
+	e, _ = r.Event()
+	fmt.Printf("%s\n", e.Bytes)
+	n, err := r.Discard(10)
+	fmt.Println(n, err)
+	// Output:
+	// <body>
+	// <sig>
+	// 3 EOF
+}
+
+func ExampleReader_Discard() {
+	r := gosax.NewReader(strings.NewReader(`<?xml version="1.0" encoding="ISO-8859-1"?><doc a="1"> <b/></doc>`))
+	r.IndexAttrs = true
+	if _, err := r.Discard(1); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(r.XMLVersion(), r.Encoding())
+	if _, err := r.Discard(2); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(r.AttrSpans()))
+	// Output:
+	// 1.0 ISO-8859-1
+	// 0
+}
+
+func ExampleWriter_SortAttrs() {
+	var buf bytes.Buffer
+	w := gosax.NewWriter(&buf)
+	w.SortAttrs = true
+	w.StartElement([]byte("rec"))
+	w.Attr([]byte("z"), []byte("1"))
+	w.Attr([]byte("a"), []byte("<2>"))
+	w.Text([]byte("x"))
+	r := gosax.NewReader(strings.NewReader(`<item  id='7' class="c" data-x="&amp;"/>`))
+	e, _ := r.Event()
+	w.WriteEvent(e)
+	w.EndElement()
+	w.Flush()
+	fmt.Println(buf.String())
+	// Output:
+	// <rec a="&lt;2&gt;" z="1">x<item class="c" data-x="&amp;" id='7'/></rec>
+}
+
+func ExampleRecorder() {
+	rec := gosax.NewRecorder(gosax.NewReader(strings.NewReader(`<a x="1">hi<b/></a>`)))
+	for {
+		e, err := rec.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	fmt.Print(rec)
+
+	var buf bytes.Buffer
+	if _, err := rec.WriteTo(&buf); err != nil {
+		log.Fatal(err)
+	}
+	e, _ := gosax.ReplayEvents(&buf).Event()
+	fmt.Printf("replayed %s\n", e.Bytes)
+	// Output:
+	// EventStart "<a x=\"1\">"
+	// EventText "hi"
+	// EventStart "<b/>"
+	// EventEnd "</a>"
+	// EventEOF ""
+	// replayed <a x="1">
+}
+
+func ExampleDecoder_SetLimits() {
+	var b strings.Builder
+	b.WriteString("<root")
+	for i := range 5 {
+		fmt.Fprintf(&b, ` xmlns:p%d="urn:%d"`, i, i)
+	}
+	b.WriteString("/>")
+
+	var v struct{}
+	dec := gosax.NewDecoder(strings.NewReader(b.String()))
+	dec.SetLimits(gosax.Limits{MaxNamespaces: 4})
+	err := dec.Decode(&v)
+	var lerr *gosax.LimitError
+	if errors.As(err, &lerr) {
+		fmt.Println(lerr.Limit, lerr.Max, lerr.Offset)
+	}
+	fmt.Println(err)
+	// Output:
+	// MaxNamespaces 4 0
+	// gosax: MaxNamespaces of 4 exceeded at offset 0
+}
+
+func ExampleProcessMultistream() {
+	// A multistream archive of <mediawiki><page>...</page>...</mediawiki>,
+	// with its pages compressed in separate bzip2 streams.
+	archive, _ := base64.StdEncoding.DecodeString("QlpoOTFBWSZTWQbgIFcAAADJgAAQAAUmKgCAIAAiAD0hADCY1tAWC8XckU4UJAG4CBXAQlpoOTFBWSZTWWDMWh0AAAlfgAAQAACwBQCGAEAmpMQAIABQpkxMgyMCqp+lGp6jR6jxSyziOrUvmnhGGEIivfytGTLSI0fmWxunKIufxdyRThQkGDMWh0BCWmg5MUFZJlNZpIhlPQAABN+AABAAAIgFIAAIQCakRAAgACGp+qNA0ZBTAATRmoMZGHcQbtuVCiQlRPkioYXxdyRThQkKSIZT0EJaaDkxQVkmU1ntvnOqAAABWYAAEAAAgAUmKgCAIAAiBoaaCAaaaAJLFJda7xdyRThQkO2+c6o=")
+	const index = "51:1:Go\n51:2:XML\n140:3:SAX\n"
+
+	streams, err := gosax.ReadMultistreamIndex(strings.NewReader(index), int64(len(archive)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	titles := make([][]string, len(streams))
+	err = gosax.ProcessMultistream(bytes.NewReader(archive), streams, 2, func(i int, r *gosax.Reader) error {
+		var t []string
+		err := r.ForEach("page/title", func(s *gosax.Subtree) error {
+			b, err := s.Bytes()
+			t = append(t, string(b))
+			return err
+		})
+		titles[i] = t
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, s := range streams {
+		fmt.Println(s.Offset, titles[i])
+	}
+	// Output:
+	// 0 []
+	// 51 [<title>Go</title> <title>XML</title>]
+	// 140 [<title>SAX</title>]
+}
+
+// slowReader returns one byte per Read, after a delay.
+type slowReader struct {
+	s     string
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.s == "" {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	p[0] = r.s[0]
+	r.s = r.s[1:]
+	return 1, nil
+}
+
+func ExampleReader_EventTimeout() {
+	input := io.MultiReader(
+		strings.NewReader("<doc><rec>1</rec><rec>"),
+		&slowReader{s: "2</rec></doc>", delay: 50 * time.Millisecond},
+	)
+	r := gosax.NewReader(input)
+	r.EventTimeout = 20 * time.Millisecond
+	for {
+		e, err := r.Event()
+		var terr *gosax.EventTimeoutError
+		if errors.As(err, &terr) {
+			fmt.Println(terr.Type, terr.Offset, terr.Timeout())
+			fmt.Println(err)
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s\n", e.Bytes)
+	}
+	// Output:
+	// <doc>
+	// <rec>
+	// 1
+	// </rec>
+	// <rec>
+	// EventText 22 true
+	// gosax: EventText at offset 22 not read within 20ms
+}
+
+func ExampleReader_Encoding() {
+	const data = "\xef\xbb\xbf<?xml version=\"1.0\" encoding=\"utf-8\" standalone=\"yes\"?>\n<doc/>"
+	r := gosax.NewReader(strings.NewReader(data))
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventStart {
+			break
+		}
+	}
+	standalone, ok := r.Standalone()
+	fmt.Println(r.XMLVersion(), r.Encoding(), standalone, ok, r.HasBOM())
+	// Output:
+	// 1.0 utf-8 true true true
+}
+
+func ExampleIndexSnapshot() {
+	xmlData := `<feed><entry xml:id="a"><title>First</title></entry><entry xml:id="b"><title>Second</title></entry></feed>`
+	ix, err := gosax.BuildIndex(gosax.NewReader(strings.NewReader(xmlData)), "feed/entry")
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := ix.MarshalBinary()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// b would typically be written to a file and memory-mapped by workers.
+	s, err := gosax.NewIndexSnapshot(b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range s.NumRecords() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := s.Record(i)
+			if xmlData[rec.Offset] != '<' {
+				panic("bad record")
+			}
+		}()
+	}
+	wg.Wait()
+	off, _ := s.ID("b")
+	info, _ := s.Path("feed/entry/title")
+	fmt.Println(s.RecordPath(), s.NumRecords(), off, info.Count)
+	_, ok := s.ID("c")
+	fmt.Println(ok)
+	// Output:
+	// feed/entry 2 52 2
+	// false
+}
+
+func ExampleReader_NormalizeDTDAttrs() {
+	const data = `<!DOCTYPE doc [<!ATTLIST item refs IDREFS #IMPLIED title CDATA #IMPLIED>]>
+<doc><item refs="  a
+  b " title=" A  B "/><item refs="c &#x20;&#9; d&#32;"/></doc>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.NormalizeDTDAttrs = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if name, _ := gosax.Name(e.Bytes); e.Type() == gosax.EventStart && string(name) == "item" {
+			fmt.Printf("%s\n", e.Bytes)
+		}
+	}
+	// Output:
+	// <item refs="a b" title=" A  B "/>
+	// <item refs="c &#9; d"/>
+}
+
+func ExampleWriter_StartElementNS() {
+	const atom = "http://www.w3.org/2005/Atom"
+	var sb strings.Builder
+	w := gosax.NewWriter(&sb)
+	w.Prefixes = map[string]string{"urn:example:meta": "meta"}
+	w.StartElementNS(atom, "feed")
+	w.AttrNS("urn:example:meta", "version", []byte("2"))
+	w.StartElementNS(atom, "title")
+	w.Text([]byte("News"))
+	w.EndElement()
+	w.StartElementNS("urn:example:meta", "source")
+	w.EndElement()
+	w.EndElement()
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sb.String())
+	// Output:
+	// <feed xmlns="http://www.w3.org/2005/Atom" xmlns:meta="urn:example:meta" meta:version="2"><title>News</title><meta:source></meta:source></feed>
+}
+
+func ExampleReader_ResolveName() {
+	const data = `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:m="urn:meta"><m:tag m:id="1"/><entry xmlns:m="urn:other"><m:tag/></entry></feed>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.TrackNamespaces = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() != gosax.EventStart {
+			continue
+		}
+		name, attrs := gosax.Name(e.Bytes)
+		space, local := r.ResolveName(name)
+		fmt.Printf("{%s}%s\n", space, local)
+		for len(attrs) > 0 {
+			attr, rest, err := gosax.NextAttribute(attrs)
+			if err != nil || len(attr.Key) == 0 {
+				break
+			}
+			attrs = rest
+			space, local := r.ResolveAttrName(attr.Key)
+			fmt.Printf("\t@{%s}%s\n", space, local)
+		}
+	}
+	// Output:
+	// {http://www.w3.org/2005/Atom}feed
+	// 	@{http://www.w3.org/2000/xmlns/}xmlns
+	// 	@{http://www.w3.org/2000/xmlns/}m
+	// {urn:meta}tag
+	// 	@{urn:meta}id
+	// {http://www.w3.org/2005/Atom}entry
+	// 	@{http://www.w3.org/2000/xmlns/}m
+	// {urn:other}tag
+}
+
+func ExampleRoundTrip() {
+	data := []byte(`<order id='7'><!-- draft --><item>Tea &amp; cake</item></order>`)
+	rt := &gosax.RoundTrip{
+		// Rewrite every element with the Writer, dropping comments.
+		Transform: func(r *gosax.Reader, w *gosax.Writer) error {
+			for {
+				e, err := r.Event()
+				if err != nil {
+					return err
+				}
+				switch e.Type() {
+				case gosax.EventEOF:
+					return nil
+				case gosax.EventStart:
+					se, err := gosax.StartElement(e.Bytes)
+					if err != nil {
+						return err
+					}
+					w.WriteToken(se)
+				case gosax.EventEnd:
+					w.EndElement()
+				case gosax.EventText:
+					text, err := gosax.Unescape(e.Bytes)
+					if err != nil {
+						return err
+					}
+					w.Text(text)
+				}
+			}
+		},
+		Equivalent: true,
+	}
+	fmt.Println(rt.Check(data))
+	rt.IgnoreComments = true
+	fmt.Println(rt.Check(data))
+	// Output:
+	// gosax: round trip differs at event 1 (offset 14, output offset 14): "<!-- draft -->" != "<item>"
+	// <nil>
+}
+
+// bufferCloser is a bytes.Buffer with a Close method that prints it.
+type bufferCloser struct {
+	name string
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	fmt.Printf("%s: %q\n", b.name, b.String())
+	return nil
+}
+
+func ExampleTextSinks() {
+	const data = `<feed><entry id="1"><title>A</title><content>Very long &amp; large</content></entry><entry id="2"><content><![CDATA[<p>x</p>]]></content></entry></feed>`
+	t := gosax.NewTextSinks(gosax.NewReader(strings.NewReader(data)))
+	t.Add("feed/entry/content", func(path []byte, start gosax.Event) (io.WriteCloser, error) {
+		return &bufferCloser{name: string(path)}, nil
+	})
+	for {
+		e, err := t.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventText {
+			fmt.Printf("text %s\n", e.Bytes)
+		}
+	}
+	// Output:
+	// text A
+	// feed/entry/content: "Very long & large"
+	// feed/entry/content: "<p>x</p>"
+}
+
+func ExampleReader_OmitNamespaceDecls() {
+	const data = `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:m="urn:meta" lang="en"><m:tag id="1"/></feed>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.TrackNamespaces = true
+	r.OmitNamespaceDecls = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventStart {
+			name, _ := gosax.Name(e.Bytes)
+			space, _ := r.ResolveName(name)
+			fmt.Printf("%s %s\n", e.Bytes, space)
+		}
+	}
+	// Output:
+	// <feed lang="en"> http://www.w3.org/2005/Atom
+	// <m:tag id="1"/> urn:meta
+}
+
+func ExampleReader_CheckWellFormed() {
+	for _, data := range []string{
+		`<doc><item/></doc>`,
+		`<doc><item></doc>`,
+		`<doc/><doc/>`,
+		`<doc/>trailing`,
+		`<doc>`,
+	} {
+		r := gosax.NewReader(strings.NewReader(data))
+		r.CheckWellFormed = true
+		for {
+			e, err := r.Event()
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			if e.Type() == gosax.EventEOF {
+				fmt.Println("ok")
+				break
+			}
+		}
+	}
+	// Output:
+	// ok
+	// gosax: end tag </doc> does not match start tag <item>
+	// gosax: second root element <doc>
+	// gosax: text outside the root element
+	// gosax: unclosed start tag <doc>
+}
+
+func ExampleReader_Limits() {
+	const data = `<a><b><c><d/></c></b></a>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.Limits = gosax.Limits{MaxDepth: 3}
+	for {
+		e, err := r.Event()
+		var lerr *gosax.LimitError
+		if errors.As(err, &lerr) {
+			fmt.Println(lerr.Limit, lerr.Offset)
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	// Output:
+	// MaxDepth 9
+}
+
+func ExampleReader_Limits_tokenSize() {
+	// The text fits in the buffer of the Reader, but not in MaxTokenSize.
+	data := "<a>" + strings.Repeat("x", 10000) + "</a>"
+	r := gosax.NewReader(strings.NewReader(data))
+	r.Limits = gosax.Limits{MaxTokenSize: 100}
+	for {
+		e, err := r.Event()
+		var lerr *gosax.LimitError
+		if errors.As(err, &lerr) {
+			fmt.Println(lerr.Limit, lerr.Max, lerr.Offset)
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	// Output:
+	// MaxTokenSize 100 3
+}
+
+func ExampleReader_Spill() {
+	data := "<doc>" + strings.Repeat("x", 10000) + "<b/></doc>"
+	r := gosax.NewReaderSize(strings.NewReader(data), 4096)
+	r.Limits = gosax.Limits{MaxTokenSize: 4096}
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	r.Spill = func() (gosax.SpillFile, error) {
+		f, err := os.CreateTemp("", "gosax")
+		if err == nil {
+			files = append(files, f)
+		}
+		return f, err
+	}
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if sr := r.Spilled(); sr != nil {
+			b, _ := io.ReadAll(sr)
+			fmt.Println("spilled", len(b), strings.Trim(string(b), "x") == "")
+			continue
+		}
+		fmt.Println(e.Type(), string(e.Bytes))
+	}
+	// Output:
+	// EventStart <doc>
+	// spilled 10000 true
+	// EventStart <b/>
+	// EventEnd </doc>
+}
+
+func ExampleReader_Spill_buffered() {
+	// The text fits in the buffer of the Reader, but not in MaxTokenSize.
+	data := "<doc>" + strings.Repeat("x", 10000) + "</doc>"
+	r := gosax.NewReader(strings.NewReader(data))
+	r.Limits = gosax.Limits{MaxTokenSize: 100}
+	var spill bytes.Buffer
+	r.Spill = func() (gosax.SpillFile, error) {
+		spill.Reset()
+		return bufferFile{&spill}, nil
+	}
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if sr := r.Spilled(); sr != nil {
+			fmt.Println("spilled", sr.Size())
+			continue
+		}
+		fmt.Println(e.Type(), string(e.Bytes))
+	}
+	// Output:
+	// EventStart <doc>
+	// spilled 10000
+	// EventEnd </doc>
+}
+
+// bufferFile is a SpillFile in memory.
+type bufferFile struct {
+	*bytes.Buffer
+}
+
+func (f bufferFile) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(f.Bytes()).ReadAt(p, off)
+}
+
+func ExampleDTD_ExpandEntity() {
+	const doctype = `<!DOCTYPE lolz [
+  <!ENTITY lol "lol">
+  <!ENTITY lol2 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;">
+  <!ENTITY lol3 "&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;">
+  <!ENTITY lol4 "&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;">
+]>`
+	dtd, err := gosax.ParseDTD([]byte(doctype))
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := dtd.ExpandEntity("lol2", gosax.Limits{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(s)
+	_, err = dtd.ExpandEntity("lol4", gosax.Limits{MaxEntityExpansion: 1000})
+	fmt.Println(err)
+	// Output:
+	// lollollollollollollollollollol
+	// gosax: MaxEntityExpansion of 1000 exceeded
+}
+
+func ExampleJoin() {
+	const data = `<feed><entry sku="a1"/><entry sku="b2"/><entry sku="c3"/></feed>`
+	prices := map[string]string{"a1": "120", "c3": "80"}
+	j := &gosax.Join[string, string]{
+		Pattern: "feed/entry",
+		Key: func(subtree []byte) (string, error) {
+			start := subtree[:bytes.IndexByte(subtree, '>')+1]
+			se, err := gosax.StartElement(start)
+			if err != nil {
+				return "", err
+			}
+			return se.Attr[0].Value, nil
+		},
+		Lookup: func(keys []string) (map[string]string, error) {
+			fmt.Println("lookup", keys)
+			found := make(map[string]string)
+			for _, k := range keys {
+				if p, ok := prices[k]; ok {
+					found[k] = p
+				}
+			}
+			return found, nil
+		},
+		Inject: func(w *gosax.Writer, price string) error {
+			return w.Attr([]byte("price"), []byte(price))
+		},
+		BatchSize: 2,
+	}
+	var out strings.Builder
+	r := gosax.NewReader(strings.NewReader(data))
+	if err := j.Copy(gosax.NewWriter(&out), r); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(out.String())
+	// Output:
+	// lookup [a1 b2]
+	// lookup [c3]
+	// <feed><entry sku="a1" price="120"></entry><entry sku="b2"/><entry sku="c3" price="80"></entry></feed>
+}
+
+func ExampleUnescapeEntities() {
+	html := gosax.EntityMap{"nbsp": '\u00a0', "copy": '©'}
+	b, err := gosax.UnescapeEntities([]byte("&copy;&nbsp;2024 &lt;gosax&gt;"), html)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%q\n", b)
+	// Output:
+	// "©\u00a02024 <gosax>"
+}
+
+func ExampleNewReaderBudget() {
+	data := "<doc>" + strings.Repeat("x", 64<<10) + "</doc>"
+	r := gosax.NewReaderBudget(strings.NewReader(data), 64<<10)
+	for {
+		e, err := r.Event()
+		var lerr *gosax.LimitError
+		if errors.As(err, &lerr) {
+			fmt.Println(lerr.Limit, lerr.Max)
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	// Output:
+	// MaxTokenSize 31744
+}
+
+func ExampleNewReaderBudget_docType() {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE doc [")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, `<!ENTITY e%d "entity number %d">`, i, i)
+	}
+	b.WriteString("]><doc/>")
+	r := gosax.NewReaderBudget(strings.NewReader(b.String()), 64<<10)
+	r.ExpandEntities = true
+	for {
+		e, err := r.Event()
+		var lerr *gosax.LimitError
+		if errors.As(err, &lerr) {
+			fmt.Println(lerr.Limit, lerr.Max)
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+	}
+	// Output:
+	// MaxDocTypeSize 4096
+}
+
+func ExampleReader_ExpandEntities() {
+	const data = `<!DOCTYPE doc [<!ENTITY co "Example &amp; Co">]><doc owner="&co;">&co; &#169; 2024</doc>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.ExpandEntities = true
+	r.UnescapeText = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		switch e.Type() {
+		case gosax.EventStart:
+			se, err := gosax.StartElement(e.Bytes)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(se.Attr[0].Value)
+		case gosax.EventText:
+			fmt.Println(string(e.Bytes))
+		case gosax.EventEOF:
+			return
+		}
+	}
+	// Output:
+	// Example & Co
+	// Example & Co © 2024
+}
+
+func ExampleReader_DetectEncoding() {
+	// "<a>héllo</a>" in UTF-16LE with a byte order mark.
+	data := "\xff\xfe<\x00a\x00>\x00h\x00\xe9\x00l\x00l\x00o\x00<\x00/\x00a\x00>\x00"
+	r := gosax.NewReader(strings.NewReader(data))
+	r.DetectEncoding = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Println(string(e.Bytes))
+	}
+	// Output:
+	// <a>
+	// héllo
+	// </a>
+}
+
+func ExampleSyncReader() {
+	const data = `<jobs><job>1</job><job>2</job><job>3</job><job>4</job></jobs>`
+	sr := gosax.NewSyncReader(gosax.NewReader(strings.NewReader(data)))
+	var mu sync.Mutex
+	var done []gosax.Event
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				e, err := sr.Event()
+				if err != nil {
+					log.Fatal(err)
+				}
+				if e.Type() == gosax.EventEOF {
+					return
+				}
+				if e.Type() == gosax.EventText {
+					mu.Lock()
+					done = append(done, e)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// The sequence numbers restore the order of the stream.
+	sort.Slice(done, func(i, j int) bool { return done[i].Seq() < done[j].Seq() })
+	for _, e := range done {
+		fmt.Println(e.Seq(), string(e.Bytes))
+	}
+	// Output:
+	// 3 1
+	// 6 2
+	// 9 3
+	// 12 4
+}
+
+func ExampleReader_CharsetReader() {
+	const data = "<?xml version=\"1.0\" encoding=\"windows-1252\"?><price>\x80 5</price>"
+	r := gosax.NewReader(strings.NewReader(data))
+	r.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if !strings.EqualFold(charset, "windows-1252") {
+			return nil, fmt.Errorf("unsupported charset %s", charset)
+		}
+		// A real program would use golang.org/x/text/encoding/charmap.
+		b, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for _, c := range b {
+			if c == 0x80 {
+				sb.WriteRune('€')
+			} else {
+				sb.WriteRune(rune(c))
+			}
+		}
+		return strings.NewReader(sb.String()), nil
+	}
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventText {
+			fmt.Println(string(e.Bytes))
+		}
+	}
+	// Output:
+	// € 5
+}
+
+func ExampleEncodeFrames() {
+	const data = `<feed><entry>a</entry><entry>b</entry></feed>`
+	// The frames would be sent as messages, for example over a gRPC stream.
+	var frames [][]byte
+	err := gosax.EncodeFrames(gosax.NewReader(strings.NewReader(data)), 16, func(frame []byte) error {
+		frames = append(frames, bytes.Clone(frame))
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(frames), "frames")
+
+	events := gosax.DecodeFrames(func() ([]byte, error) {
+		if len(frames) == 0 {
+			return nil, io.EOF
+		}
+		frame := frames[0]
+		frames = frames[1:]
+		return frame, nil
+	})
+	var out strings.Builder
+	if err := gosax.CopyEvents(gosax.NewWriter(&out), events); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(out.String())
+	// Output:
+	// 5 frames
+	// <feed><entry>a</entry><entry>b</entry></feed>
+}
+
+func ExampleReader_ElementContent() {
+	const data = `<doc>
+  <meta>
+    <title>Notes</title>
+  </meta>
+  <p>See <b>this</b> <i>now</i></p>
+</doc>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.ElementContent = []string{"doc", "doc/meta"}
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventText {
+			fmt.Printf("%q\n", e.Bytes)
+		}
+	}
+	// Output:
+	// "Notes"
+	// "See "
+	// "this"
+	// " "
+	// "now"
+}
+
+func ExampleReader_CheckChars() {
+	const data = "<name>caf\xe9</name>"
+	r := gosax.NewReader(strings.NewReader(data))
+	r.CheckChars = true
+	for {
+		e, err := r.Event()
+		var serr *gosax.SyntaxError
+		if errors.As(err, &serr) {
+			fmt.Println(serr.Msg, "at offset", serr.Offset)
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			return
+		}
+	}
+	// Output:
+	// invalid UTF-8 at offset 9
+}
+
+func ExampleTruncate() {
+	b := []byte("Tōkyō &amp; Ōsaka")
+	for _, n := range []int{2, 9, 12, 13} {
+		fmt.Printf("%q\n", gosax.Truncate(b, n))
+	}
+	// Output:
+	// "T"
+	// "Tōkyō "
+	// "Tōkyō "
+	// "Tōkyō &amp;"
+}
+
+func ExampleReader_Lenient() {
+	const data = `<ul class=menu><li>Fish & Chips<br><li checked>Tea</ul>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.Lenient = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Printf("%s\n", e.Bytes)
+	}
+	// Output:
+	// <ul class="menu">
+	// <li>
+	// Fish &amp; Chips
+	// <br/>
+	// </li>
+	// <li checked="checked">
+	// Tea
+	// </li>
+	// </ul>
+}
+
+func ExampleReader_Lenient_trimText() {
+	const data = "<ul>\n<li>a\n<li>b\n<br>\n<img src=x>\n</ul>"
+	r := gosax.NewReader(strings.NewReader(data))
+	r.Lenient = true
+	r.TrimText = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Printf("%s\n", e.Bytes)
+	}
+	// Output:
+	// <ul>
+	// <li>
+	// a
+	// </li>
+	// <li>
+	// b
+	// <br/>
+	// <img src="x"/>
+	// </li>
+	// </ul>
+}
+
+func ExampleReader_SkipWhitespaceText() {
+	const data = `<doc>
+  <title> Notes </title>
+  <p>See <b>this</b></p>
+</doc>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.SkipWhitespaceText = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventText {
+			fmt.Printf("%q\n", e.Bytes)
+		}
+	}
+	// Output:
+	// " Notes "
+	// "See "
+	// "this"
+}
+
+func ExampleReader_ExpandCDATA() {
+	const data = `<code>if a &lt; b<![CDATA[ && b < c]]></code>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.ExpandCDATA = true
+	r.UnescapeText = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if e.Type() == gosax.EventText {
+			fmt.Printf("%q\n", e.Bytes)
+		}
+	}
+	// Output:
+	// "if a < b"
+	// " && b < c"
+}
+
+func ExampleReader_CheckCDataEnd() {
+	const data = `<doc><!-- ok --><p>a]]>b</p></doc>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.CheckComments = true
+	r.CheckCDataEnd = true
+	for {
+		e, err := r.Event()
+		var serr *gosax.SyntaxError
+		if errors.As(err, &serr) {
+			fmt.Println(serr.Msg, "at offset", serr.Offset)
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			return
+		}
+	}
+	// Output:
+	// text contains "]]>" at offset 20
+}
+
+func ExampleParseXMLDecl() {
+	const data = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><?pi data?><doc/>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.EmitXMLDecl = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		fmt.Println(e.Type())
+		if e.Type() == gosax.EventXMLDecl {
+			d, err := gosax.ParseXMLDecl(e.Bytes)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(d.Version, d.Encoding, d.Standalone)
+		}
+	}
+	// Output:
+	// EventXMLDecl
+	// 1.0 UTF-8 yes
+	// EventProcessingInstruction
+	// EventStart
+}
+
+func ExampleReader_ExtractSubtree() {
+	const data = `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<entry><title>A</title><media:thumbnail url="a.png"/></entry>
+</feed>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.TrackNamespaces = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Type() == gosax.EventEOF {
+			break
+		}
+		if name, _ := gosax.Name(e.Bytes); e.Type() == gosax.EventStart && string(name) == "entry" {
+			if err := r.ExtractSubtree(os.Stdout, true); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	// Output:
+	// <?xml version="1.0" encoding="UTF-8"?>
+	// <entry xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/"><title>A</title><media:thumbnail url="a.png"/></entry>
+}
+
+func ExampleSniffRoot() {
+	const data = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE rss>
+<rss xmlns="http://backend.userland.com/rss2" version="2.0"><channel/></rss>`
+	info, r, err := gosax.SniffRoot(strings.NewReader(data), 512)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(info.Name, info.Space, info.Encoding, info.DocType)
+	b, err := io.ReadAll(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(b) == len(data))
+	// Output:
+	// rss http://backend.userland.com/rss2 UTF-8 rss
+	// true
+}
+
+func ExampleReader_AttrSpans() {
+	const data = `<item id="42" title='Fish &amp; Chips'/>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.IndexAttrs = true
+	e, err := r.Event()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range r.AttrSpans() {
+		fmt.Printf("%s=%s\n", e.Bytes[s.NameStart:s.NameEnd], e.Bytes[s.ValueStart:s.ValueEnd])
+	}
+	// Output:
+	// id=42
+	// title=Fish &amp; Chips
+}
+
+func ExampleProfile_WriteGoTypes() {
+	p := gosax.NewProfile()
+	p.InferTypes = true
+	for _, doc := range []string{
+		`<feed><entry id="1"><title>Hello</title><status>draft</status><tag>a</tag><tag>b</tag></entry></feed>`,
+		`<feed><entry id="2"><title>World</title><status>draft</status><updated>2024-05-01T10:00:00Z</updated></entry><entry id="3"><status>public</status><tag>c</tag></entry></feed>`,
+		`<feed><entry id="4"><status>public</status><score>4.5</score></entry></feed>`,
+	} {
+		if err := p.Add(gosax.NewReader(strings.NewReader(doc))); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := p.WriteGoTypes(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// type Feed struct {
+	// 	XMLName xml.Name `xml:"feed"`
+	// 	Entry   []Entry  `xml:"entry"`
+	// }
+	//
+	// type Entry struct {
+	// 	Id      int64     `xml:"id,attr"`
+	// 	Score   float64   `xml:"score"`
+	// 	Status  string    `xml:"status"` // "draft", "public"
+	// 	Tag     []string  `xml:"tag"`
+	// 	Title   string    `xml:"title"`
+	// 	Updated time.Time `xml:"updated"`
+	// }
+}
+
+func ExampleReader_Depth() {
+	const data = `<doc><p>See <br/>this</p></doc>`
+	for _, emit := range []bool{false, true} {
+		r := gosax.NewReader(strings.NewReader(data))
+		r.EmitSelfClosingTag = emit
+		var depths []int
+		for {
+			e, err := r.Event()
+			if err != nil {
+				log.Fatal(err)
+			}
+			if e.Type() == gosax.EventEOF {
+				break
+			}
+			depths = append(depths, r.Depth())
+		}
+		fmt.Println(depths)
+	}
+	// Output:
+	// [1 2 2 3 2 2 1]
+	// [1 2 2 3 3 2 2 1]
+}
+
+func ExampleReader_Limits_malformed() {
+	r := gosax.NewReader(strings.NewReader(`<a x=></a>`))
+	r.Limits = gosax.Limits{MaxAttrs: 8}
+	_, err := r.Event()
+	fmt.Println(err)
+	// Output:
+	// gosax: missing value of attribute "x"
+}
+
+func ExampleReader_TrackNamespaces_malformed() {
+	r := gosax.NewReader(strings.NewReader(`<a xmlns=></a>`))
+	r.TrackNamespaces = true
+	_, err := r.Event()
+	fmt.Println(err)
+	// Output:
+	// gosax: missing value of attribute "xmlns"
+}
+
+func ExampleReader_OmitNamespaceDecls_malformed() {
+	r := gosax.NewReader(strings.NewReader(`<a xmlns:p="urn:p" x=></a>`))
+	r.OmitNamespaceDecls = true
+	_, err := r.Event()
+	fmt.Println(err)
+	// Output:
+	// gosax: missing value of attribute "x"
+}
+
+func ExampleReader_ApplyDTDDefaults_malformed() {
+	const data = `<!DOCTYPE a [<!ATTLIST a lang CDATA "en">]><a x=></a>`
+	r := gosax.NewReader(strings.NewReader(data))
+	r.ApplyDTDDefaults = true
+	for {
+		e, err := r.Event()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if e.Type() == gosax.EventEOF {
+			return
+		}
+	}
+	// Output:
+	// gosax: missing value of attribute "x"
+}
+
+func ExampleReader_DetectEncoding_malformed() {
+	r := gosax.NewReader(strings.NewReader(`<?xml version="1.0" encoding=?><a/>`))
+	r.DetectEncoding = true
+	_, err := r.Event()
+	fmt.Println(err)
+	// Output:
+	// gosax: invalid XML declaration
+}
@@ -0,0 +1,142 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// expandEntities applies ExpandEntities to ev.
+func (r *Reader) expandEntities(ev Event) (Event, error) {
+	switch ev.Type() {
+	case EventDocType:
+		dtd, err := ParseDTD(ev.Bytes)
+		if err != nil {
+			return ev, r.eventError(ev.Bytes, "%s", strings.TrimPrefix(err.Error(), "gosax: "))
+		}
+		r.dtd = dtd
+		r.dtdSize = dtdSize(dtd)
+		limits := r.Limits.resolve()
+		if r.limited {
+			limits = r.limits
+		}
+		r.entities = entityExpander{
+			entities: dtd.Entities,
+			max:      limits.MaxEntityExpansion,
+		}
+		return ev, nil
+	case EventText, EventEntityRef, EventStart:
+	default:
+		return ev, nil
+	}
+	if len(r.entities.entities) == 0 || bytes.IndexByte(ev.Bytes, '&') < 0 {
+		return ev, nil
+	}
+	b, ok, err := r.appendExpanded(r.expanded[:0], ev.Bytes, ev.Type() == EventStart)
+	if err != nil {
+		var lerr *LimitError
+		if errors.As(err, &lerr) {
+			lerr.Offset = r.EventOffset()
+			return ev, lerr
+		}
+		return ev, r.eventError(ev.Bytes, "%s", strings.TrimPrefix(err.Error(), "gosax: "))
+	}
+	if !ok {
+		return ev, nil
+	}
+	r.expanded = b
+	if r.raw == nil {
+		r.raw = ev.Bytes
+	}
+	ev.Bytes = b
+	if ev.Type() == EventEntityRef {
+		ev.value = ev.value&^0xff | uint64(EventText)
+	}
+	return ev, nil
+}
+
+// appendExpanded appends b to dst with the references to internal entities
+// expanded, and reports whether there were any. In a tag, only references
+// in attribute values are expanded.
+func (r *Reader) appendExpanded(dst, b []byte, tag bool) ([]byte, bool, error) {
+	var quote byte
+	expanded := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if tag {
+			switch {
+			case quote == 0 && (c == '"' || c == '\''):
+				quote = c
+			case c == quote:
+				quote = 0
+			}
+		}
+		if c != '&' || tag && quote == 0 {
+			continue
+		}
+		end := refEnd(b[i+1:])
+		if end <= 0 {
+			continue
+		}
+		name := b[i+1 : i+1+end]
+		if _, ok := r.entities.entities[string(name)]; !ok {
+			continue
+		}
+		var err error
+		r.expansion, err = r.entities.expand(r.expansion[:0], string(name))
+		if err != nil {
+			return nil, false, err
+		}
+		dst = append(dst, b[:i]...)
+		dst = appendExpansion(dst, r.expansion, quote)
+		b = b[i+end+2:]
+		i = -1
+		expanded = true
+	}
+	return append(dst, b...), expanded, nil
+}
+
+// appendExpansion appends the replacement text v, escaping the characters
+// that would be read as markup or end the attribute value delimited by
+// quote.
+func appendExpansion(dst, v []byte, quote byte) []byte {
+	for _, c := range v {
+		switch {
+		case c == '<':
+			dst = append(dst, "&lt;"...)
+		case c == quote && c == '"':
+			dst = append(dst, "&quot;"...)
+		case c == quote && c == '\'':
+			dst = append(dst, "&apos;"...)
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
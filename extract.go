@@ -0,0 +1,153 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+)
+
+// ExtractSubtree writes the element started by the last EventStart as a
+// standalone document to w, consuming the Reader through the matching end
+// tag. The namespaces declared by ancestors of the element and used by
+// its element or attribute names are declared again on its start tag, so
+// that it does not have unbound prefixes. If xmlDecl is true, the document
+// starts with an XML declaration.
+//
+// ExtractSubtree requires TrackNamespaces and does not work with
+// OmitNamespaceDecls. The element is held in memory as a whole.
+func (r *Reader) ExtractSubtree(w io.Writer, xmlDecl bool) error {
+	if r.last.Type() != EventStart {
+		return errors.New("gosax: ExtractSubtree called without EventStart")
+	}
+	if !r.TrackNamespaces || r.OmitNamespaceDecls {
+		return errors.New("gosax: ExtractSubtree requires TrackNamespaces without OmitNamespaceDecls")
+	}
+	// The bindings of the ancestors are those in scope but for the frame
+	// of the element.
+	inherited := make(map[string]string)
+	r.ns.visible(func(b nsBinding) {
+		inherited[b.prefix] = b.uri
+	})
+	for _, b := range r.ns.frame() {
+		delete(inherited, b.prefix)
+	}
+
+	name, _ := Name(r.last.Bytes)
+	end := len("<") + len(name)
+	sr, err := r.SubtreeReader()
+	if err != nil {
+		return err
+	}
+	subtree, err := io.ReadAll(sr)
+	if err != nil {
+		return err
+	}
+	used, err := usedPrefixes(subtree)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	if xmlDecl {
+		version, encoding := r.XMLVersion(), r.Encoding()
+		if version == "" {
+			version = "1.0"
+		}
+		if encoding == "" || r.DetectEncoding || r.CharsetReader != nil {
+			// Transcoded input is UTF-8.
+			encoding = "UTF-8"
+		}
+		buf = append(buf, `<?xml version="`...)
+		buf = escapeAttr(buf, []byte(version))
+		buf = append(buf, `" encoding="`...)
+		buf = escapeAttr(buf, []byte(encoding))
+		buf = append(buf, "\"?>\n"...)
+	}
+	buf = append(buf, subtree[:end]...)
+	for _, prefix := range used {
+		uri, ok := inherited[prefix]
+		if !ok || prefix == "" && uri == "" {
+			continue
+		}
+		buf = append(buf, " xmlns"...)
+		if prefix != "" {
+			buf = append(buf, ':')
+			buf = append(buf, prefix...)
+		}
+		buf = append(buf, `="`...)
+		buf = escapeAttr(buf, []byte(uri))
+		buf = append(buf, '"')
+	}
+	buf = append(buf, subtree[end:]...)
+	_, err = w.Write(buf)
+	return err
+}
+
+// usedPrefixes returns the sorted namespace prefixes of the element and
+// attribute names of the document b, with "" for unprefixed element names.
+func usedPrefixes(b []byte) ([]string, error) {
+	seen := make(map[string]bool)
+	r := NewReaderBuf(bytes.NewReader(b), make([]byte, 0, len(b)+minReadSize))
+	for {
+		ev, err := r.Event()
+		if err != nil {
+			return nil, err
+		}
+		switch ev.Type() {
+		case EventEOF:
+			prefixes := make([]string, 0, len(seen))
+			for prefix := range seen {
+				prefixes = append(prefixes, prefix)
+			}
+			slices.Sort(prefixes)
+			return prefixes, nil
+		case EventStart:
+			name, attrs := Name(ev.Bytes)
+			prefix, _ := splitQName(name)
+			seen[string(prefix)] = true
+			for len(attrs) > 0 {
+				attr, rest, err := NextAttribute(attrs)
+				if err != nil {
+					return nil, err
+				}
+				if len(attr.Key) == 0 {
+					break
+				}
+				attrs = rest
+				if _, ok := nsDecl(attr.Key); ok {
+					continue
+				}
+				if prefix, _ := splitQName(attr.Key); prefix != nil {
+					seen[string(prefix)] = true
+				}
+			}
+		}
+	}
+}
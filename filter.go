@@ -0,0 +1,107 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+// Filter stacks a processing stage on an EventReader: the returned
+// EventReader consumes the events of r and produces new ones.
+// Filters can be distributed as reusable components and combined with Chain.
+type Filter func(r EventReader) EventReader
+
+// Chain applies filters to r in order, so the first filter reads directly
+// from r and the last produces the events of the returned EventReader.
+// The result can be consumed directly, by a Parser, or by CopyEvents.
+func Chain(r EventReader, filters ...Filter) EventReader {
+	for _, f := range filters {
+		r = f(r)
+	}
+	return r
+}
+
+// FilterFunc returns a Filter that calls fn for each event read from the
+// underlying EventReader. fn passes events downstream by calling emit any
+// number of times, which lets it drop, replace or insert events.
+// EventEOF is passed to fn like any other event.
+//
+// Emitted events must stay valid until fn is called again; the events
+// passed to fn satisfy this.
+func FilterFunc(fn func(e Event, emit func(Event)) error) Filter {
+	return func(r EventReader) EventReader {
+		f := &funcFilter{r: r, fn: fn}
+		f.emit = func(e Event) {
+			f.queue = append(f.queue, e)
+		}
+		return f
+	}
+}
+
+type funcFilter struct {
+	r     EventReader
+	fn    func(Event, func(Event)) error
+	emit  func(Event)
+	queue []Event
+	head  int
+	eof   bool
+}
+
+func (f *funcFilter) Event() (Event, error) {
+	for f.head == len(f.queue) {
+		if f.eof {
+			return Event{value: uint64(EventEOF)}, nil
+		}
+		f.queue = f.queue[:0]
+		f.head = 0
+		e, err := f.r.Event()
+		if err != nil {
+			return Event{}, err
+		}
+		if e.Type() == EventEOF {
+			f.eof = true
+		}
+		if err := f.fn(e, f.emit); err != nil {
+			return Event{}, err
+		}
+	}
+	e := f.queue[f.head]
+	f.head++
+	return e, nil
+}
+
+// CopyEvents writes the events of r to w until EventEOF and flushes w.
+func CopyEvents(w *Writer, r EventReader) error {
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return err
+		}
+		if e.Type() == EventEOF {
+			return w.Flush()
+		}
+		if err := w.WriteEvent(e); err != nil {
+			return err
+		}
+	}
+}
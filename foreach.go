@@ -0,0 +1,142 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+// ForEach reads r to EOF and calls fn for each element whose path matches
+// pattern, such as "feed/entry" or "*/item". fn may consume the element
+// once with the methods of Subtree; otherwise it is skipped when fn
+// returns. Matching elements are not searched for nested matches.
+//
+// The first error returned by r or fn stops ForEach and is returned.
+func (r *Reader) ForEach(pattern string, fn func(s *Subtree) error) error {
+	s := &Subtree{d: decodeState{r: r}}
+	var path elementPath
+	for {
+		e, err := s.d.next()
+		if err != nil {
+			return err
+		}
+		path.update(e)
+		switch e.Type() {
+		case EventEOF:
+			return nil
+		case EventStart:
+			if !matchPath(pattern, path.bytes()) {
+				continue
+			}
+			s.start = e
+			s.selfClosing = isSelfClosing(e.Bytes)
+			s.consumed = false
+			if err := fn(s); err != nil {
+				return err
+			}
+			if !s.consumed {
+				if err := s.d.skip(e.Bytes); err != nil {
+					return err
+				}
+			}
+			if !s.selfClosing {
+				path.pop()
+			}
+		}
+	}
+}
+
+// A Subtree is an element matched by ForEach.
+type Subtree struct {
+	d           decodeState
+	start       Event
+	selfClosing bool
+	consumed    bool
+}
+
+// Start returns the start tag of the element. It is valid until the
+// element is consumed.
+func (s *Subtree) Start() Event {
+	return s.start
+}
+
+// Decode stores the element in the value pointed to by v, like
+// Decoder.DecodeElement. Namespaces declared by its ancestors are in scope.
+func (s *Subtree) Decode(v any) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return errors.New("gosax: Decode requires a non-nil pointer")
+	}
+	if err := s.consume(); err != nil {
+		return err
+	}
+	return s.d.unmarshal(val.Elem(), s.start.Bytes)
+}
+
+// Bytes returns a copy of the raw bytes of the element, through its end
+// tag.
+func (s *Subtree) Bytes() ([]byte, error) {
+	if err := s.consume(); err != nil {
+		return nil, err
+	}
+	sr, err := s.d.r.SubtreeReader()
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(sr)
+	s.popScope()
+	return b, err
+}
+
+// Walk calls fn for each event of the content of the element, up to and
+// including its end tag, like SkipFunc.
+func (s *Subtree) Walk(fn func(Event) error) error {
+	if err := s.consume(); err != nil {
+		return err
+	}
+	err := s.d.r.walkSubtree(fn)
+	s.popScope()
+	return err
+}
+
+func (s *Subtree) consume() error {
+	if s.consumed {
+		return errors.New("gosax: subtree already consumed")
+	}
+	s.consumed = true
+	return nil
+}
+
+// popScope pops the namespace frame of the element after it was read
+// without the decoder.
+func (s *Subtree) popScope() {
+	if !s.selfClosing && len(s.d.ns.marks) > 0 {
+		s.d.ns.pop()
+	}
+}
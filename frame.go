@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// A frame holds a batch of events for transports that carry messages,
+// such as gRPC streams: one record per event, made of the event type and
+// sequence number and the length of its bytes as uvarints, followed by the
+// bytes. The last frame of a stream ends with the EventEOF record.
+const (
+	// DefaultFrameSize is the frame size used by EncodeFrames for a size
+	// that is not positive.
+	DefaultFrameSize = 32 << 10
+
+	// maxFrameSize bounds the length written by WriteFramed and read by
+	// ReadFramed.
+	maxFrameSize = 1 << 30
+)
+
+// EncodeFrames reads the events of r up to and including EventEOF and
+// passes them to emit in frames of about size bytes. An event larger than
+// size gets a frame of its own. The frame is only valid during the call
+// to emit.
+func EncodeFrames(r EventReader, size int, emit func(frame []byte) error) error {
+	if size <= 0 {
+		size = DefaultFrameSize
+	}
+	var frame []byte
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return err
+		}
+		if len(frame) > 0 && len(frame)+len(e.Bytes) > size {
+			if err := emit(frame); err != nil {
+				return err
+			}
+			frame = frame[:0]
+		}
+		frame = binary.AppendUvarint(frame, e.value)
+		frame = binary.AppendUvarint(frame, uint64(len(e.Bytes)))
+		frame = append(frame, e.Bytes...)
+		if e.Type() == EventEOF {
+			return emit(frame)
+		}
+	}
+}
+
+// DecodeFrames returns an EventReader of the events in the frames returned
+// by next, such as the Recv method of a stream, until the EventEOF record.
+// The events keep the sequence numbers they had when encoded. As with
+// Reader, an Event is only valid until the next call.
+func DecodeFrames(next func() ([]byte, error)) EventReader {
+	return &frameDecoder{next: next}
+}
+
+type frameDecoder struct {
+	next  func() ([]byte, error)
+	frame []byte
+	done  bool
+	err   error
+}
+
+var (
+	errCorruptFrame  = errors.New("gosax: corrupt event frame")
+	errFrameTooLarge = errors.New("gosax: event frame too large")
+)
+
+func (d *frameDecoder) Event() (Event, error) {
+	if d.err != nil {
+		return Event{}, d.err
+	}
+	if d.done {
+		return Event{value: uint64(EventEOF)}, nil
+	}
+	for len(d.frame) == 0 {
+		frame, err := d.next()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			d.err = err
+			return Event{}, err
+		}
+		d.frame = frame
+	}
+	value, n := binary.Uvarint(d.frame)
+	if n <= 0 {
+		d.err = errCorruptFrame
+		return Event{}, d.err
+	}
+	d.frame = d.frame[n:]
+	size, n := binary.Uvarint(d.frame)
+	if n <= 0 || size > uint64(len(d.frame)-n) {
+		d.err = errCorruptFrame
+		return Event{}, d.err
+	}
+	e := Event{Bytes: d.frame[n : n+int(size)], value: value}
+	if !e.Type().Valid() {
+		d.err = errCorruptFrame
+		return Event{}, d.err
+	}
+	d.frame = d.frame[n+int(size):]
+	d.done = e.Type() == EventEOF
+	return e, nil
+}
+
+// WriteFramed writes the events of r up to and including EventEOF to w as
+// frames of about size bytes, each preceded by its length as a 4-byte big
+// endian integer, for byte stream transports. ReadFramed reads them back.
+// A frame longer than 1 GiB, which ReadFramed would reject, is not written.
+func WriteFramed(w io.Writer, r EventReader, size int) error {
+	var hdr [4]byte
+	return EncodeFrames(r, size, func(frame []byte) error {
+		if len(frame) > maxFrameSize {
+			return errFrameTooLarge
+		}
+		binary.BigEndian.PutUint32(hdr[:], uint32(len(frame)))
+		if _, err := w.Write(hdr[:]); err != nil {
+			return err
+		}
+		_, err := w.Write(frame)
+		return err
+	})
+}
+
+// ReadFramed returns an EventReader of the events written to r by
+// WriteFramed.
+func ReadFramed(r io.Reader) EventReader {
+	var hdr [4]byte
+	var buf []byte
+	return DecodeFrames(func() ([]byte, error) {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(hdr[:])
+		if n > maxFrameSize {
+			return nil, errFrameTooLarge
+		}
+		if cap(buf) < int(n) {
+			buf = make([]byte, n)
+		}
+		buf = buf[:n]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return buf, nil
+	})
+}
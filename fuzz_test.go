@@ -0,0 +1,232 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/orisano/gosax"
+)
+
+// FuzzDifferential compares the events of gosax, with the checks the
+// Reader offers, with the tokens of encoding/xml. gosax must reject the
+// inputs encoding/xml rejects for a reason it checks, and accept the
+// others with the same tokens. Run it with "go test -fuzz FuzzDifferential".
+func FuzzDifferential(f *testing.F) {
+	for _, s := range []string{
+		`<a/>`,
+		`<a x="1" y='2'>text</a>`,
+		"<a>\r\nline\rbreaks\r\n</a>",
+		`<?xml version="1.0"?><!DOCTYPE a [<!ENTITY e "x">]><a>&lt;&#65;&#x42;</a>`,
+		`<a><![CDATA[<raw>]]><!-- comment --><?pi data?></a>`,
+		`<ns:a xmlns:ns="urn:x" ns:b="&quot;"><ns:c/></ns:a>`,
+		`<a b="x>y"/><c/>`,
+	} {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		want, stdErr := stdTokens(data)
+		if stdErr == nil && lenientStd(data, want) {
+			return
+		}
+		got, err := gosaxTokens(data)
+		if stdErr != nil {
+			if err == nil && checkedStd(stdErr) {
+				t.Fatalf("gosax accepted %q rejected by encoding/xml: %v", data, stdErr)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("gosax rejected %q accepted by encoding/xml: %v", data, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%q:\ngosax:        %q\nencoding/xml: %q", data, got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("%q: token %d:\ngosax:        %q\nencoding/xml: %q", data, i, got[i], want[i])
+			}
+		}
+	})
+}
+
+func stdTokens(data []byte) ([]string, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var toks []string
+	for {
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			return toks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		toks = appendToken(toks, tok)
+	}
+}
+
+func gosaxTokens(data []byte) ([]string, error) {
+	r := gosax.NewReader(bytes.NewReader(data))
+	r.EmitSelfClosingTag = true
+	r.CheckComments = true
+	r.CheckCDataEnd = true
+	r.CheckChars = true
+	var toks []string
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return nil, err
+		}
+		if e.Type() == gosax.EventEOF {
+			return toks, nil
+		}
+		tok, err := gosax.Token(e)
+		if err != nil {
+			return nil, err
+		}
+		toks = appendToken(toks, tok)
+	}
+}
+
+// uncheckedStd lists the errors of encoding/xml on markup the Reader does
+// not check, such as names, attribute syntax and the XML declaration.
+var uncheckedStd = []string{
+	"expected element name after <",
+	"invalid characters between </",
+	"expected target name after <?",
+	"invalid sequence <!- not part of <!--",
+	"invalid <![ sequence",
+	"expected /> in element",
+	"expected attribute name in element",
+	"attribute name without = in element",
+	"unquoted or missing attribute value in element",
+	"unescaped < inside quoted string",
+	"invalid XML name",
+	"xml: unsupported version",
+	"xml: encoding",
+	"xml: opening charset",
+}
+
+// checkedStd reports whether err rejects an input for a reason the Reader
+// checks.
+func checkedStd(err error) bool {
+	for _, s := range uncheckedStd {
+		if strings.Contains(err.Error(), s) {
+			return false
+		}
+	}
+	return true
+}
+
+// lenientStd reports whether toks use constructs encoding/xml accepts but
+// XML does not allow, where gosax may differ.
+func lenientStd(data []byte, toks []string) bool {
+	for _, s := range toks {
+		switch s[0] {
+		case 'D':
+			if !strings.HasPrefix(s, "DDOCTYPE") {
+				return true
+			}
+		case 'S':
+			// encoding/xml does not normalize whitespace in attribute values.
+			if strings.Contains(s, `\t`) || strings.Contains(s, `\n`) || strings.Contains(s, `\r`) {
+				return true
+			}
+		case 'C':
+			// encoding/xml does not check the characters of comments.
+			if badChars(s) {
+				return true
+			}
+		case 'P':
+			// encoding/xml does not check the characters of processing
+			// instructions either.
+			if badChars(s) {
+				return true
+			}
+			// The target must be followed by whitespace or "?>".
+			target, inst, _ := strings.Cut(s[1:], " ")
+			if inst != "" && !bytes.Contains(data, []byte("<?"+target+" ")) &&
+				!bytes.Contains(data, []byte("<?"+target+"\t")) &&
+				!bytes.Contains(data, []byte("<?"+target+"\n")) &&
+				!bytes.Contains(data, []byte("<?"+target+"\r")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// badChars reports whether s has characters XML does not allow.
+func badChars(s string) bool {
+	return !utf8.ValidString(s) || strings.ContainsFunc(s, func(c rune) bool {
+		return c < 0x20 && c != '\t' && c != '\n' && c != '\r' ||
+			0xD800 <= c && c < 0xE000 || c == 0xFFFE || c == 0xFFFF
+	})
+}
+
+// appendToken appends a canonical form of tok to toks, merging adjacent
+// character data.
+func appendToken(toks []string, tok xml.Token) []string {
+	var s string
+	switch tok := tok.(type) {
+	case xml.StartElement:
+		s = "S" + qname(tok.Name)
+		for _, a := range tok.Attr {
+			s += fmt.Sprintf(" %s=%q", qname(a.Name), a.Value)
+		}
+	case xml.EndElement:
+		s = "E" + qname(tok.Name)
+	case xml.CharData:
+		if n := len(toks); n > 0 && toks[n-1][0] == 'T' {
+			toks[n-1] += string(tok)
+			return toks
+		}
+		s = "T" + string(tok)
+	case xml.Comment:
+		s = "C" + string(tok)
+	case xml.ProcInst:
+		s = "P" + tok.Target + " " + string(tok.Inst)
+	case xml.Directive:
+		// encoding/xml rewrites comments inside directives.
+		s = "D" + string(tok[:min(len(tok), 7)])
+	}
+	return append(toks, s)
+}
+
+func qname(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
@@ -35,11 +35,15 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"time"
 	"unicode/utf8"
 )
 
+// EventType is the type of an Event.
+type EventType uint8
+
 const (
-	eventUnknown = iota
+	eventUnknown EventType = iota
 	EventStart
 	EventEnd
 	EventText
@@ -48,15 +52,73 @@ const (
 	EventProcessingInstruction
 	EventDocType
 	EventEOF
+	EventEntityRef
+	EventXMLDecl
 )
 
+// EventTypeCount is one more than the largest EventType, for tables indexed
+// by event type.
+const EventTypeCount = int(EventXMLDecl) + 1
+
+var eventTypeNames = [EventTypeCount]string{
+	eventUnknown:               "EventUnknown",
+	EventStart:                 "EventStart",
+	EventEnd:                   "EventEnd",
+	EventText:                  "EventText",
+	EventCData:                 "EventCData",
+	EventComment:               "EventComment",
+	EventProcessingInstruction: "EventProcessingInstruction",
+	EventDocType:               "EventDocType",
+	EventEOF:                   "EventEOF",
+	EventEntityRef:             "EventEntityRef",
+	EventXMLDecl:               "EventXMLDecl",
+}
+
+func (t EventType) String() string {
+	if t.Valid() {
+		return eventTypeNames[t]
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Valid reports whether t is one of the defined event types.
+func (t EventType) Valid() bool {
+	return t > eventUnknown && int(t) < EventTypeCount
+}
+
 type Event struct {
 	Bytes []byte
-	value uint32
+	// value holds the EventType in the low 8 bits, then eventUnescaped and
+	// the sequence number.
+	value uint64
+}
+
+// eventUnescaped marks a text event holding character data rather than
+// markup.
+const eventUnescaped = 1 << 8
+
+func (e Event) Type() EventType {
+	return EventType(e.value)
+}
+
+// Unescaped reports whether the Bytes of a text event hold character data,
+// as with UnescapeText and ExpandCDATA, rather than text as it appears in
+// markup.
+func (e Event) Unescaped() bool {
+	return e.value&eventUnescaped != 0
 }
 
-func (e Event) Type() uint8 {
-	return uint8(e.value)
+// Seq returns the sequence number of the event: 1 for the first event a
+// Reader with SeqNumbers returns since it was created or Reset, increasing
+// by one with each event. Other events have sequence number 0.
+func (e Event) Seq() uint64 {
+	return e.value >> 9
+}
+
+// EventReader is the interface implemented by Reader and by the types that
+// transform its events.
+type EventReader interface {
+	Event() (Event, error)
 }
 
 type Reader struct {
@@ -64,7 +126,239 @@ type Reader struct {
 	state  func(*Reader) (Event, error)
 
 	EmitSelfClosingTag bool
-	selfClosingEnd     int
+	selfClosingLen     int
+
+	// Observer, if set before the first call to Event, receives metrics.
+	Observer Observer
+
+	// ApplyDTDDefaults makes start tags include the attributes that the
+	// internal DTD subset declares with a default value, when they are not
+	// specified. It must be set before the first call to Event.
+	ApplyDTDDefaults bool
+
+	// NormalizeDTDAttrs makes start tags hold the values of the attributes
+	// that the internal DTD subset declares with a tokenized type, such as
+	// ID or NMTOKENS, normalized as XML requires: without leading and
+	// trailing spaces and with runs of spaces collapsed. CDATA attributes
+	// are left as written. It must be set before the first call to Event.
+	NormalizeDTDAttrs bool
+
+	// ElementContent holds the path patterns, such as "feed/entry" or
+	// "*/item", of the elements declared to contain only elements, whose
+	// whitespace-only text events are skipped. Text in other elements is
+	// kept as written, so document formats mixing data and prose keep
+	// their mixed content intact.
+	ElementContent []string
+
+	// SkipWhitespaceText skips text events that are only whitespace, such
+	// as the indentation between elements. Other text events are kept as
+	// written.
+	SkipWhitespaceText bool
+
+	// TrimText trims leading and trailing whitespace from text events and
+	// skips text events that are only whitespace.
+	TrimText bool
+	// CheckEndNames makes Event fail on an end tag that does not match the
+	// innermost open element.
+	CheckEndNames bool
+	// CheckComments makes Event fail on a comment that contains "--",
+	// reporting its offset.
+	CheckComments bool
+	// CheckCDataEnd makes Event fail on text that contains "]]>" as
+	// written, which XML does not allow outside CDATA sections, reporting
+	// its offset.
+	CheckCDataEnd bool
+	// CheckChars makes Event fail on text, tags, comments and processing
+	// instructions that are not valid UTF-8 or contain characters XML 1.0
+	// does not allow, such as control characters, reporting the offset of
+	// the first bad byte.
+	CheckChars bool
+	// CheckWellFormed makes Event fail on a document that is not a single
+	// balanced element: on mismatched end tags as CheckEndNames does, on
+	// elements left open at the end of the input, on a missing root
+	// element or a second one, on character data outside the root element
+	// and on a misplaced XML or document type declaration.
+	CheckWellFormed bool
+
+	// EmitEntityRefs makes text events stop at entity and character
+	// references, which are reported as EventEntityRef events holding the
+	// reference as written, such as "&amp;" or "&#160;".
+	EmitEntityRefs bool
+
+	// EmitXMLDecl makes the XML declaration at the start of the document
+	// reported as an EventXMLDecl event instead of a processing
+	// instruction. ParseXMLDecl parses it.
+	EmitXMLDecl bool
+
+	// ExpandEntities makes the Reader remember the internal general
+	// entities declared by the document type declaration and expand the
+	// references to them in text, including EventEntityRef events, which
+	// become text events, and in attribute values. Markup in replacement
+	// text is escaped, so it reads as character data. The total expansion
+	// is bounded by Limits.MaxEntityExpansion, which applies even if Limits
+	// is the zero value. It must be set before the first call to Event.
+	ExpandEntities bool
+
+	// ExpandCDATA makes CDATA sections reported as text events holding
+	// their content, without the "<![CDATA[" and "]]>" delimiters, as
+	// encoding/xml reports both as CharData. The content is not unescaped
+	// by UnescapeText.
+	ExpandCDATA bool
+
+	// UnescapeText makes text events hold their unescaped content, with line
+	// breaks normalized to "\n", as returned by Unescape. The content is
+	// decoded into a buffer owned by the Reader, so the input is unchanged.
+	UnescapeText bool
+
+	// DetectEncoding makes the Reader detect the encoding of the input from
+	// its byte order mark, the start of its XML declaration, or the
+	// encoding the declaration names, and transcode UTF-16 and ISO-8859-1
+	// input to UTF-8 before tokenization. Other encodings than UTF-8 and
+	// US-ASCII fail. Event offsets then count bytes of UTF-8. It must be set
+	// before the first call to Event.
+	DetectEncoding bool
+	// CharsetReader, if not nil, is called with the encoding named by the
+	// XML declaration, unless it is UTF-8 or US-ASCII, to return a reader
+	// converting input, the document from its start, to UTF-8, as the field
+	// of encoding/xml.Decoder does. It takes precedence over the
+	// transcoding of DetectEncoding. It must be set before the first call
+	// to Event.
+	CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+	// Follow makes the end of the underlying reader not final, for reading
+	// a document that is still being appended to. At the end of the
+	// available input, Event returns ErrNeedMoreData instead of EventEOF or
+	// an incomplete event; calling Event again resumes where it stopped.
+	Follow bool
+
+	// Normalizer, if set, normalizes the content of text and CDATA events,
+	// after UnescapeText. Normalized content is held in a buffer owned by
+	// the Reader.
+	Normalizer Normalizer
+
+	// TagWhitespace selects how form feeds, vertical tabs and no-break
+	// spaces inside tags are handled.
+	TagWhitespace TagWhitespaceMode
+
+	// EventTimeout, if positive, bounds the time a single call to Event may
+	// take reading input, failing with an *EventTimeoutError. The deadline
+	// is checked whenever more input is needed, and passed to the
+	// underlying reader if it has a SetReadDeadline method, such as
+	// net.Conn; other readers are not interrupted while blocked in Read.
+	// The Reader cannot be used after a timeout.
+	EventTimeout time.Duration
+
+	// TrackNamespaces makes the Reader keep the namespace bindings in scope,
+	// declared by the xmlns attributes of the open elements, for
+	// ResolveName and ResolveAttrName. It must be set before the first call
+	// to Event.
+	TrackNamespaces bool
+
+	// OmitNamespaceDecls makes start tags leave out their xmlns and xmlns:*
+	// attributes, so that only application attributes remain. With
+	// TrackNamespaces, the declarations are still applied by ResolveName.
+	// The tag without them is held in a buffer owned by the Reader.
+	OmitNamespaceDecls bool
+
+	// Lenient makes the Reader accept HTML-like markup, reporting it as
+	// well-formed events: attribute values get quotes, attributes without
+	// a value get their name as value, and '&' characters that do not
+	// start a reference are escaped. Missing end tags are added before the
+	// end tag of an enclosing element, at the end of the input, after the
+	// start tag of an HTML void element such as <br>, which becomes
+	// self-closing, and before the start tag of a sibling <li>, <p>, <td>
+	// and the like. End tags without a start tag are dropped. Events it
+	// rewrites or adds are held in buffers owned by the Reader.
+	Lenient bool
+
+	// IndexAttrs makes the Reader locate the attributes of start tags as
+	// it scans them, returned by AttrSpans, so that they need not be
+	// scanned again with NextAttribute.
+	IndexAttrs bool
+
+	// SeqNumbers makes the Reader stamp each event with its sequence
+	// number, returned by Event.Seq.
+	SeqNumbers bool
+
+	// Spill, if set, is called for a text larger than Limits.MaxTokenSize,
+	// which is then written from the start of the returned SpillFile
+	// instead of failing with a *LimitError. The text is reported as an
+	// EventText with empty Bytes, without the options that check or
+	// rewrite text, and Spilled returns it. The caller owns the SpillFile.
+	// Text read with EmitEntityRefs is not spilled.
+	Spill func() (SpillFile, error)
+
+	// Limits bounds the resources used to read untrusted input. Unless it
+	// is the zero value, Event fails with a *LimitError on input exceeding
+	// MaxDepth, MaxTokenSize, MaxAttrs, MaxAttrValueLen or MaxDocTypeSize.
+	// The namespace limits apply to TrackNamespaces. MaxBytes is not
+	// enforced by the Reader. It must be set before the first call to Event.
+	Limits Limits
+
+	// slow is set when an option requires postprocessing of events.
+	slow    bool
+	dtd     *DTD
+	scratch []byte
+	// normalized holds the output of Normalizer.
+	normalized []byte
+	// tagScratch holds the tags rewritten by TagWhitespaceLenient.
+	tagScratch []byte
+	open       elementPath
+	// rootSeen is set by CheckWellFormed at the start of the root element.
+	rootSeen bool
+
+	// lastLen is the length of the last event in the input.
+	lastLen int
+	// raw is the last event as read, when an option changed its Bytes.
+	raw []byte
+	// seq is the sequence number of the last event.
+	seq  uint64
+	decl xmlDecl
+
+	// ns holds the namespace bindings of TrackNamespaces. nsSettle is set
+	// while the frame of the last element has to be popped.
+	ns       nsScope
+	nsSettle bool
+	// omitted holds the start tags of OmitNamespaceDecls.
+	omitted []byte
+	// entities expands the entities of ExpandEntities into expanded, the
+	// event, through expansion, the replacement text.
+	entities  entityExpander
+	expanded  []byte
+	expansion []byte
+
+	// limits holds Limits resolved, when they are enforced.
+	limits  Limits
+	limited bool
+	// depth is the number of open elements.
+	depth int
+	// spill holds the text of the last event if it was spilled, and
+	// spillLen its length.
+	spill    SpillFile
+	spillLen int64
+
+	// budget is the memory budget of NewReaderBudget, kept across Reset,
+	// and dtdSize the size of dtd counted against it.
+	budget  int
+	dtdSize int
+
+	// quotes holds the offsets of the quotes of the last tag scanned with
+	// IndexAttrs, and attrSpans the attributes they delimit. attrs is nil
+	// unless the last event is a tag.
+	quotes    []int
+	attrSpans []AttrSpan
+	attrs     []AttrSpan
+
+	// lenientBuf holds the events fixed by Lenient. lenientEnds holds the
+	// end tags it adds before lenientHeld, read by stateLenientEnd before
+	// it resumes lenientNext; lenientSynth is set while one was returned.
+	lenientBuf   []byte
+	lenientEnds  []byte
+	lenientHeld  Event
+	lenientNext  func(*Reader) (Event, error)
+	lenientSynth bool
+
+	last Event
 }
 
 func NewReader(r io.Reader) *Reader {
@@ -89,7 +383,72 @@ func NewReaderBuf(r io.Reader, buf []byte) *Reader {
 // The underlying byte slice may be overwritten by subsequent calls.
 // If you need to retain the Event data, make a copy before the next Event call.
 func (r *Reader) Event() (Event, error) {
-	return r.state(r)
+	if r.slow || r.seq < 2 || r.Follow || r.EventTimeout > 0 || r.IndexAttrs || r.SeqNumbers || r.Spill != nil {
+		return r.event()
+	}
+	ev, err := r.state(r)
+	r.lastLen = len(ev.Bytes)
+	if err == nil {
+		r.updateDepth(ev)
+		r.seq++
+	}
+	r.last = ev
+	return ev, err
+}
+
+// event is Event with the options that need more than reading the event.
+func (r *Reader) event() (Event, error) {
+	if r.Follow && r.reader.err == io.EOF {
+		r.reader.err = nil
+	}
+	if r.EventTimeout > 0 {
+		r.reader.deadline = time.Now().Add(r.EventTimeout)
+	}
+	var ev Event
+	var err error
+	for {
+		r.spill = nil
+		ev, err = r.state(r)
+		if err != nil && r.EventTimeout > 0 {
+			err = r.eventTimeoutError(err)
+		}
+		if err == io.EOF && r.Follow {
+			return Event{}, ErrNeedMoreData
+		}
+		r.lastLen = len(ev.Bytes)
+		if r.spill != nil {
+			r.lastLen = int(r.spillLen)
+		}
+		r.raw = nil
+		if r.seq < 2 && err == nil {
+			ev = r.prolog(ev)
+		}
+		if !r.slow || r.spill != nil {
+			break
+		}
+		// Events the options drop are replaced with the next one, which
+		// goes through the same steps.
+		var ok bool
+		ev, ok, err = r.postprocess(ev, err)
+		if ok || err != nil {
+			break
+		}
+	}
+	if r.IndexAttrs {
+		r.attrs = nil
+		if err == nil {
+			r.attrs = r.indexAttrs(ev)
+		}
+	}
+	if err == nil {
+		r.updateDepth(ev)
+		r.seq++
+		if r.SeqNumbers {
+			ev.value |= r.seq << 9
+		}
+	}
+	r.last = ev
+	return ev, err
 }
 
 func (r *Reader) Reset(reader io.Reader) {
@@ -98,32 +457,196 @@ func (r *Reader) Reset(reader io.Reader) {
 		data = data[:0]
 	}
 	r.reader = byteReader{
-		data: data,
-		r:    reader,
+		data:  data,
+		r:     reader,
+		alloc: r.reader.alloc,
 	}
 	r.state = (*Reader).stateInit
 	r.EmitSelfClosingTag = false
+	r.Observer = nil
+	r.ApplyDTDDefaults = false
+	r.NormalizeDTDAttrs = false
+	r.ElementContent = nil
+	r.SkipWhitespaceText = false
+	r.TrimText = false
+	r.CheckEndNames = false
+	r.CheckComments = false
+	r.CheckCDataEnd = false
+	r.CheckChars = false
+	r.CheckWellFormed = false
+	r.rootSeen = false
+	r.EmitEntityRefs = false
+	r.EmitXMLDecl = false
+	r.ExpandEntities = false
+	r.entities = entityExpander{}
+	r.ExpandCDATA = false
+	r.UnescapeText = false
+	r.Follow = false
+	r.DetectEncoding = false
+	r.CharsetReader = nil
+	r.TagWhitespace = TagWhitespaceXML
+	r.Normalizer = nil
+	r.EventTimeout = 0
+	r.TrackNamespaces = false
+	r.OmitNamespaceDecls = false
+	r.IndexAttrs = false
+	r.SeqNumbers = false
+	r.Spill = nil
+	r.spill = nil
+	r.quotes = r.quotes[:0]
+	r.Lenient = false
+	r.lenientEnds = r.lenientEnds[:0]
+	r.lenientHeld = Event{}
+	r.lenientNext = nil
+	r.lenientSynth = false
+	r.Limits = Limits{}
+	r.limited = false
+	r.depth = 0
+	r.ns = nsScope{bindings: r.ns.bindings[:0], marks: r.ns.marks[:0]}
+	r.nsSettle = false
+	r.open.reset()
+	r.slow = false
+	r.dtd = nil
+	r.dtdSize = 0
+	r.selfClosingLen = 0
+	r.last = Event{}
+	r.lastLen = 0
+	r.seq = 0
+	r.decl = xmlDecl{}
+}
+
+// Buffered returns the number of bytes that have been read from the
+// underlying reader but not yet consumed by Event.
+func (r *Reader) Buffered() int {
+	return len(r.reader.window())
+}
+
+// InputOffset returns the input offset just after the last event returned
+// by Event.
+func (r *Reader) InputOffset() int64 {
+	return r.reader.inputOffset()
+}
+
+// EventOffset returns the input offset of the last event returned by Event.
+func (r *Reader) EventOffset() int64 {
+	return r.reader.inputOffset() - int64(r.lastLen)
+}
+
+// Raw returns the bytes of the last event returned by Event as they appear
+// in the input. It differs from the Bytes of the event only when an option
+// such as TrimText, UnescapeText or ApplyDTDDefaults has rewritten it. The
+// result is valid until the next call to Event.
+func (r *Reader) Raw() []byte {
+	if r.raw != nil {
+		return r.raw
+	}
+	return r.last.Bytes
+}
+
+// Depth returns the nesting depth of the last event returned by Event: the
+// number of open elements around it, counting the element of a start or
+// end tag, so that the tags of the root element have depth 1. A
+// self-closing tag has the depth of its element whether or not
+// EmitSelfClosingTag reports its end.
+func (r *Reader) Depth() int {
+	switch r.last.Type() {
+	case EventStart:
+		if isSelfClosing(r.last.Bytes) {
+			return r.depth + 1
+		}
+	case EventEnd:
+		return r.depth + 1
+	}
+	return r.depth
+}
+
+// updateDepth updates the number of open elements after ev.
+func (r *Reader) updateDepth(ev Event) {
+	switch ev.Type() {
+	case EventStart:
+		if !isSelfClosing(ev.Bytes) {
+			r.depth++
+		}
+	case EventEnd:
+		if !isSelfClosing(ev.Bytes) && r.depth > 0 {
+			r.depth--
+		}
+	}
+}
+
+// Remaining returns an io.Reader that yields the unconsumed input: the
+// buffered bytes followed by the rest of the underlying reader.
+// It is intended for protocols that switch from XML framing to another
+// encoding mid-stream.
+//
+// After Remaining is called, Event reports EventEOF until the Reader is Reset.
+func (r *Reader) Remaining() io.Reader {
+	buf := bytes.Clone(r.reader.window())
+	rest := r.reader.r
+	if r.reader.err != nil {
+		rest = errReader{r.reader.err}
+	}
+	r.reader.release(len(buf))
+	r.state = (*Reader).stateDone
+	return io.MultiReader(bytes.NewReader(buf), rest)
+}
+
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
 }
 
 func (r *Reader) stateInit() (Event, error) {
+	r.reader.observer = r.Observer
+	r.slow = r.Observer != nil || r.ApplyDTDDefaults || r.NormalizeDTDAttrs || r.SkipWhitespaceText || r.TrimText || r.CheckEndNames || r.CheckWellFormed || r.CheckComments || r.CheckCDataEnd || r.CheckChars || r.ExpandCDATA || r.UnescapeText || r.TagWhitespace != TagWhitespaceXML || r.Normalizer != nil || r.TrackNamespaces || r.OmitNamespaceDecls || r.ExpandEntities || r.ElementContent != nil || r.Lenient
+	if r.TrackNamespaces {
+		r.ns.setLimits(r.Limits)
+	}
+	if r.Limits != (Limits{}) || r.budget > 0 {
+		r.limits = r.Limits.resolve()
+		if r.budget > 0 {
+			r.budgetLimits()
+		}
+		r.limited = true
+		r.slow = true
+		r.reader.maxToken = r.limits.MaxTokenSize
+	}
+	if r.DetectEncoding || r.CharsetReader != nil {
+		if err := r.detectEncoding(); err != nil {
+			return Event{}, err
+		}
+	}
 	// remove_utf8_bom
 	return r.stateInsideText()
 }
 
 func (r *Reader) stateInsideText() (Event, error) {
+	if r.EmitEntityRefs {
+		return r.stateInsideTextRefs()
+	}
 	end, err := readText(&r.reader)
+	if r.Spill != nil && isTokenLimit(err) {
+		return r.spillText()
+	}
+	if err == io.EOF && r.Follow {
+		r.state = (*Reader).stateInsideText
+		return Event{}, err
+	}
 	if err == io.EOF {
 		r.state = (*Reader).stateDone
 		if end == 0 {
 			return Event{
-				value: EventEOF,
+				value: uint64(EventEOF),
 			}, nil
 		} else {
 			w := r.reader.window()
 			r.reader.offset += len(w)
 			return Event{
 				Bytes: w,
-				value: EventText,
+				value: uint64(EventText),
 			}, nil
 		}
 	}
@@ -138,7 +661,7 @@ func (r *Reader) stateInsideText() (Event, error) {
 		r.reader.offset += len(w)
 		return Event{
 			Bytes: w,
-			value: EventText,
+			value: uint64(EventText),
 		}, nil
 	}
 }
@@ -173,7 +696,7 @@ func (r *Reader) stateInsideMarkup() (Event, error) {
 					r.reader.offset += offset + i + 3
 					return Event{
 						Bytes: w[:offset+i+3],
-						value: EventCData,
+						value: uint64(EventCData),
 					}, nil
 				}
 				offset = len(w) - 2
@@ -183,47 +706,84 @@ func (r *Reader) stateInsideMarkup() (Event, error) {
 				w = rr.window()
 			}
 		case '-': // Comment
-			offset := 3
+			// The "-->" must not overlap the "<!--", as in "<!--->".
+			offset := 4
 			for {
-				if i := bytes.Index(w[offset:], []byte("-->")); i >= 0 {
+				if i := bytes.Index(w[min(offset, len(w)):], []byte("-->")); i >= 0 {
 					r.reader.offset += offset + i + 3
 					return Event{
 						Bytes: w[:offset+i+3],
-						value: EventComment,
+						value: uint64(EventComment),
 					}, nil
 				}
-				offset = len(w) - 2
+				offset = max(len(w)-2, 4)
 				if rr.extend() == 0 {
 					return Event{}, rr.err
 				}
 				w = rr.window()
 			}
 		case 'D', 'd': // DocType
+			// The internal subset may contain markup declarations, quoted
+			// literals and comments, all of which may contain '>'.
 			offset := 2
+			lv := 1
+			var quote byte
+			comment := false
 			for {
-				lv := 1
-				for i, c := range w[offset:] {
-					if c == '>' {
+			scan:
+				for offset < len(w) {
+					c := w[offset]
+					switch {
+					case comment:
+						if c == '-' {
+							if offset+3 > len(w) {
+								break scan
+							}
+							if string(w[offset:offset+3]) == "-->" {
+								comment = false
+								offset += 3
+								continue
+							}
+						}
+					case quote != 0:
+						if c == quote {
+							quote = 0
+						}
+					case c == '"' || c == '\'':
+						quote = c
+					case c == '<':
+						if offset+4 > len(w) {
+							break scan
+						}
+						if string(w[offset+1:offset+4]) == "!--" {
+							comment = true
+							offset += 4
+							continue
+						}
+						lv++
+					case c == '>':
 						lv--
 						if lv == 0 {
-							r.reader.offset += offset + i + 1
+							r.reader.offset += offset + 1
 							return Event{
-								Bytes: w[:offset+i+1],
-								value: EventDocType,
+								Bytes: w[:offset+1],
+								value: uint64(EventDocType),
 							}, nil
 						}
-					} else if c == '<' {
-						lv++
 					}
+					offset++
+				}
+				if r.limited && r.limits.MaxDocTypeSize >= 0 && offset > r.limits.MaxDocTypeSize {
+					// Fail before buffering an oversized internal subset.
+					return Event{}, &LimitError{Limit: "MaxDocTypeSize", Max: int64(r.limits.MaxDocTypeSize), Offset: rr.inputOffset()}
 				}
-				offset = len(w)
 				if rr.extend() == 0 {
 					return Event{}, rr.err
 				}
 				w = rr.window()
 			}
 		default:
-			return Event{}, fmt.Errorf("unknown bang type: %c", w[1])
+			return Event{}, syntaxError(rr.inputOffset(), w[:3], "unknown bang type: %c", w[2])
 		}
 	case '/': // close tag
 		offset := 2
@@ -232,7 +792,7 @@ func (r *Reader) stateInsideMarkup() (Event, error) {
 				r.reader.offset += offset + i + 1
 				return Event{
 					Bytes: w[:offset+i+1],
-					value: EventEnd,
+					value: uint64(EventEnd),
 				}, nil
 			}
 			offset = len(w)
@@ -248,7 +808,7 @@ func (r *Reader) stateInsideMarkup() (Event, error) {
 				r.reader.offset += offset + i + 2
 				return Event{
 					Bytes: w[:offset+i+2],
-					value: EventProcessingInstruction,
+					value: uint64(EventProcessingInstruction),
 				}, nil
 			}
 			offset = len(w) - 1
@@ -266,6 +826,9 @@ func (r *Reader) stateInsideMarkup() (Event, error) {
 		)
 		state := byte('>')
 		offset := 1
+		if r.IndexAttrs {
+			r.quotes = r.quotes[:0]
+		}
 		for {
 			for offset < len(w) {
 				if state == '>' {
@@ -287,16 +850,18 @@ func (r *Reader) stateInsideMarkup() (Event, error) {
 					if p >= 0 {
 						if ch == '>' {
 							if r.EmitSelfClosingTag && w[offset+p-1] == '/' {
-								r.selfClosingEnd = offset + p
+								r.selfClosingLen = offset + p + 1
 								r.state = (*Reader).stateSelfClosingTag
-							} else {
-								r.reader.offset += offset + p + 1
 							}
+							r.reader.offset += offset + p + 1
 							return Event{
 								Bytes: w[:offset+p+1],
-								value: EventStart,
+								value: uint64(EventStart),
 							}, nil
 						} else {
+							if r.IndexAttrs {
+								r.quotes = append(r.quotes, offset+p)
+							}
 							state = ch
 							offset += p + 1
 						}
@@ -305,6 +870,9 @@ func (r *Reader) stateInsideMarkup() (Event, error) {
 					}
 				} else {
 					if i := bytes.IndexByte(w[offset:], state); i >= 0 {
+						if r.IndexAttrs {
+							r.quotes = append(r.quotes, offset+i)
+						}
 						offset += i + 1
 						state = '>'
 					} else {
@@ -323,17 +891,18 @@ func (r *Reader) stateInsideMarkup() (Event, error) {
 
 func (r *Reader) stateSelfClosingTag() (Event, error) {
 	r.state = (*Reader).stateInsideText
-	w := r.reader.window()
-	r.reader.offset += r.selfClosingEnd + 1
+	rr := &r.reader
+	n := r.selfClosingLen
+	r.selfClosingLen = 0
 	return Event{
-		Bytes: w[:r.selfClosingEnd+1],
-		value: EventEnd,
+		Bytes: rr.data[rr.offset-n : rr.offset],
+		value: uint64(EventEnd),
 	}, nil
 }
 
 func (r *Reader) stateDone() (Event, error) {
 	return Event{
-		value: EventEOF,
+		value: uint64(EventEOF),
 	}, nil
 }
 
@@ -388,7 +957,8 @@ type Attribute struct {
 }
 
 // NextAttribute extracts the next attribute from an XML tag.
-// It returns the Attribute and the remaining bytes.
+// It returns the Attribute and the remaining bytes, or an error if the
+// attribute has no quoted value.
 func NextAttribute(b []byte) (Attribute, []byte, error) {
 	i := 0
 	for ; i < len(b) && whitespace[b[i]]; i++ {
@@ -399,29 +969,25 @@ func NextAttribute(b []byte) (Attribute, []byte, error) {
 	keyStart := i
 	for ; i < len(b) && !whitespace[b[i]] && b[i] != '='; i++ {
 	}
-	if i == len(b) {
-		return Attribute{Key: b[keyStart:]}, nil, nil
-	}
 	key := b[keyStart:i]
 	for ; i < len(b) && whitespace[b[i]]; i++ {
 	}
-	if i == len(b) {
-		return Attribute{Key: key}, nil, nil
-	}
-	if b[i] != '=' {
-		return Attribute{Key: key}, b[i:], nil
+	if i == len(b) || b[i] != '=' {
+		return Attribute{}, nil, fmt.Errorf("missing value of attribute %q", key)
 	}
 	i++
 	for ; i < len(b) && whitespace[b[i]]; i++ {
 	}
-
-	if b[i] == '"' {
-		valueEnd := i + 1 + bytes.IndexByte(b[i+1:], '"') + 1
-		value := b[i:valueEnd]
-		return Attribute{Key: key, Value: value}, b[valueEnd:], nil
+	if i == len(b) {
+		return Attribute{}, nil, fmt.Errorf("missing value of attribute %q", key)
 	}
-	if b[i] == '\'' {
-		valueEnd := i + 1 + bytes.IndexByte(b[i+1:], '\'') + 1
+
+	if q := b[i]; q == '"' || q == '\'' {
+		n := bytes.IndexByte(b[i+1:], q)
+		if n < 0 {
+			return Attribute{}, nil, fmt.Errorf("unterminated value of attribute %q", key)
+		}
+		valueEnd := i + 1 + n + 1
 		value := b[i:valueEnd]
 		return Attribute{Key: key, Value: value}, b[valueEnd:], nil
 	}
@@ -438,99 +1004,170 @@ var whitespace = [256]bool{
 // Unescape decodes XML entity references in a byte slice.
 // It returns the unescaped bytes and any error encountered.
 func Unescape(b []byte) ([]byte, error) {
-	p := indexUnescape(b)
-	if p < 0 {
+	return unescape(b, nil)
+}
+
+// unescape is Unescape resolving other entities with ents, if not nil.
+// It works in place unless a replacement text is longer than its reference.
+func unescape(b []byte, ents Entities) ([]byte, error) {
+	// '&' and '\r' are located with separate vectorized searches, each
+	// repeated only once its match has been consumed.
+	amp := bytes.IndexByte(b, '&')
+	cr := bytes.IndexByte(b, '\r')
+	if amp < 0 && cr < 0 {
 		return b, nil
 	}
-	begin := 0
-	cur := p
-	for {
+	begin, cur := 0, 0
+	for amp >= 0 || cr >= 0 {
+		p := amp
+		if p < 0 || cr >= 0 && cr < p {
+			p = cr
+		}
+		if begin != cur {
+			copy(b[cur:], b[begin:p])
+		}
+		cur += p - begin
 		if b[p] == '&' {
-			var escaped []byte
-			for i := 2; i < 13 && p+i < len(b); i++ {
-				if b[p+i] == ';' {
-					escaped = b[p+1 : p+i]
-					break
-				}
-			}
-			if len(escaped) <= 1 {
+			end := refEnd(b[p+1:])
+			if end <= 1 {
 				return nil, fmt.Errorf("invalid escape sequence")
 			}
-			if cur != p && begin != p {
-				cur += copy(b[cur:], b[begin:p])
-			}
+			escaped := b[p+1 : p+1+end]
 			if escaped[0] == '#' {
-				var x uint64
-				var err error
-				if escaped[1] == 'x' {
-					x, err = strconv.ParseUint(string(escaped[2:]), 16, 32)
-				} else {
-					x, err = strconv.ParseUint(string(escaped[1:]), 10, 32)
-				}
+				x, err := charRef(escaped)
 				if err != nil {
-					return nil, fmt.Errorf("invalid char reference: %w", err)
+					return nil, err
 				}
-				cur += utf8.EncodeRune(b[cur:], rune(x))
+				cur += utf8.EncodeRune(b[cur:], x)
+			} else if c := predefinedEntity(escaped); c != 0 {
+				b[cur] = c
+				cur++
 			} else {
-				switch string(escaped) {
-				case "lt":
-					b[cur] = '<'
-				case "gt":
-					b[cur] = '>'
-				case "amp":
-					b[cur] = '&'
-				case "apos":
-					b[cur] = '\''
-				case "quot":
-					b[cur] = '"'
-				default:
+				v, ok := resolveEntity(ents, escaped)
+				if !ok {
 					return nil, fmt.Errorf("invalid escape sequence: %q", string(escaped))
 				}
-				cur++
+				if len(v) > len(escaped)+2 {
+					return unescapeGrow(b, cur, p, ents)
+				}
+				cur += copy(b[cur:], v)
 			}
 			begin = p + len(escaped) + 2
 		} else {
-			if cur != p && begin != p {
-				cur += copy(b[cur:], b[begin:p])
-			}
 			b[cur] = '\n'
 			cur++
 			begin = p + 1
 			if p+1 < len(b) && b[p+1] == '\n' {
-				begin += 1
+				begin++
 			}
 		}
-		if i := indexUnescape(b[begin:]); i >= 0 {
-			p = begin + i
-		} else {
-			break
+		if amp >= 0 && amp < begin {
+			amp = nextIndexByte(b, begin, '&')
+		}
+		if cr >= 0 && cr < begin {
+			cr = nextIndexByte(b, begin, '\r')
 		}
 	}
-	if len(b) != begin {
-		cur += copy(b[cur:], b[begin:])
+	if begin != cur {
+		copy(b[cur:], b[begin:])
 	}
-	return b[:cur], nil
+	return b[:cur+len(b)-begin], nil
 }
 
-func indexUnescape(s []byte) int {
-	const (
-		splat uint64 = 0x0101010101010101
-		v1           = '&' * splat
-		v2           = '\r' * splat
-	)
-	offset := 0
-	for len(s) >= 8 {
-		v := binary.LittleEndian.Uint64(s[:8])
-		if hasZeroByte(v^v1) || hasZeroByte(v^v2) {
-			break
-		}
-		s = s[8:]
-		offset += 8
+// charRef decodes the character reference name, such as "#160" or "#xA0".
+func charRef(name []byte) (rune, error) {
+	var x uint64
+	var err error
+	if name[1] == 'x' {
+		x, err = strconv.ParseUint(string(name[2:]), 16, 32)
+	} else {
+		x, err = strconv.ParseUint(string(name[1:]), 10, 32)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid char reference: %w", err)
 	}
-	for i, c := range s {
-		if c == '&' || c == '\r' {
-			return offset + i
+	if !isXMLChar(rune(x)) {
+		return 0, fmt.Errorf("invalid char reference: %q", name)
+	}
+	return rune(x), nil
+}
+
+// predefinedEntity returns the character of the predefined entity name,
+// or 0.
+func predefinedEntity(name []byte) byte {
+	switch string(name) {
+	case "lt":
+		return '<'
+	case "gt":
+		return '>'
+	case "amp":
+		return '&'
+	case "apos":
+		return '\''
+	case "quot":
+		return '"'
+	}
+	return 0
+}
+
+// refEnd returns the index of the ';' terminating the reference name at the
+// start of b, or -1 if a character that cannot be part of a name or a
+// character reference comes first.
+func refEnd(b []byte) int {
+	for i, c := range b {
+		switch {
+		case c == ';':
+			return i
+		case c == '#' && i == 0, refNameChar[c]:
+		default:
+			return -1
 		}
 	}
 	return -1
 }
+
+// refNameChar reports the bytes allowed in entity names, counting every
+// byte of a multi-byte UTF-8 sequence.
+var refNameChar = func() (t [256]bool) {
+	for c := range t {
+		t[c] = 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '.' || c == '-' || c == '_' || c == ':' || c >= utf8.RuneSelf
+	}
+	return t
+}()
+
+// nextIndexByte returns the index of the first c in b at or after from, or
+// -1.
+func nextIndexByte(b []byte, from int, c byte) int {
+	if i := bytes.IndexByte(b[from:], c); i >= 0 {
+		return from + i
+	}
+	return -1
+}
+
+// UnescapeAttr is like Unescape for attribute values, which are normalized
+// as the spec requires: literal tabs, line feeds, carriage returns and CRLF
+// pairs become single spaces before references are decoded, so "&#10;"
+// still yields a line feed. Like Unescape, it works in place.
+func UnescapeAttr(b []byte) ([]byte, error) {
+	return unescapeAttr(b, nil)
+}
+
+func unescapeAttr(b []byte, ents Entities) ([]byte, error) {
+	n := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch c {
+		case '\r':
+			if i+1 < len(b) && b[i+1] == '\n' {
+				i++
+			}
+			c = ' '
+		case '\n', '\t':
+			c = ' '
+		}
+		b[n] = c
+		n++
+	}
+	return unescape(b[:n], ents)
+}
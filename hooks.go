@@ -0,0 +1,95 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "reflect"
+
+// A DecodeHook converts the text of an element or attribute and stores it
+// in v, an addressable value of the type the hook is registered for.
+type DecodeHook func(v reflect.Value, text []byte) error
+
+type decodeHooks struct {
+	types map[reflect.Type]DecodeHook
+	xsd   map[string]DecodeHook
+}
+
+// RegisterType makes d decode values of type t with fn, in place of the
+// default conversion and of Unmarshaler methods.
+func (d *Decoder) RegisterType(t reflect.Type, fn DecodeHook) {
+	h := d.d.registerHooks()
+	if h.types == nil {
+		h.types = make(map[reflect.Type]DecodeHook)
+	}
+	h.types[t] = fn
+}
+
+// RegisterXSDType makes d decode the fields tagged with the XSD type name,
+// as in `xml:"created" xsd:"xs:dateTime"`, with fn. The name is matched as
+// written in the tag. A hook registered for the type of the field takes
+// precedence.
+func (d *Decoder) RegisterXSDType(name string, fn DecodeHook) {
+	h := d.d.registerHooks()
+	if h.xsd == nil {
+		h.xsd = make(map[string]DecodeHook)
+	}
+	h.xsd[name] = fn
+}
+
+// RegisterTypeFunc is like RegisterType for T, with a hook returning the
+// value to store.
+func RegisterTypeFunc[T any](d *Decoder, fn func(text []byte) (T, error)) {
+	d.RegisterType(reflect.TypeFor[T](), func(v reflect.Value, text []byte) error {
+		x, err := fn(text)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(&x).Elem())
+		return nil
+	})
+}
+
+func (d *decodeState) registerHooks() *decodeHooks {
+	if d.hooks == nil {
+		d.hooks = &decodeHooks{}
+	}
+	return d.hooks
+}
+
+// lookup returns the hook for a value of type t in a field with the XSD
+// type xsd, or nil. The XSD type of a slice field applies to its elements.
+func (h *decodeHooks) lookup(t reflect.Type, xsd string) DecodeHook {
+	if h == nil {
+		return nil
+	}
+	if fn, ok := h.types[t]; ok {
+		return fn
+	}
+	if xsd == "" || t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		return nil
+	}
+	return h.xsd[xsd]
+}
@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned by DecodeRequest and DecodeResponse
+	// when the body is not declared as XML.
+	ErrUnsupportedMediaType = errors.New("gosax: unsupported media type")
+	// ErrUnsupportedCharset is returned by DecodeRequest and DecodeResponse
+	// when the body is declared in a charset gosax cannot decode.
+	ErrUnsupportedCharset = errors.New("gosax: unsupported charset")
+)
+
+// DecodeRequest decodes the XML body of req into v. The Content-Type must
+// be an XML media type, if present, and its charset UTF-8, US-ASCII or
+// ISO-8859-1. The body is read up to limits.MaxBytes and closed.
+func DecodeRequest(req *http.Request, v any, limits Limits) error {
+	return decodeBody(req.Header, req.Body, v, limits)
+}
+
+// DecodeResponse is like DecodeRequest, but decodes the body of resp.
+func DecodeResponse(resp *http.Response, v any, limits Limits) error {
+	return decodeBody(resp.Header, resp.Body, v, limits)
+}
+
+func decodeBody(h http.Header, body io.ReadCloser, v any, limits Limits) error {
+	if body == nil || body == http.NoBody {
+		return io.EOF
+	}
+	defer body.Close()
+	var r io.Reader = body
+	if n := limits.maxBytes(); n > 0 {
+		r = http.MaxBytesReader(nil, body, n)
+	}
+	if ct := h.Get("Content-Type"); ct != "" {
+		mt, params, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedMediaType, err)
+		}
+		if !isXMLMediaType(mt) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mt)
+		}
+		switch cs := strings.ToLower(params["charset"]); cs {
+		case "", "utf-8", "utf8", "us-ascii":
+		case "iso-8859-1", "latin1":
+			r = &latin1Reader{r: r}
+		default:
+			return fmt.Errorf("%w: %s", ErrUnsupportedCharset, cs)
+		}
+	}
+	dec := NewDecoder(r)
+	dec.SetLimits(limits)
+	return dec.Decode(v)
+}
+
+// isXMLMediaType reports whether mt is application/xml, text/xml or a
+// structured syntax with the +xml suffix.
+func isXMLMediaType(mt string) bool {
+	return mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml")
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8.
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(p) < utf8.UTFMax {
+		return 0, io.ErrShortBuffer
+	}
+	if cap(l.buf) < len(p)/2 {
+		l.buf = make([]byte, len(p)/2)
+	}
+	n, err := l.r.Read(l.buf[:len(p)/2])
+	j := 0
+	for _, c := range l.buf[:n] {
+		j += utf8.EncodeRune(p[j:], rune(c))
+	}
+	return j, err
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+// IDIndex maps element IDs to the input offsets of their start tags,
+// so identified elements can be read again by seeking the input.
+//
+// xml:id is always indexed. When an ID occurs more than once, the first
+// element wins.
+type IDIndex struct {
+	// Attrs lists other attributes that hold element IDs, such as "id".
+	Attrs []string
+	// Offsets maps each ID to the input offset of its start tag.
+	Offsets map[string]int64
+}
+
+// BuildIDIndex reads r to EOF and indexes the IDs of its elements.
+func BuildIDIndex(r *Reader, attrs ...string) (*IDIndex, error) {
+	ix := &IDIndex{Attrs: attrs}
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return nil, err
+		}
+		if e.Type() == EventEOF {
+			return ix, nil
+		}
+		if err := ix.Add(r, e); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Add indexes e, the event last returned by r.
+// It allows an index to be built while the document is processed.
+func (ix *IDIndex) Add(r *Reader, e Event) error {
+	if e.Type() != EventStart {
+		return nil
+	}
+	_, rest := Name(e.Bytes)
+	for len(rest) > 0 {
+		attr, next, err := NextAttribute(rest)
+		if err != nil {
+			return err
+		}
+		rest = next
+		if len(attr.Key) == 0 {
+			break
+		}
+		if !ix.isID(attr.Key) {
+			continue
+		}
+		id, err := attrValue(attr.Value)
+		if err != nil {
+			return err
+		}
+		id = string(trimSpace([]byte(id)))
+		if ix.Offsets == nil {
+			ix.Offsets = make(map[string]int64)
+		}
+		if _, ok := ix.Offsets[id]; !ok {
+			ix.Offsets[id] = r.EventOffset()
+		}
+	}
+	return nil
+}
+
+// Lookup returns the input offset of the start tag of the element with id.
+func (ix *IDIndex) Lookup(id string) (int64, bool) {
+	off, ok := ix.Offsets[id]
+	return off, ok
+}
+
+func (ix *IDIndex) isID(key []byte) bool {
+	if string(key) == "xml:id" {
+		return true
+	}
+	for _, a := range ix.Attrs {
+		if string(key) == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"fmt"
+	"hash/maphash"
+	"slices"
+)
+
+// IDRefChecker verifies in a single streaming pass that IDREF and IDREFS
+// attributes refer to IDs of the document and that IDs are unique.
+//
+// Attribute types are taken from the ATTLIST declarations of the internal
+// DTD subset, xml:id, and the attribute names configured in the fields.
+// IDs are kept as 64-bit hashes, so memory stays bounded by the number of
+// IDs and unresolved references rather than their size.
+type IDRefChecker struct {
+	// IDAttrs lists attributes that hold IDs, such as "id".
+	IDAttrs []string
+	// IDRefAttrs lists attributes that hold a single reference.
+	IDRefAttrs []string
+	// IDRefsAttrs lists attributes that hold whitespace-separated references.
+	IDRefsAttrs []string
+
+	seed    maphash.Seed
+	ids     map[uint64]struct{}
+	pending map[uint64]IDRefProblem
+	dups    []IDRefProblem
+	dtd     *DTD
+}
+
+// IDRefProblem is a dangling reference or a duplicate ID.
+type IDRefProblem struct {
+	// Offset is the input offset of the start tag holding the attribute.
+	Offset    int64
+	Element   string
+	Attr      string
+	ID        string
+	Duplicate bool
+}
+
+func (p IDRefProblem) String() string {
+	if p.Duplicate {
+		return fmt.Sprintf("offset %d: <%s %s>: duplicate ID %q", p.Offset, p.Element, p.Attr, p.ID)
+	}
+	return fmt.Sprintf("offset %d: <%s %s>: reference to undefined ID %q", p.Offset, p.Element, p.Attr, p.ID)
+}
+
+// Check reads r to EOF and returns the problems found, in input order.
+func (c *IDRefChecker) Check(r *Reader) ([]IDRefProblem, error) {
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return nil, err
+		}
+		if e.Type() == EventEOF {
+			return c.Finish(), nil
+		}
+		if err := c.Add(r, e); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Add checks e, the event last returned by r.
+func (c *IDRefChecker) Add(r *Reader, e Event) error {
+	switch e.Type() {
+	case EventDocType:
+		dtd, err := ParseDTD(e.Bytes)
+		if err != nil {
+			return err
+		}
+		c.dtd = dtd
+		return nil
+	case EventStart:
+	default:
+		return nil
+	}
+	if c.ids == nil {
+		c.seed = maphash.MakeSeed()
+		c.ids = make(map[uint64]struct{})
+		c.pending = make(map[uint64]IDRefProblem)
+	}
+	name, rest := Name(e.Bytes)
+	for len(rest) > 0 {
+		attr, next, err := NextAttribute(rest)
+		if err != nil {
+			return err
+		}
+		rest = next
+		if len(attr.Key) == 0 {
+			break
+		}
+		kind := c.attrType(name, attr.Key)
+		if kind == "" {
+			continue
+		}
+		v, err := attrValue(attr.Value)
+		if err != nil {
+			return err
+		}
+		p := IDRefProblem{Offset: r.EventOffset(), Element: string(name), Attr: string(attr.Key)}
+		switch kind {
+		case "ID":
+			p.ID = string(trimSpace([]byte(v)))
+			h := maphash.String(c.seed, p.ID)
+			if _, ok := c.ids[h]; ok {
+				p.Duplicate = true
+				c.dups = append(c.dups, p)
+				continue
+			}
+			c.ids[h] = struct{}{}
+			delete(c.pending, h)
+		case "IDREF", "IDREFS":
+			b := []byte(v)
+			for len(b) > 0 {
+				b = trimSpace(b)
+				i := 0
+				for i < len(b) && !whitespace[b[i]] {
+					i++
+				}
+				if i == 0 {
+					break
+				}
+				h := maphash.Bytes(c.seed, b[:i])
+				if _, ok := c.ids[h]; !ok {
+					if _, ok := c.pending[h]; !ok {
+						p.ID = string(b[:i])
+						c.pending[h] = p
+					}
+				}
+				if kind == "IDREF" {
+					break
+				}
+				b = b[i:]
+			}
+		}
+	}
+	return nil
+}
+
+// Finish returns the duplicate IDs and the references that were not
+// resolved by any ID of the document, in input order.
+func (c *IDRefChecker) Finish() []IDRefProblem {
+	problems := slices.Clone(c.dups)
+	for _, p := range c.pending {
+		problems = append(problems, p)
+	}
+	slices.SortStableFunc(problems, func(a, b IDRefProblem) int {
+		switch {
+		case a.Offset < b.Offset:
+			return -1
+		case a.Offset > b.Offset:
+			return 1
+		}
+		return 0
+	})
+	return problems
+}
+
+// attrType returns ID, IDREF, IDREFS or "" for the attribute key of
+// element name.
+func (c *IDRefChecker) attrType(name, key []byte) string {
+	if string(key) == "xml:id" || slices.Contains(c.IDAttrs, string(key)) {
+		return "ID"
+	}
+	if slices.Contains(c.IDRefAttrs, string(key)) {
+		return "IDREF"
+	}
+	if slices.Contains(c.IDRefsAttrs, string(key)) {
+		return "IDREFS"
+	}
+	if c.dtd != nil {
+		for _, def := range c.dtd.Attlists[string(name)] {
+			if def.Name == string(key) {
+				switch def.Type {
+				case "ID", "IDREF", "IDREFS":
+					return def.Type
+				}
+				return ""
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,206 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// IndexSuffix is appended to the name of an XML file to name its index.
+const IndexSuffix = ".gosax-index"
+
+// Index is an offset index of an XML file, stored as a JSON sidecar file,
+// that allows random access to records and identified elements.
+type Index struct {
+	// Size is the size of the indexed input, used to detect stale indexes.
+	Size int64 `json:"size"`
+	// RecordPath is the path pattern of the record elements, such as
+	// "feed/entry" or "*/item".
+	RecordPath string `json:"record_path,omitempty"`
+	// Records holds the byte range of each record element.
+	Records []Section `json:"records,omitempty"`
+	// IDs maps element IDs to the offsets of their start tags.
+	IDs map[string]int64 `json:"ids,omitempty"`
+	// Paths describes each distinct element path of the document.
+	Paths map[string]PathInfo `json:"paths,omitempty"`
+}
+
+// Section is a byte range of the input.
+type Section struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// PathInfo describes the elements at an element path.
+type PathInfo struct {
+	// Offset is the offset of the start tag of the first element.
+	Offset int64 `json:"offset"`
+	Count  int   `json:"count"`
+}
+
+// BuildIndex reads r to EOF and indexes its element paths, the elements
+// matching recordPath, and the IDs held by xml:id and idAttrs.
+// An empty recordPath indexes no records.
+func BuildIndex(r *Reader, recordPath string, idAttrs ...string) (*Index, error) {
+	ix := &Index{
+		RecordPath: recordPath,
+		Paths:      make(map[string]PathInfo),
+	}
+	ids := &IDIndex{Attrs: idAttrs}
+	var path elementPath
+	recordDepth := -1
+	var recordStart int64
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return nil, err
+		}
+		path.update(e)
+		switch e.Type() {
+		case EventEOF:
+			ix.Size = r.InputOffset()
+			ix.IDs = ids.Offsets
+			return ix, nil
+		case EventStart:
+			start := r.EventOffset()
+			p := path.bytes()
+			info, ok := ix.Paths[string(p)]
+			if !ok {
+				info.Offset = start
+			}
+			info.Count++
+			ix.Paths[string(p)] = info
+			if err := ids.Add(r, e); err != nil {
+				return nil, err
+			}
+			if recordDepth < 0 && recordPath != "" && matchPath(recordPath, p) {
+				if isSelfClosing(e.Bytes) {
+					ix.Records = append(ix.Records, Section{start, r.InputOffset() - start})
+				} else {
+					recordDepth = path.depth()
+					recordStart = start
+				}
+			}
+		case EventEnd:
+			if !isSelfClosing(e.Bytes) && path.depth() == recordDepth-1 {
+				ix.Records = append(ix.Records, Section{recordStart, r.InputOffset() - recordStart})
+				recordDepth = -1
+			}
+		}
+	}
+}
+
+// ReadIndex reads an Index written by WriteTo or encoded by MarshalBinary.
+func ReadIndex(r io.Reader) (*Index, error) {
+	var ix Index
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(snapshotMagic)); string(head) == snapshotMagic {
+		b, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		if err := ix.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		return &ix, nil
+	}
+	if err := json.NewDecoder(br).Decode(&ix); err != nil {
+		return nil, err
+	}
+	return &ix, nil
+}
+
+// WriteTo writes ix as JSON to w.
+func (ix *Index) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.Marshal(ix)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// IndexedFile is an XML file opened together with its index.
+type IndexedFile struct {
+	Index *Index
+	f     *os.File
+}
+
+// OpenIndexed opens the XML file name and its index name+IndexSuffix, in
+// either format read by ReadIndex.
+// It fails if the index does not match the size of the file.
+func OpenIndexed(name string) (*IndexedFile, error) {
+	xf, err := os.Open(name + IndexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := ReadIndex(xf)
+	xf.Close()
+	if err != nil {
+		return nil, fmt.Errorf("gosax: %s: %w", name+IndexSuffix, err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() != ix.Size {
+		f.Close()
+		return nil, fmt.Errorf("gosax: index of %s is stale", name)
+	}
+	return &IndexedFile{Index: ix, f: f}, nil
+}
+
+// Record returns a Reader over the i-th record element.
+func (f *IndexedFile) Record(i int) *Reader {
+	s := f.Index.Records[i]
+	return NewReaderSize(io.NewSectionReader(f.f, s.Offset, s.Length), int(min(s.Length+1, 2*1024*1024)))
+}
+
+// Element returns a Reader positioned at the start tag of the element
+// with the given ID. The Reader continues past the element to the end of
+// the file.
+func (f *IndexedFile) Element(id string) (*Reader, bool) {
+	off, ok := f.Index.IDs[id]
+	if !ok {
+		return nil, false
+	}
+	return NewReader(io.NewSectionReader(f.f, off, f.Index.Size-off)), true
+}
+
+// Close closes the underlying file.
+func (f *IndexedFile) Close() error {
+	return f.f.Close()
+}
@@ -0,0 +1,382 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxEnumValues is the largest number of distinct values TypeStats
+// remembers for an enumeration.
+const MaxEnumValues = 16
+
+// TypeStats describes the values of the elements or attributes at a path,
+// to infer their type. The value of an element is its character data,
+// unless it contains elements.
+type TypeStats struct {
+	// MaxOccurs is the largest number of elements at the path within one
+	// parent element.
+	MaxOccurs int64 `json:"max_occurs,omitempty"`
+	// Values counts the values, not counting the Empty ones, and the
+	// following fields count those parsed as integers, floating-point
+	// numbers, integers included, booleans ("true" or "false"), RFC 3339
+	// times, and other dates, such as "2006-01-02" or RFC 1123 dates.
+	Values int64 `json:"values"`
+	Empty  int64 `json:"empty,omitempty"`
+	Ints   int64 `json:"ints,omitempty"`
+	Floats int64 `json:"floats,omitempty"`
+	Bools  int64 `json:"bools,omitempty"`
+	Times  int64 `json:"times,omitempty"`
+	Dates  int64 `json:"dates,omitempty"`
+	// Enum counts each distinct value, until there are more than
+	// MaxEnumValues of them and ManyValues is set.
+	Enum       map[string]int64 `json:"enum,omitempty"`
+	ManyValues bool             `json:"many_values,omitempty"`
+	// Mixed is set if an element contains both elements and text.
+	Mixed bool `json:"mixed,omitempty"`
+}
+
+// dateLayouts are the layouts of the values counted as TypeStats.Dates.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+func (t *TypeStats) add(v []byte) {
+	v = trimSpace(v)
+	if len(v) == 0 {
+		t.Empty++
+		return
+	}
+	t.Values++
+	s := string(v)
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		t.Ints++
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		t.Floats++
+	}
+	if s == "true" || s == "false" {
+		t.Bools++
+	}
+	if _, err := time.Parse(time.RFC3339, s); err == nil {
+		t.Times++
+	} else {
+		for _, layout := range dateLayouts {
+			if _, err := time.Parse(layout, s); err == nil {
+				t.Dates++
+				break
+			}
+		}
+	}
+	if t.ManyValues {
+		return
+	}
+	if t.Enum == nil {
+		t.Enum = make(map[string]int64)
+	}
+	t.Enum[s]++
+	if len(t.Enum) > MaxEnumValues {
+		t.Enum = nil
+		t.ManyValues = true
+	}
+}
+
+// Kind returns the kind of the values: "int", "float", "bool", "time",
+// "date", "enum", if there are few distinct values each seen at least
+// twice on average, "string", or "" if there are no values.
+func (t *TypeStats) Kind() string {
+	switch n := t.Values; {
+	case n == 0:
+		return ""
+	case t.Ints == n:
+		return "int"
+	case t.Floats == n:
+		return "float"
+	case t.Bools == n:
+		return "bool"
+	case t.Times == n:
+		return "time"
+	case t.Times+t.Dates == n:
+		return "date"
+	case !t.ManyValues && n >= 2*int64(len(t.Enum)):
+		return "enum"
+	}
+	return "string"
+}
+
+// valueFrame collects the value of an open element for Profile.Add.
+type valueFrame struct {
+	value    []byte
+	children map[string]int64
+}
+
+func (f *valueFrame) child(name []byte) {
+	if f.children == nil {
+		f.children = make(map[string]int64)
+	}
+	f.children[string(name)]++
+}
+
+func (f *valueFrame) text(e Event) error {
+	switch e.Type() {
+	case EventCData:
+		f.value = append(f.value, trim(e.Bytes, "<![CDATA[", "]]>")...)
+	default:
+		n := len(f.value)
+		f.value = append(f.value, e.Bytes...)
+		v, err := Unescape(f.value[n:])
+		if err != nil {
+			return err
+		}
+		f.value = append(f.value[:n], v...)
+	}
+	return nil
+}
+
+// endFrame adds the statistics of the element at path, with the values f
+// collected.
+func (p *Profile) endFrame(path []byte, f *valueFrame) {
+	t := p.types(path)
+	if len(f.children) == 0 {
+		t.add(f.value)
+		return
+	}
+	if len(trimSpace(f.value)) > 0 {
+		t.Mixed = true
+	}
+	for name, n := range f.children {
+		c := p.types([]byte(string(path) + "/" + name))
+		c.MaxOccurs = max(c.MaxOccurs, n)
+	}
+}
+
+func (p *Profile) types(path []byte) *TypeStats {
+	if p.Types == nil {
+		p.Types = make(map[string]*TypeStats)
+	}
+	t, ok := p.Types[string(path)]
+	if !ok {
+		t = &TypeStats{}
+		p.Types[string(path)] = t
+	}
+	return t
+}
+
+// WriteGoTypes writes Go type declarations for decoding the documents
+// profiled with InferTypes by encoding/xml: a struct type for each
+// element with attributes or elements, named after the element, with a
+// field for each attribute and child element, and a slice for elements
+// occurring more than once. Values are typed after TypeStats.Kind; enum
+// and date values are strings. The declarations use the packages
+// encoding/xml and time, which the caller imports.
+func (p *Profile) WriteGoTypes(w io.Writer) error {
+	g := goTypes{
+		p:        p,
+		children: make(map[string][]string),
+		names:    make(map[string]string),
+		used:     make(map[string]bool),
+	}
+	var paths []string
+	for path := range p.Paths {
+		parent, step := "", path
+		if i := strings.LastIndexByte(path, '/'); i >= 0 {
+			parent, step = path[:i], path[i+1:]
+		}
+		if _, ok := nsDecl([]byte(strings.TrimPrefix(step, "@"))); ok && step[0] == '@' {
+			continue
+		}
+		if parent != "" {
+			g.children[parent] = append(g.children[parent], path)
+		}
+		if step[0] != '@' {
+			paths = append(paths, path)
+		}
+	}
+	for _, c := range g.children {
+		slices.Sort(c)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		if g.isStruct(path) {
+			g.name(path)
+		}
+	}
+	for _, path := range paths {
+		if g.isStruct(path) {
+			g.writeStruct(path)
+		}
+	}
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// goTypes generates the declarations of Profile.WriteGoTypes.
+type goTypes struct {
+	p   *Profile
+	buf bytes.Buffer
+	// children maps the paths of elements to the sorted paths of their
+	// attributes, but for namespace declarations, and child elements.
+	children map[string][]string
+	names    map[string]string
+	used     map[string]bool
+}
+
+func (g *goTypes) isStruct(path string) bool {
+	return len(g.children[path]) > 0
+}
+
+// name returns the type name of the element at path, made unique by the
+// names of its ancestors.
+func (g *goTypes) name(path string) string {
+	if name, ok := g.names[path]; ok {
+		return name
+	}
+	steps := strings.Split(path, "/")
+	name := ""
+	for i := len(steps) - 1; i >= 0; i-- {
+		name = goName(localName(steps[i])) + name
+		if !g.used[name] {
+			break
+		}
+	}
+	for base, i := name, 2; g.used[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	g.used[name] = true
+	g.names[path] = name
+	return name
+}
+
+func (g *goTypes) writeStruct(path string) {
+	fmt.Fprintf(&g.buf, "type %s struct {\n", g.name(path))
+	if !strings.Contains(path, "/") {
+		fmt.Fprintf(&g.buf, "XMLName xml.Name `xml:%q`\n", localName(path))
+	}
+	fields := map[string]bool{"XMLName": true}
+	field := func(name string) string {
+		for base, i := name, 2; fields[name]; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		fields[name] = true
+		return name
+	}
+	if t := g.p.Types[path]; t != nil && t.Kind() != "" {
+		typ, comment := goType(t)
+		fmt.Fprintf(&g.buf, "%s %s `xml:\",chardata\"`%s\n", field("Value"), typ, comment)
+	}
+	for _, c := range g.children[path] {
+		step := c[len(path)+1:]
+		if attr, ok := strings.CutPrefix(step, "@"); ok {
+			typ, comment := goType(g.p.Types[c])
+			fmt.Fprintf(&g.buf, "%s %s `xml:\"%s,attr\"`%s\n", field(goName(localName(attr))), typ, localName(attr), comment)
+			continue
+		}
+		typ, comment := "", ""
+		if g.isStruct(c) {
+			typ = g.name(c)
+		} else {
+			typ, comment = goType(g.p.Types[c])
+		}
+		if t := g.p.Types[c]; t != nil && t.MaxOccurs > 1 {
+			typ = "[]" + typ
+		}
+		fmt.Fprintf(&g.buf, "%s %s `xml:%q`%s\n", field(goName(localName(step))), typ, localName(step), comment)
+	}
+	g.buf.WriteString("}\n\n")
+}
+
+// goType returns the Go type of values described by t, with a comment.
+func goType(t *TypeStats) (string, string) {
+	if t == nil {
+		return "string", ""
+	}
+	switch t.Kind() {
+	case "int":
+		return "int64", ""
+	case "float":
+		return "float64", ""
+	case "bool":
+		return "bool", ""
+	case "time":
+		return "time.Time", ""
+	case "date":
+		return "string", " // date"
+	case "enum":
+		values := make([]string, 0, len(t.Enum))
+		for v := range t.Enum {
+			values = append(values, strconv.Quote(v))
+		}
+		slices.Sort(values)
+		return "string", " // " + strings.Join(values, ", ")
+	}
+	return "string", ""
+}
+
+func localName(qname string) string {
+	_, local := splitQName([]byte(qname))
+	return string(local)
+}
+
+// goName returns an exported Go identifier for the XML name s, such as
+// "PubDate" for "pub-date".
+func goName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(c) {
+			b.WriteByte('X')
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		b.WriteRune(c)
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
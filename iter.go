@@ -0,0 +1,58 @@
+//go:build go1.23
+
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "iter"
+
+// Attrs returns an iterator over the attributes in b, the bytes following
+// the name of a start tag as returned by Name. Values are as written,
+// quoted and escaped. Iteration stops after the first error.
+//
+//	_, rest := gosax.Name(e.Bytes)
+//	for attr, err := range gosax.Attrs(rest) {
+//		...
+//	}
+func Attrs(b []byte) iter.Seq2[Attribute, error] {
+	return func(yield func(Attribute, error) bool) {
+		for len(b) > 0 {
+			attr, rest, err := NextAttribute(b)
+			if err != nil {
+				yield(Attribute{}, err)
+				return
+			}
+			if len(attr.Key) == 0 {
+				return
+			}
+			b = rest
+			if !yield(attr, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"io"
+)
+
+// A Join copies a document, enriching the elements matching Pattern with
+// values looked up by a key extracted from each of them, as when joining a
+// feed against reference data. Elements are looked up in batches, and only
+// the elements of the current batch and the output since its first element
+// are held in memory.
+type Join[K comparable, V any] struct {
+	// Pattern is the path pattern of the elements, such as "feed/entry" or
+	// "*/item". Matching elements are not searched for nested matches.
+	Pattern string
+	// Key extracts the key of an element from its raw bytes.
+	Key func(subtree []byte) (K, error)
+	// Lookup returns the values of a batch of distinct keys. Keys missing
+	// from the result are not joined.
+	Lookup func(keys []K) (map[K]V, error)
+	// Inject writes the value of an element to w. It is called with the
+	// start tag still open, so it can add attributes with w.Attr before
+	// writing child elements, which precede the content of the element.
+	Inject func(w *Writer, v V) error
+	// BatchSize is the maximum number of keys passed to Lookup at once,
+	// 1 if not positive.
+	BatchSize int
+}
+
+type joinItem[K comparable] struct {
+	// at is the length of the staged output preceding the element.
+	at      int
+	subtree []byte
+	key     K
+}
+
+// Copy writes the events of r to w until EventEOF, joining the matching
+// elements, and flushes w.
+func (j *Join[K, V]) Copy(w *Writer, r *Reader) error {
+	out := w.w
+	defer func() { w.w = out }()
+	// The output following the first element of a batch is staged until
+	// the batch is looked up.
+	var staged bytes.Buffer
+	var items []joinItem[K]
+	var path elementPath
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return err
+		}
+		path.update(e)
+		switch e.Type() {
+		case EventEOF:
+			if err := j.flush(w, out, &staged, items); err != nil {
+				return err
+			}
+			return w.Flush()
+		case EventStart:
+			if !matchPath(j.Pattern, path.bytes()) {
+				break
+			}
+			sr, err := r.SubtreeReader()
+			if err != nil {
+				return err
+			}
+			subtree, err := io.ReadAll(sr)
+			if err != nil {
+				return err
+			}
+			if !isSelfClosing(e.Bytes) {
+				path.pop()
+			}
+			key, err := j.Key(subtree)
+			if err != nil {
+				return err
+			}
+			w.closeStart()
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				w.w = &staged
+			}
+			items = append(items, joinItem[K]{staged.Len(), subtree, key})
+			if len(items) >= max(j.BatchSize, 1) {
+				if err := j.flush(w, out, &staged, items); err != nil {
+					return err
+				}
+				items = items[:0]
+			}
+			continue
+		}
+		if err := w.WriteEvent(e); err != nil {
+			return err
+		}
+	}
+}
+
+// flush looks up the keys of items and writes the staged output to out,
+// with the joined elements in place.
+func (j *Join[K, V]) flush(w *Writer, out io.Writer, staged *bytes.Buffer, items []joinItem[K]) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	keys := make([]K, 0, len(items))
+	seen := make(map[K]bool, len(items))
+	for _, it := range items {
+		if !seen[it.key] {
+			seen[it.key] = true
+			keys = append(keys, it.key)
+		}
+	}
+	values, err := j.Lookup(keys)
+	if err != nil {
+		return err
+	}
+	w.w = out
+	b := staged.Bytes()
+	pos := 0
+	for _, it := range items {
+		if _, err := out.Write(b[pos:it.at]); err != nil {
+			return err
+		}
+		pos = it.at
+		v, ok := values[it.key]
+		if err := j.writeElement(w, it.subtree, v, ok); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if _, err := out.Write(b[pos:]); err != nil {
+		return err
+	}
+	staged.Reset()
+	return nil
+}
+
+// writeElement writes the element subtree to w, injecting v if ok.
+func (j *Join[K, V]) writeElement(w *Writer, subtree []byte, v V, ok bool) error {
+	if !ok {
+		return w.Raw(subtree)
+	}
+	r := NewReaderSize(bytes.NewReader(subtree), len(subtree)+1)
+	start, err := r.Event()
+	if err != nil {
+		return err
+	}
+	name, attrs := Name(start.Bytes)
+	if err := w.StartElement(name); err != nil {
+		return err
+	}
+	for len(attrs) > 0 {
+		attr, rest, err := NextAttribute(attrs)
+		if err != nil {
+			return err
+		}
+		attrs = rest
+		if len(attr.Key) == 0 || len(attr.Value) < 2 {
+			continue
+		}
+		value, err := UnescapeAttr(attr.Value[1 : len(attr.Value)-1])
+		if err != nil {
+			return err
+		}
+		if err := w.Attr(attr.Key, value); err != nil {
+			return err
+		}
+	}
+	if err := j.Inject(w, v); err != nil {
+		return err
+	}
+	if isSelfClosing(start.Bytes) {
+		return w.EndElement()
+	}
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return err
+		}
+		if e.Type() == EventEOF {
+			return nil
+		}
+		if err := w.WriteEvent(e); err != nil {
+			return err
+		}
+	}
+}
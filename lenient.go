@@ -0,0 +1,221 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "bytes"
+
+// lenient applies Lenient to ev. It reports false for an event it drops.
+func (r *Reader) lenient(ev Event) (Event, bool, error) {
+	if r.lenientSynth {
+		// The end tags closeOpen adds are placed at the event they precede.
+		r.lenientSynth = false
+		r.lastLen = len(r.lenientHeld.Bytes)
+	}
+	r.open.settle()
+	switch ev.Type() {
+	case EventText:
+		if b, ok := appendLenientText(r.lenientBuf[:0], ev.Bytes); ok {
+			r.lenientBuf = b
+			r.raw, ev.Bytes = ev.Bytes, b
+		}
+	case EventStart:
+		name, _ := Name(ev.Bytes)
+		if d := r.open.depth(); d > 0 && bytes.Equal(r.open.top(), name) && siblingClosed[string(name)] {
+			// A <li> closes the <li> before it.
+			return r.closeOpen(d-1, ev)
+		}
+		b, ok := appendLenientTag(r.lenientBuf[:0], ev.Bytes)
+		if voidElements[string(name)] && !isSelfClosing(ev.Bytes) {
+			b = append(b[:len(b)-1], "/>"...)
+			ok = true
+		}
+		if ok {
+			r.lenientBuf = b
+			r.raw, ev.Bytes = ev.Bytes, b
+		}
+	case EventEnd:
+		if isSelfClosing(ev.Bytes) {
+			if b, ok := appendLenientTag(r.lenientBuf[:0], ev.Bytes); ok {
+				r.lenientBuf = b
+				r.raw, ev.Bytes = ev.Bytes, b
+			}
+			break
+		}
+		name, _ := Name(ev.Bytes)
+		i := r.open.depth() - 1
+		for i >= 0 && !bytes.Equal(r.open.at(i), name) {
+			i--
+		}
+		if i < 0 {
+			// An end tag without a start tag is dropped.
+			return ev, false, nil
+		}
+		if i < r.open.depth()-1 {
+			return r.closeOpen(i+1, ev)
+		}
+	case EventEOF:
+		if r.open.depth() > 0 {
+			return r.closeOpen(0, ev)
+		}
+	}
+	return ev, true, nil
+}
+
+// voidElements holds the HTML elements that have no end tag, whose start
+// tags Lenient makes self-closing.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// siblingClosed holds the HTML elements whose end tag Lenient implies
+// before the start tag of a sibling with the same name.
+var siblingClosed = map[string]bool{
+	"dd": true, "dt": true, "li": true, "option": true, "p": true,
+	"td": true, "th": true, "tr": true,
+}
+
+// closeOpen returns end tags for the open elements from the depth-th one
+// inward, innermost first, and then ev.
+func (r *Reader) closeOpen(depth int, ev Event) (Event, bool, error) {
+	r.lenientEnds = r.lenientEnds[:0]
+	for i := r.open.depth() - 1; i >= depth; i-- {
+		r.lenientEnds = append(r.lenientEnds, "</"...)
+		r.lenientEnds = append(r.lenientEnds, r.open.at(i)...)
+		r.lenientEnds = append(r.lenientEnds, '>')
+	}
+	r.lenientHeld = ev
+	r.lenientNext = r.state
+	r.state = (*Reader).stateLenientEnd
+	ev, err := r.stateLenientEnd()
+	return ev, true, err
+}
+
+// stateLenientEnd returns the next end tag made by closeOpen, and then
+// the event it held back.
+func (r *Reader) stateLenientEnd() (Event, error) {
+	if len(r.lenientEnds) == 0 {
+		r.state = r.lenientNext
+		return r.lenientHeld, nil
+	}
+	i := bytes.IndexByte(r.lenientEnds, '>') + 1
+	ev := Event{Bytes: r.lenientEnds[:i:i], value: uint64(EventEnd)}
+	r.lenientEnds = r.lenientEnds[i:]
+	r.lenientSynth = true
+	return ev, nil
+}
+
+// appendLenientText appends b to dst with bare '&' characters escaped, and
+// reports whether there were any.
+func appendLenientText(dst, b []byte) ([]byte, bool) {
+	changed := false
+	for {
+		i := bytes.IndexByte(b, '&')
+		if i < 0 {
+			return append(dst, b...), changed
+		}
+		dst = append(dst, b[:i+1]...)
+		if refEnd(b[i+1:]) <= 0 {
+			dst = append(dst, "amp;"...)
+			changed = true
+		}
+		b = b[i+1:]
+	}
+}
+
+// appendLenientTag appends the start tag b to dst with its attribute values
+// quoted and their bare '&' characters escaped, and reports whether it
+// changed. An attribute without a value gets its name as value.
+func appendLenientTag(dst, b []byte) ([]byte, bool) {
+	name, attrs := Name(b)
+	dst = append(dst, '<')
+	dst = append(dst, name...)
+	changed := false
+	for {
+		attrs = trimLeftSpace(attrs)
+		if len(attrs) == 0 {
+			break
+		}
+		i := 0
+		for i < len(attrs) && !whitespace[attrs[i]] && attrs[i] != '=' {
+			i++
+		}
+		key := attrs[:i]
+		attrs = trimLeftSpace(attrs[i:])
+		dst = append(dst, ' ')
+		dst = append(dst, key...)
+		dst = append(dst, '=')
+		if len(attrs) == 0 || attrs[0] != '=' {
+			// A minimized attribute, as in HTML.
+			dst = append(dst, '"')
+			dst = append(dst, key...)
+			dst = append(dst, '"')
+			changed = true
+			continue
+		}
+		attrs = trimLeftSpace(attrs[1:])
+		var value []byte
+		if len(attrs) > 0 && (attrs[0] == '"' || attrs[0] == '\'') {
+			j := bytes.IndexByte(attrs[1:], attrs[0])
+			if j < 0 {
+				j = len(attrs) - 1
+				changed = true
+			}
+			value, attrs = attrs[1:1+j], attrs[min(2+j, len(attrs)):]
+		} else {
+			j := 0
+			for j < len(attrs) && !whitespace[attrs[j]] {
+				j++
+			}
+			value, attrs = attrs[:j], attrs[j:]
+			changed = true
+		}
+		dst = append(dst, '"')
+		n := len(dst)
+		var amp bool
+		dst, amp = appendLenientText(dst, value)
+		changed = changed || amp
+		if bytes.IndexByte(dst[n:], '"') >= 0 {
+			v := bytes.ReplaceAll(dst[n:], []byte(`"`), []byte("&quot;"))
+			dst = append(dst[:n], v...)
+		}
+		dst = append(dst, '"')
+	}
+	if isSelfClosing(b) {
+		dst = append(dst, '/')
+	}
+	dst = append(dst, '>')
+	return dst, changed
+}
+
+func trimLeftSpace(b []byte) []byte {
+	for len(b) > 0 && whitespace[b[0]] {
+		b = b[1:]
+	}
+	return b
+}
@@ -0,0 +1,182 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "fmt"
+
+// Default values used for the zero fields of Limits.
+const (
+	DefaultMaxBytes      = 10 << 20
+	DefaultMaxNamespaces = 1024
+	DefaultMaxPrefixLen  = 256
+
+	DefaultMaxDepth        = 10000
+	DefaultMaxTokenSize    = 64 << 20
+	DefaultMaxAttrs        = 1024
+	DefaultMaxAttrValueLen = 1 << 20
+
+	DefaultMaxDocTypeSize     = 1 << 20
+	DefaultMaxEntityExpansion = 1 << 20
+)
+
+// Limits bounds the resources used to decode untrusted input. Zero fields
+// take the default value and negative fields mean no limit.
+type Limits struct {
+	// MaxBytes is the maximum size of the input, DefaultMaxBytes by default.
+	// It is enforced by DecodeRequest and DecodeResponse, where exceeding it
+	// fails with an *http.MaxBytesError.
+	MaxBytes int64
+	// MaxNamespaces is the maximum number of namespace bindings in scope at
+	// once, DefaultMaxNamespaces by default.
+	MaxNamespaces int
+	// MaxPrefixLen is the maximum length of a declared namespace prefix,
+	// DefaultMaxPrefixLen by default.
+	MaxPrefixLen int
+
+	// MaxDepth is the maximum nesting depth of elements, DefaultMaxDepth by
+	// default.
+	MaxDepth int
+	// MaxTokenSize is the maximum size of an event in the input, such as a
+	// tag or a text, DefaultMaxTokenSize by default. It also bounds the
+	// buffer of the Reader, which fails before reading a larger event.
+	MaxTokenSize int
+	// MaxAttrs is the maximum number of attributes of a start tag,
+	// DefaultMaxAttrs by default.
+	MaxAttrs int
+	// MaxAttrValueLen is the maximum length of an attribute value as
+	// written, DefaultMaxAttrValueLen by default.
+	MaxAttrValueLen int
+
+	// MaxDocTypeSize is the maximum size of a document type declaration,
+	// including its internal subset, DefaultMaxDocTypeSize by default.
+	MaxDocTypeSize int
+	// MaxEntityExpansion is the maximum total size of the replacement text
+	// produced by expanding entities declared in the internal subset,
+	// counting nested references, DefaultMaxEntityExpansion by default.
+	MaxEntityExpansion int
+}
+
+// resolve returns l with the defaults of the zero fields filled in.
+func (l Limits) resolve() Limits {
+	return Limits{
+		MaxBytes:        l.maxBytes(),
+		MaxNamespaces:   l.maxNamespaces(),
+		MaxPrefixLen:    l.maxPrefixLen(),
+		MaxDepth:        limit(l.MaxDepth, DefaultMaxDepth),
+		MaxTokenSize:    limit(l.MaxTokenSize, DefaultMaxTokenSize),
+		MaxAttrs:        limit(l.MaxAttrs, DefaultMaxAttrs),
+		MaxAttrValueLen: limit(l.MaxAttrValueLen, DefaultMaxAttrValueLen),
+
+		MaxDocTypeSize:     limit(l.MaxDocTypeSize, DefaultMaxDocTypeSize),
+		MaxEntityExpansion: limit(l.MaxEntityExpansion, DefaultMaxEntityExpansion),
+	}
+}
+
+func (l Limits) maxBytes() int64 {
+	return limit(l.MaxBytes, DefaultMaxBytes)
+}
+
+func (l Limits) maxNamespaces() int {
+	return limit(l.MaxNamespaces, DefaultMaxNamespaces)
+}
+
+func (l Limits) maxPrefixLen() int {
+	return limit(l.MaxPrefixLen, DefaultMaxPrefixLen)
+}
+
+// limit returns def for zero and -1 for no limit.
+func limit[T int | int64](v, def T) T {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return -1
+	}
+	return v
+}
+
+// A LimitError reports input exceeding one of the Limits.
+type LimitError struct {
+	// Limit is the name of the exceeded Limits field.
+	Limit string
+	// Max is the value of the limit.
+	Max int64
+	// Offset is the input offset of the event exceeding the limit, or -1
+	// if the limit was not exceeded by reading input.
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("gosax: %s of %d exceeded", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("gosax: %s of %d exceeded at offset %d", e.Limit, e.Max, e.Offset)
+}
+
+// limitError returns a LimitError for the event just read.
+func (r *Reader) limitError(name string, max int) error {
+	return &LimitError{Limit: name, Max: int64(max), Offset: r.reader.inputOffset() - int64(r.lastLen)}
+}
+
+// checkLimits checks ev against the limits of r, resolved.
+func (r *Reader) checkLimits(ev Event) error {
+	l := &r.limits
+	if l.MaxTokenSize >= 0 && len(ev.Bytes) > l.MaxTokenSize {
+		// The event fit in the buffer, so extend did not check it.
+		return r.limitError("MaxTokenSize", l.MaxTokenSize)
+	}
+	switch ev.Type() {
+	case EventStart:
+		if l.MaxDepth >= 0 && r.depth >= l.MaxDepth {
+			return r.limitError("MaxDepth", l.MaxDepth)
+		}
+		_, attrs := Name(ev.Bytes)
+		n := 0
+		for len(attrs) > 0 {
+			attr, rest, err := NextAttribute(attrs)
+			if err != nil {
+				return r.eventError(ev.Bytes, "%v", err)
+			}
+			if len(attr.Key) == 0 {
+				break
+			}
+			attrs = rest
+			n++
+			if l.MaxAttrs >= 0 && n > l.MaxAttrs {
+				return r.limitError("MaxAttrs", l.MaxAttrs)
+			}
+			if l.MaxAttrValueLen >= 0 && len(attr.Value)-2 > l.MaxAttrValueLen {
+				return r.limitError("MaxAttrValueLen", l.MaxAttrValueLen)
+			}
+		}
+	case EventDocType:
+		if l.MaxDocTypeSize >= 0 && len(ev.Bytes) > l.MaxDocTypeSize {
+			return r.limitError("MaxDocTypeSize", l.MaxDocTypeSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"expvar"
+	"fmt"
+)
+
+// Observer receives metrics from a Reader.
+// Methods are called synchronously from Reader.Event and must be cheap.
+type Observer interface {
+	// ObserveEvent is called for each event returned by Reader.Event.
+	ObserveEvent(e Event)
+	// ObserveRefill is called after each read from the underlying
+	// io.Reader with the number of bytes read.
+	ObserveRefill(n int)
+	// ObserveError is called for each error returned by Reader.Event.
+	ObserveError(err error)
+}
+
+// Metrics is an Observer that counts with expvar.Int, so the counters can
+// be published with expvar and scraped by exporters.
+// A Metrics may be shared by Readers in different goroutines.
+type Metrics struct {
+	Events  expvar.Int
+	Bytes   expvar.Int
+	Refills expvar.Int
+	Errors  expvar.Int
+}
+
+func (m *Metrics) ObserveEvent(Event) {
+	m.Events.Add(1)
+}
+
+func (m *Metrics) ObserveRefill(n int) {
+	m.Refills.Add(1)
+	m.Bytes.Add(int64(n))
+}
+
+func (m *Metrics) ObserveError(error) {
+	m.Errors.Add(1)
+}
+
+// String returns the counters as a JSON object, making *Metrics an
+// expvar.Var.
+func (m *Metrics) String() string {
+	return fmt.Sprintf(`{"events": %d, "bytes": %d, "refills": %d, "errors": %d}`,
+		m.Events.Value(), m.Bytes.Value(), m.Refills.Value(), m.Errors.Value())
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ReadMultistreamIndex reads the index of a multistream bzip2 archive of
+// size bytes, such as a Wikipedia pages-articles-multistream dump, and
+// returns the byte range of each stream in order. Each line of the index
+// has the form "offset:id:title", where offset is that of the stream
+// holding the page. The stream before the first indexed one, holding the
+// document prolog, is included; the last range extends to the end of the
+// archive. A compressed index can be read through Decompress.
+func ReadMultistreamIndex(r io.Reader, size int64) ([]Section, error) {
+	offsets := []int64{0}
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		s, _, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			if s == "" {
+				continue
+			}
+			return nil, fmt.Errorf("gosax: multistream index line %d: missing offset", line)
+		}
+		off, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || off < 0 || off > size {
+			return nil, fmt.Errorf("gosax: multistream index line %d: invalid offset %q", line, s)
+		}
+		offsets = append(offsets, off)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(offsets)
+	offsets = slices.Compact(offsets)
+	streams := make([]Section, 0, len(offsets))
+	for i, off := range offsets {
+		end := size
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		if end > off {
+			streams = append(streams, Section{off, end - off})
+		}
+	}
+	return streams, nil
+}
+
+// ProcessMultistream decompresses and parses the streams of a multistream
+// bzip2 archive independently. fn is called for each stream on up to
+// workers goroutines (GOMAXPROCS if workers <= 0), with the index of the
+// stream and a Reader over its contents: a run of sibling elements, such as
+// <page>s, without a common root. The first error returned by fn stops
+// processing and is returned.
+func ProcessMultistream(ra io.ReaderAt, streams []Section, workers int, fn func(i int, r *Reader) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	jobs := make(chan int)
+	stop := make(chan struct{})
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(streams)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 0, compressedBufSize)
+			for i := range jobs {
+				s := streams[i]
+				sr := bufio.NewReaderSize(io.NewSectionReader(ra, s.Offset, s.Length), compressedBufSize)
+				if err := fn(i, NewReaderBuf(bzip2.NewReader(sr), buf)); err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(stop)
+					})
+				}
+			}
+		}()
+	}
+loop:
+	for i := range streams {
+		select {
+		case jobs <- i:
+		case <-stop:
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
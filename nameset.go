@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+// A NameSet is a compiled set of element names. It classifies a name with a
+// single pass over its bytes, using a perfect hash built by NewNameSet, and
+// without converting the name to a string.
+type NameSet struct {
+	names []string
+	table []int32 // index into names, or -1
+	seed  uint32
+	mask  uint32
+}
+
+// NewNameSet returns a NameSet of names. The index of a name in the set is
+// its position in names; a repeated name keeps its first position.
+func NewNameSet(names ...string) *NameSet {
+	s := &NameSet{names: names}
+	size := 8
+	for size < 2*len(names) {
+		size *= 2
+	}
+	for {
+		s.table = make([]int32, size)
+		s.mask = uint32(size - 1)
+		for seed := uint32(1); seed <= 256; seed++ {
+			s.seed = seed * 0x9e3779b1
+			if s.build() {
+				return s
+			}
+		}
+		size *= 2
+	}
+}
+
+// build fills the table with the current seed, reporting whether the names
+// hash without collisions.
+func (s *NameSet) build() bool {
+	for i := range s.table {
+		s.table[i] = -1
+	}
+	for i, name := range s.names {
+		h := s.hash([]byte(name))
+		if j := s.table[h]; j >= 0 {
+			if s.names[j] == name {
+				continue
+			}
+			return false
+		}
+		s.table[h] = int32(i)
+	}
+	return true
+}
+
+func (s *NameSet) hash(b []byte) uint32 {
+	h := s.seed ^ uint32(len(b))
+	for _, c := range b {
+		h = (h ^ uint32(c)) * 0x01000193
+	}
+	return (h ^ h>>16) & s.mask
+}
+
+// Index returns the index of name in s, or -1 if it is not in s.
+func (s *NameSet) Index(name []byte) int {
+	i := s.table[s.hash(name)]
+	if i < 0 || s.names[i] != string(name) {
+		return -1
+	}
+	return int(i)
+}
+
+// Contains reports whether name is in s.
+func (s *NameSet) Contains(name []byte) bool {
+	return s.Index(name) >= 0
+}
+
+// IndexTag is like Index, but takes the bytes of a start or end tag event.
+func (s *NameSet) IndexTag(b []byte) int {
+	name, _ := Name(b)
+	return s.Index(name)
+}
+
+// Names returns the names in s.
+func (s *NameSet) Names() []string {
+	return s.names
+}
@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+// nsBinding is a namespace prefix bound to a URI.
+// The default namespace has an empty prefix.
+type nsBinding struct {
+	prefix string
+	uri    string
+}
+
+// nsScope is a stack of namespace bindings with one frame per element.
+type nsScope struct {
+	bindings []nsBinding
+	marks    []int
+	// size is the total length of the prefixes and URIs of bindings.
+	size int
+	// maxBindings and maxPrefixLen are the limits checked by checkLimits,
+	// when positive.
+	maxBindings  int
+	maxPrefixLen int
+}
+
+func (s *nsScope) push() {
+	s.marks = append(s.marks, len(s.bindings))
+}
+
+func (s *nsScope) pop() {
+	n := len(s.marks) - 1
+	for _, b := range s.bindings[s.marks[n]:] {
+		s.size -= len(b.prefix) + len(b.uri)
+	}
+	s.bindings = s.bindings[:s.marks[n]]
+	s.marks = s.marks[:n]
+}
+
+func (s *nsScope) declare(prefix, uri string) {
+	s.bindings = append(s.bindings, nsBinding{prefix, uri})
+	s.size += len(prefix) + len(uri)
+}
+
+// reset empties s, keeping its buffers and limits.
+func (s *nsScope) reset() {
+	s.bindings = s.bindings[:0]
+	s.marks = s.marks[:0]
+	s.size = 0
+}
+
+// setLimits sets the limits checked by checkLimits.
+func (s *nsScope) setLimits(l Limits) {
+	s.maxBindings = l.maxNamespaces()
+	s.maxPrefixLen = l.maxPrefixLen()
+}
+
+// checkLimits returns the name and value of the limit that declaring prefix
+// would exceed, or an empty name.
+func (s *nsScope) checkLimits(prefix string) (string, int) {
+	if s.maxPrefixLen > 0 && len(prefix) > s.maxPrefixLen {
+		return "MaxPrefixLen", s.maxPrefixLen
+	}
+	if s.maxBindings > 0 && len(s.bindings) >= s.maxBindings {
+		return "MaxNamespaces", s.maxBindings
+	}
+	return "", 0
+}
+
+// lookup returns the URI bound to prefix in the innermost scope.
+func (s *nsScope) lookup(prefix string) (string, bool) {
+	for i := len(s.bindings) - 1; i >= 0; i-- {
+		if s.bindings[i].prefix == prefix {
+			return s.bindings[i].uri, true
+		}
+	}
+	switch prefix {
+	case "xml":
+		return xmlURL, true
+	case "xmlns":
+		return xmlnsURL, true
+	}
+	return "", false
+}
+
+// frame returns the bindings declared in the innermost frame.
+func (s *nsScope) frame() []nsBinding {
+	if len(s.marks) == 0 {
+		return s.bindings
+	}
+	return s.bindings[s.marks[len(s.marks)-1]:]
+}
+
+// visible calls fn for each binding in scope, innermost first,
+// skipping bindings shadowed by an inner declaration of the same prefix.
+func (s *nsScope) visible(fn func(nsBinding)) {
+	for i := len(s.bindings) - 1; i >= 0; i-- {
+		b := s.bindings[i]
+		shadowed := false
+		for _, o := range s.bindings[i+1:] {
+			if o.prefix == b.prefix {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			fn(b)
+		}
+	}
+}
+
+const (
+	xmlURL   = "http://www.w3.org/XML/1998/namespace"
+	xmlnsURL = "http://www.w3.org/2000/xmlns/"
+)
+
+// splitQName splits a qualified name into its prefix and local part.
+func splitQName(b []byte) ([]byte, []byte) {
+	for i, c := range b {
+		if c == ':' {
+			return b[:i], b[i+1:]
+		}
+	}
+	return nil, b
+}
+
+// nsDecl reports whether key is a namespace declaration and returns the
+// declared prefix.
+func nsDecl(key []byte) (string, bool) {
+	if string(key) == "xmlns" {
+		return "", true
+	}
+	if len(key) > 6 && string(key[:6]) == "xmlns:" {
+		return string(key[6:]), true
+	}
+	return "", false
+}
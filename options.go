@@ -0,0 +1,341 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+)
+
+// Config mirrors the reader options of quick-xml, to ease porting code
+// between the two. Apply sets the equivalent options of a Reader.
+type Config struct {
+	// TrimText trims whitespace around text and drops blank text events.
+	TrimText bool
+	// ExpandEmptyElements reports <a/> as a start and an end event.
+	ExpandEmptyElements bool
+	// CheckEndNames fails on mismatched end tags.
+	CheckEndNames bool
+	// CheckComments fails on comments containing "--".
+	CheckComments bool
+}
+
+// Apply sets the options of r equivalent to c.
+// It must be called before the first call to Event.
+func (c Config) Apply(r *Reader) {
+	r.TrimText = c.TrimText
+	r.EmitSelfClosingTag = c.ExpandEmptyElements
+	r.CheckEndNames = c.CheckEndNames
+	r.CheckComments = c.CheckComments
+}
+
+// DTD returns the document type declaration read so far, or nil.
+// It is only parsed when an option such as ApplyDTDDefaults or
+// NormalizeDTDAttrs needs it.
+func (r *Reader) DTD() *DTD {
+	return r.dtd
+}
+
+// A Normalizer applies a Unicode normalization form to text. The forms of
+// golang.org/x/text/unicode/norm, such as norm.NFC, implement it.
+type Normalizer interface {
+	// IsNormal reports whether b is already normalized.
+	IsNormal(b []byte) bool
+	// Append appends the normalized form of src to out.
+	Append(out []byte, src ...byte) []byte
+}
+
+// TagWhitespaceMode selects how a Reader treats characters inside tags that
+// are not XML whitespace but are commonly produced as such: form feed,
+// vertical tab and no-break space.
+type TagWhitespaceMode uint8
+
+const (
+	// TagWhitespaceXML takes them as part of the surrounding name, as XML
+	// does.
+	TagWhitespaceXML TagWhitespaceMode = iota
+	// TagWhitespaceLenient replaces them with spaces in the event, so names
+	// and attributes are split where the producer meant.
+	TagWhitespaceLenient
+	// TagWhitespaceStrict makes Event fail with a SyntaxError.
+	TagWhitespaceStrict
+)
+
+// tagWhitespace applies r.TagWhitespace to the tag b, outside of attribute
+// values, and returns the tag. A rewritten tag is held in r.tagScratch,
+// with b kept as r.raw.
+func (r *Reader) tagWhitespace(b []byte) ([]byte, error) {
+	out := b
+	copied := false
+	var quote byte
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		n := 0
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '\f' || c == '\v':
+			n = 1
+		case c == 0xC2 && i+1 < len(b) && b[i+1] == 0xA0:
+			n = 2
+		}
+		if n == 0 {
+			continue
+		}
+		if r.TagWhitespace == TagWhitespaceStrict {
+			return b, r.eventError(b, "invalid whitespace %q in tag at offset %d", b[i:i+n], i)
+		}
+		if !copied {
+			r.tagScratch = append(r.tagScratch[:0], b...)
+			out = r.tagScratch
+			copied = true
+			if r.raw == nil {
+				r.raw = b
+			}
+		}
+		for j := i; j < i+n; j++ {
+			out[j] = ' '
+		}
+		i += n - 1
+	}
+	return out, nil
+}
+
+// checkDocument checks the place of ev in the document for
+// CheckWellFormed, with r.open holding the elements open before ev.
+func (r *Reader) checkDocument(ev Event) error {
+	top := r.open.depth() == 0
+	switch ev.Type() {
+	case EventStart:
+		if top && r.rootSeen {
+			name, _ := Name(ev.Bytes)
+			return r.eventError(ev.Bytes, "second root element <%s>", name)
+		}
+		r.rootSeen = true
+	case EventText:
+		t := ev.Bytes
+		if r.seq == 0 {
+			t = bytes.TrimPrefix(t, utf8BOM)
+		}
+		if top && len(trimSpace(t)) > 0 {
+			return r.eventError(ev.Bytes, "text outside the root element")
+		}
+	case EventCData, EventEntityRef:
+		if top {
+			return r.eventError(ev.Bytes, "character data outside the root element")
+		}
+	case EventDocType:
+		if r.rootSeen {
+			return r.eventError(ev.Bytes, "DOCTYPE after the root element")
+		}
+	case EventProcessingInstruction:
+		if _, ok := parseXMLDecl(ev.Bytes); ok && r.seq > 0 && !(r.seq == 1 && r.decl.bom) {
+			return r.eventError(ev.Bytes, "XML declaration not at the start of the document")
+		}
+	case EventEOF:
+		if !top {
+			return r.eventError(ev.Bytes, "unclosed start tag <%s>", r.open.top())
+		}
+		if !r.rootSeen {
+			return r.eventError(ev.Bytes, "no root element")
+		}
+	}
+	return nil
+}
+
+// inElementContent reports whether the innermost open element matches
+// one of the patterns of ElementContent.
+func (r *Reader) inElementContent() bool {
+	r.open.settle()
+	path := r.open.bytes()
+	if len(path) == 0 {
+		return false
+	}
+	for _, pattern := range r.ElementContent {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventError returns a SyntaxError for the event b just read.
+func (r *Reader) eventError(b []byte, format string, args ...any) error {
+	return syntaxError(r.reader.inputOffset()-int64(r.lastLen), b, format, args...)
+}
+
+// postprocess applies the options that transform or observe events. It
+// reports false for an event the options drop, which Event replaces with
+// the next one.
+func (r *Reader) postprocess(ev Event, err error) (Event, bool, error) {
+	if err == nil && r.Lenient {
+		var ok bool
+		ev, ok, err = r.lenient(ev)
+		if !ok {
+			return ev, false, err
+		}
+	}
+	if err == nil && ev.Type() == EventText && (r.SkipWhitespaceText || r.TrimText || r.ElementContent != nil && r.inElementContent()) {
+		t := trimSpace(ev.Bytes)
+		if len(t) == 0 {
+			return ev, false, nil
+		}
+		if r.TrimText {
+			// The event now starts after the leading whitespace.
+			r.lastLen -= cap(ev.Bytes) - cap(t)
+			if len(t) != len(ev.Bytes) && r.raw == nil {
+				r.raw = ev.Bytes
+			}
+			ev.Bytes = t
+		}
+	}
+	if err == nil && r.limited {
+		err = r.checkLimits(ev)
+		if r.Spill != nil && !r.EmitEntityRefs && ev.Type() == EventText && isTokenLimit(err) {
+			return r.spillEvent(ev)
+		}
+	}
+	if err == nil && r.CheckChars {
+		err = r.checkChars(ev)
+	}
+	if err == nil && r.CheckCDataEnd && ev.Type() == EventText {
+		if i := bytes.Index(ev.Bytes, []byte("]]>")); i >= 0 {
+			err = syntaxError(r.EventOffset()+int64(i), ev.Bytes, "text contains \"]]>\"")
+		}
+	}
+	if err == nil && r.ExpandEntities {
+		ev, err = r.expandEntities(ev)
+	}
+	if err == nil && r.UnescapeText && ev.Type() == EventText {
+		if r.raw == nil {
+			r.raw = ev.Bytes
+		}
+		r.scratch = append(r.scratch[:0], ev.Bytes...)
+		ev.Bytes, err = Unescape(r.scratch)
+		if err != nil {
+			err = r.eventError(r.raw, "%v", err)
+		}
+		ev.value |= eventUnescaped
+	}
+	if err == nil && r.Normalizer != nil && (ev.Type() == EventText || ev.Type() == EventCData) && !r.Normalizer.IsNormal(ev.Bytes) {
+		if r.raw == nil {
+			r.raw = ev.Bytes
+		}
+		r.normalized = r.Normalizer.Append(r.normalized[:0], ev.Bytes...)
+		ev.Bytes = r.normalized
+	}
+	if err == nil && r.CheckComments && ev.Type() == EventComment {
+		body := trim(ev.Bytes, "<!--", "-->")
+		i := bytes.Index(body, []byte("--"))
+		if i < 0 && bytes.HasSuffix(body, []byte("-")) {
+			// The "-" makes "--->" end the comment.
+			i = len(body) - 1
+		}
+		if i >= 0 {
+			err = syntaxError(r.EventOffset()+int64(len("<!--")+i), ev.Bytes, "comment contains \"--\": %q", ev.Bytes)
+		}
+	}
+	if err == nil && r.TagWhitespace != TagWhitespaceXML && (ev.Type() == EventStart || ev.Type() == EventEnd) {
+		ev.Bytes, err = r.tagWhitespace(ev.Bytes)
+	}
+	if err == nil && (r.CheckEndNames || r.CheckWellFormed || r.ElementContent != nil || r.Lenient) {
+		r.open.settle()
+		if (r.CheckEndNames || r.CheckWellFormed) && ev.Type() == EventEnd && !isSelfClosing(ev.Bytes) {
+			name, _ := Name(ev.Bytes)
+			if open := r.open.top(); string(name) != string(open) {
+				if open == nil {
+					err = r.eventError(ev.Bytes, "unexpected end tag </%s>", name)
+				} else {
+					err = r.eventError(ev.Bytes, "end tag </%s> does not match start tag <%s>", name, open)
+				}
+			}
+		}
+		if err == nil && r.CheckWellFormed {
+			err = r.checkDocument(ev)
+		}
+		r.open.update(ev)
+	}
+	if err == nil && r.ExpandCDATA && ev.Type() == EventCData {
+		if r.raw == nil {
+			r.raw = ev.Bytes
+		}
+		ev.Bytes = trim(ev.Bytes, "<![CDATA[", "]]>")
+		ev.value = uint64(EventText) | eventUnescaped
+	}
+	if err == nil && (r.ApplyDTDDefaults || r.NormalizeDTDAttrs) {
+		switch ev.Type() {
+		case EventDocType:
+			if r.dtd == nil {
+				if r.dtd, err = ParseDTD(ev.Bytes); err == nil {
+					r.dtdSize = dtdSize(r.dtd)
+				}
+			}
+		case EventStart:
+			if r.dtd != nil {
+				var ok bool
+				r.scratch, ok, err = r.dtd.appendStartTag(r.scratch[:0], ev.Bytes, r.ApplyDTDDefaults, r.NormalizeDTDAttrs)
+				if err != nil {
+					err = r.eventError(ev.Bytes, "%v", err)
+				} else if ok {
+					if r.raw == nil {
+						r.raw = ev.Bytes
+					}
+					ev.Bytes = r.scratch
+				}
+			}
+		}
+	}
+	if err == nil && r.TrackNamespaces {
+		err = r.trackNamespaces(ev)
+	}
+	if err == nil && r.OmitNamespaceDecls && (ev.Type() == EventStart || ev.Type() == EventEnd && isSelfClosing(ev.Bytes)) {
+		var ok bool
+		r.omitted, ok, err = appendWithoutNamespaceDecls(r.omitted[:0], ev.Bytes)
+		if err != nil {
+			err = r.eventError(ev.Bytes, "%v", err)
+		} else if ok {
+			if r.raw == nil {
+				r.raw = ev.Bytes
+			}
+			ev.Bytes = r.omitted
+		}
+	}
+	if err == nil && r.budget > 0 {
+		err = r.checkBudget()
+	}
+	if o := r.reader.observer; o != nil {
+		if err != nil {
+			o.ObserveError(err)
+		} else {
+			o.ObserveEvent(ev)
+		}
+	}
+	return ev, true, err
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// The following functions parse values directly from event or attribute
+// bytes without converting them to a string first.
+// Leading and trailing XML whitespace is ignored, as encoding/xml does.
+
+// ParseInt is like strconv.ParseInt but operates on a byte slice.
+func ParseInt(b []byte, base int, bitSize int) (int64, error) {
+	return strconv.ParseInt(unsafeString(trimSpace(b)), base, bitSize)
+}
+
+// ParseUint is like strconv.ParseUint but operates on a byte slice.
+func ParseUint(b []byte, base int, bitSize int) (uint64, error) {
+	return strconv.ParseUint(unsafeString(trimSpace(b)), base, bitSize)
+}
+
+// ParseFloat is like strconv.ParseFloat but operates on a byte slice.
+func ParseFloat(b []byte, bitSize int) (float64, error) {
+	return strconv.ParseFloat(unsafeString(trimSpace(b)), bitSize)
+}
+
+// ParseBool is like strconv.ParseBool but operates on a byte slice.
+func ParseBool(b []byte) (bool, error) {
+	return strconv.ParseBool(unsafeString(trimSpace(b)))
+}
+
+// ParseTime is like time.Parse but operates on a byte slice.
+func ParseTime(b []byte, layout string) (time.Time, error) {
+	return time.Parse(layout, unsafeString(trimSpace(b)))
+}
+
+// unsafeString returns a string sharing memory with b.
+// It is only passed to functions which copy the string before retaining it.
+func unsafeString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// unsafeBytes returns a byte slice sharing memory with s.
+// It must not be modified.
+func unsafeBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+func trimSpace(b []byte) []byte {
+	for len(b) > 0 && whitespace[b[0]] {
+		b = b[1:]
+	}
+	for len(b) > 0 && whitespace[b[len(b)-1]] {
+		b = b[:len(b)-1]
+	}
+	return b
+}
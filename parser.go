@@ -0,0 +1,119 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "errors"
+
+var (
+	// ErrSuspended is returned by Parse and Resume when a handler stopped
+	// the Parser with Stop(true).
+	ErrSuspended = errors.New("gosax: parser suspended")
+	// ErrAborted is returned by Parse and Resume when a handler stopped
+	// the Parser with Stop(false).
+	ErrAborted = errors.New("gosax: parser aborted")
+)
+
+const (
+	parserReady = iota
+	parserRunning
+	parserSuspending
+	parserSuspended
+	parserAborted
+	parserFinished
+)
+
+// Parser drives an EventReader and pushes each Event to a handler.
+type Parser struct {
+	r       EventReader
+	handler func(Event) error
+	status  int
+}
+
+// NewParser returns a Parser that calls handler for each Event of r,
+// including the final EventEOF.
+func NewParser(r EventReader, handler func(Event) error) *Parser {
+	return &Parser{r: r, handler: handler}
+}
+
+// Parse reads events until EOF, an error, or a call to Stop.
+func (p *Parser) Parse() error {
+	if p.status != parserReady {
+		return errors.New("gosax: Parse called twice")
+	}
+	return p.run()
+}
+
+// Stop stops the Parser after the handler currently running returns.
+// It must be called from within the handler.
+//
+// With resumable, Parse returns ErrSuspended and the Parser can be continued
+// with Resume. The bytes of the last Event stay valid while suspended.
+// Otherwise Parse returns ErrAborted and the Parser cannot be continued.
+func (p *Parser) Stop(resumable bool) {
+	if p.status != parserRunning {
+		return
+	}
+	if resumable {
+		p.status = parserSuspending
+	} else {
+		p.status = parserAborted
+	}
+}
+
+// Resume continues a Parser suspended by Stop(true).
+func (p *Parser) Resume() error {
+	if p.status != parserSuspended {
+		return errors.New("gosax: Resume called on a parser that is not suspended")
+	}
+	return p.run()
+}
+
+func (p *Parser) run() error {
+	p.status = parserRunning
+	for {
+		ev, err := p.r.Event()
+		if err != nil {
+			p.status = parserFinished
+			return err
+		}
+		if err := p.handler(ev); err != nil {
+			p.status = parserFinished
+			return err
+		}
+		switch p.status {
+		case parserSuspending:
+			p.status = parserSuspended
+			return ErrSuspended
+		case parserAborted:
+			return ErrAborted
+		}
+		if ev.Type() == EventEOF {
+			p.status = parserFinished
+			return nil
+		}
+	}
+}
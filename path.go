@@ -0,0 +1,165 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "strings"
+
+// elementPath tracks the names of the open elements of a document.
+type elementPath struct {
+	names []byte
+	ends  []int
+	// selfClosing is set while the innermost element is a self-closing tag
+	// that has not been popped yet.
+	selfClosing bool
+}
+
+func (p *elementPath) push(name []byte) {
+	if len(p.ends) > 0 {
+		p.names = append(p.names, '/')
+	}
+	p.names = append(p.names, name...)
+	p.ends = append(p.ends, len(p.names))
+}
+
+func (p *elementPath) pop() {
+	p.ends = p.ends[:len(p.ends)-1]
+	if len(p.ends) == 0 {
+		p.names = p.names[:0]
+		return
+	}
+	p.names = p.names[:p.ends[len(p.ends)-1]]
+}
+
+func (p *elementPath) reset() {
+	p.names = p.names[:0]
+	p.ends = p.ends[:0]
+	p.selfClosing = false
+}
+
+// depth returns the number of open elements.
+func (p *elementPath) depth() int {
+	return len(p.ends)
+}
+
+// bytes returns the slash-separated path, such as "feed/entry/title".
+func (p *elementPath) bytes() []byte {
+	return p.names
+}
+
+// update applies e to the path. Start tags stay on the path until the
+// following event, so a self-closing tag is popped by the next update
+// whether or not its end is reported.
+func (p *elementPath) update(e Event) {
+	p.settle()
+	switch e.Type() {
+	case EventStart:
+		name, _ := Name(e.Bytes)
+		p.push(name)
+		p.selfClosing = isSelfClosing(e.Bytes)
+	case EventEnd:
+		if !isSelfClosing(e.Bytes) && p.depth() > 0 {
+			p.pop()
+		}
+	}
+}
+
+// settle pops a self-closing tag left on the path by update.
+func (p *elementPath) settle() {
+	if p.selfClosing {
+		p.pop()
+		p.selfClosing = false
+	}
+}
+
+// matchPath reports whether the slash-separated path matches pattern,
+// where a "*" step matches any single element name.
+func matchPath(pattern string, path []byte) bool {
+	for {
+		pi := strings.IndexByte(pattern, '/')
+		step := pattern
+		if pi >= 0 {
+			step = pattern[:pi]
+		}
+		i := 0
+		for i < len(path) && path[i] != '/' {
+			i++
+		}
+		if step != "*" && step != string(path[:i]) {
+			return false
+		}
+		if pi < 0 || i == len(path) {
+			return pi < 0 && i == len(path)
+		}
+		pattern = pattern[pi+1:]
+		path = path[i+1:]
+	}
+}
+
+// matchPathPrefix reports whether path matches the leading steps of
+// pattern, so that pattern may match one of its descendants.
+func matchPathPrefix(pattern string, path []byte) bool {
+	for {
+		pi := strings.IndexByte(pattern, '/')
+		if pi < 0 {
+			return false
+		}
+		i := 0
+		for i < len(path) && path[i] != '/' {
+			i++
+		}
+		if step := pattern[:pi]; step != "*" && step != string(path[:i]) {
+			return false
+		}
+		if i == len(path) {
+			return true
+		}
+		pattern = pattern[pi+1:]
+		path = path[i+1:]
+	}
+}
+
+// top returns the name of the innermost open element, or nil.
+func (p *elementPath) top() []byte {
+	n := len(p.ends)
+	if n == 0 {
+		return nil
+	}
+	begin := 0
+	if n > 1 {
+		begin = p.ends[n-2] + 1
+	}
+	return p.names[begin:p.ends[n-1]]
+}
+
+// at returns the name of the i-th open element, the root being 0.
+func (p *elementPath) at(i int) []byte {
+	begin := 0
+	if i > 0 {
+		begin = p.ends[i-1] + 1
+	}
+	return p.names[begin:p.ends[i]]
+}
@@ -0,0 +1,116 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+)
+
+// A DecoderPlan decodes values of type T with the struct tag analysis done
+// in advance by CompileDecoder. It is safe for concurrent use, and reuses
+// its Readers and buffers between calls.
+type DecoderPlan[T any] struct {
+	types map[reflect.Type]*typeInfo
+	pool  sync.Pool // *planState
+}
+
+type planState struct {
+	r  *Reader
+	br bytes.Reader
+	d  decodeState
+}
+
+// CompileDecoder analyzes T and the types it contains, and returns a plan
+// to decode them. Invalid struct tags are reported here rather than when
+// decoding.
+func CompileDecoder[T any]() (*DecoderPlan[T], error) {
+	p := &DecoderPlan[T]{types: make(map[reflect.Type]*typeInfo)}
+	if err := p.compile(reflect.TypeFor[T]()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *DecoderPlan[T]) compile(typ reflect.Type) error {
+	for {
+		if typ.Implements(unmarshalerType) || typ.Implements(textUnmarshalerType) {
+			return nil
+		}
+		if pt := reflect.PointerTo(typ); pt.Implements(unmarshalerType) || pt.Implements(textUnmarshalerType) {
+			return nil
+		}
+		switch typ.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			typ = typ.Elem()
+			continue
+		case reflect.Struct:
+		default:
+			return nil
+		}
+		break
+	}
+	if _, ok := p.types[typ]; ok || typ == nameType || typ == attrType {
+		return nil
+	}
+	tinfo, err := getTypeInfo(typ)
+	if err != nil {
+		return err
+	}
+	p.types[typ] = tinfo
+	for i := range tinfo.fields {
+		if err := p.compile(typ.FieldByIndex(tinfo.fields[i].idx).Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unmarshal is like the Unmarshal function, decoding data into v.
+func (p *DecoderPlan[T]) Unmarshal(data []byte, v *T) error {
+	s, _ := p.pool.Get().(*planState)
+	if s == nil {
+		s = &planState{r: NewReaderSize(nil, 64*1024)}
+		s.d.types = p.types
+	}
+	s.br.Reset(data)
+	s.r.Reset(&s.br)
+	s.d.reset(s.r)
+	err := s.d.decode(reflect.ValueOf(v).Elem())
+	s.br.Reset(nil)
+	s.r.Reset(nil)
+	s.d.reset(nil)
+	p.pool.Put(s)
+	return err
+}
+
+// Decode decodes the next element read from r into v, like Decoder.Decode.
+func (p *DecoderPlan[T]) Decode(r *Reader, v *T) error {
+	d := decodeState{r: r, types: p.types}
+	return d.decode(reflect.ValueOf(v).Elem())
+}
@@ -0,0 +1,136 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"io"
+	"runtime"
+	"sync"
+)
+
+// ProcessSubtrees reads r to EOF and calls fn for the raw bytes of each
+// element whose path matches pattern, such as "feed/entry" or "*/item".
+// Calls to fn run concurrently on up to workers goroutines (GOMAXPROCS if
+// workers <= 0); each receives its own copy of the subtree. The results
+// are passed to emit in input order, from a single goroutine.
+//
+// Matching elements are not searched for nested matches. The first error
+// returned by r, fn or emit stops processing and is returned.
+func ProcessSubtrees[T any](r *Reader, pattern string, workers int, fn func(subtree []byte) (T, error), emit func(T) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	type result struct {
+		v   T
+		err error
+	}
+	type job struct {
+		subtree []byte
+		result  chan result
+	}
+	jobs := make(chan job)
+	// queue holds the result channels in input order and bounds the number
+	// of subtrees in flight.
+	queue := make(chan chan result, 2*workers)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				v, err := fn(j.subtree)
+				j.result <- result{v, err}
+			}
+		}()
+	}
+
+	var emitErr error
+	emitted := make(chan struct{})
+	go func() {
+		defer close(emitted)
+		for ch := range queue {
+			res := <-ch
+			if emitErr != nil {
+				continue
+			}
+			if res.err == nil {
+				res.err = emit(res.v)
+			}
+			if res.err != nil {
+				emitErr = res.err
+				close(stop)
+			}
+		}
+	}()
+
+	readErr := func() error {
+		var path elementPath
+		for {
+			e, err := r.Event()
+			if err != nil {
+				return err
+			}
+			path.update(e)
+			switch e.Type() {
+			case EventEOF:
+				return nil
+			case EventStart:
+				if !matchPath(pattern, path.bytes()) {
+					continue
+				}
+				sr, err := r.SubtreeReader()
+				if err != nil {
+					return err
+				}
+				subtree, err := io.ReadAll(sr)
+				if err != nil {
+					return err
+				}
+				if !isSelfClosing(e.Bytes) {
+					path.pop()
+				}
+				ch := make(chan result, 1)
+				select {
+				case queue <- ch:
+				case <-stop:
+					return nil
+				}
+				jobs <- job{subtree, ch}
+			}
+		}
+	}()
+	close(jobs)
+	close(queue)
+	wg.Wait()
+	<-emitted
+	if readErr != nil {
+		return readErr
+	}
+	return emitErr
+}
@@ -0,0 +1,182 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"strings"
+)
+
+// A Profile summarizes the structure of a corpus of documents: the element
+// and attribute paths used, how often, and how large their content is.
+type Profile struct {
+	Documents int   `json:"documents"`
+	Bytes     int64 `json:"bytes"`
+	// Encodings counts the encodings declared by the documents. Documents
+	// without an encoding declaration are counted as UTF-8.
+	Encodings map[string]int `json:"encodings"`
+	// Paths maps the slash-separated paths of elements, such as
+	// "feed/entry", and of attributes, such as "feed/entry/@id", to their
+	// statistics.
+	Paths map[string]*PathStats `json:"paths"`
+	// Types maps the paths of elements and attributes to the statistics of
+	// their values, collected when InferTypes is set.
+	Types map[string]*TypeStats `json:"types,omitempty"`
+
+	// InferTypes makes Add collect the statistics of Types.
+	InferTypes bool `json:"-"`
+}
+
+// PathStats describes the elements or attributes at a path.
+type PathStats struct {
+	Count int64 `json:"count"`
+	// Bytes and MaxBytes are the total and largest size of the elements,
+	// tags included, or of the raw attribute values.
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+	// TextBytes is the size of the character data directly inside the
+	// elements.
+	TextBytes int64 `json:"text_bytes,omitempty"`
+}
+
+// NewProfile returns an empty Profile.
+func NewProfile() *Profile {
+	return &Profile{
+		Encodings: make(map[string]int),
+		Paths:     make(map[string]*PathStats),
+	}
+}
+
+// Add reads r to EOF and adds its document to p. On error, p keeps the
+// statistics of the part of the document read.
+func (p *Profile) Add(r *Reader) error {
+	var path elementPath
+	var open []*PathStats
+	var starts []int64
+	// frames holds the values of the open elements, with InferTypes.
+	var frames []valueFrame
+	defer func() {
+		encoding := strings.ToUpper(r.Encoding())
+		if encoding == "" {
+			encoding = "UTF-8"
+		}
+		p.Documents++
+		p.Bytes += r.InputOffset()
+		p.Encodings[encoding]++
+	}()
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return err
+		}
+		path.settle()
+		switch e.Type() {
+		case EventStart:
+			path.update(e)
+			s := p.stats(path.bytes())
+			if p.InferTypes {
+				if n := len(frames); n > 0 {
+					frames[n-1].child(path.top())
+				} else {
+					p.types(path.bytes()).MaxOccurs = 1
+				}
+			}
+			if isSelfClosing(e.Bytes) {
+				s.add(int64(len(e.Bytes)))
+				if p.InferTypes {
+					p.types(path.bytes()).add(nil)
+				}
+			} else {
+				open = append(open, s)
+				starts = append(starts, r.EventOffset())
+				if p.InferTypes {
+					frames = append(frames, valueFrame{})
+				}
+			}
+			_, attrs := Name(e.Bytes)
+			for len(attrs) > 0 {
+				attr, rest, err := NextAttribute(attrs)
+				if err != nil {
+					return err
+				}
+				if len(attr.Key) == 0 {
+					break
+				}
+				attrs = rest
+				n := len(path.bytes())
+				key := append(append(path.bytes(), "/@"...), attr.Key...)
+				p.stats(key).add(int64(max(len(attr.Value)-2, 0)))
+				if p.InferTypes && len(attr.Value) >= 2 {
+					v, err := UnescapeAttr(bytes.Clone(attr.Value[1 : len(attr.Value)-1]))
+					if err != nil {
+						return err
+					}
+					p.types(key).add(v)
+				}
+				path.names = path.names[:n]
+			}
+		case EventEnd:
+			if isSelfClosing(e.Bytes) || len(open) == 0 {
+				continue
+			}
+			n := len(open) - 1
+			open[n].add(r.InputOffset() - starts[n])
+			open, starts = open[:n], starts[:n]
+			if p.InferTypes {
+				p.endFrame(path.bytes(), &frames[len(frames)-1])
+				frames = frames[:len(frames)-1]
+			}
+			path.update(e)
+		case EventText, EventCData, EventEntityRef:
+			if n := len(open); n > 0 {
+				open[n-1].TextBytes += int64(len(e.Bytes))
+			}
+			if n := len(frames); n > 0 {
+				if err := frames[n-1].text(e); err != nil {
+					return err
+				}
+			}
+		case EventEOF:
+			return nil
+		}
+	}
+}
+
+func (p *Profile) stats(path []byte) *PathStats {
+	s, ok := p.Paths[string(path)]
+	if !ok {
+		s = &PathStats{}
+		p.Paths[string(path)] = s
+	}
+	return s
+}
+
+func (s *PathStats) add(n int64) {
+	s.Count++
+	s.Bytes += n
+	s.MaxBytes = max(s.MaxBytes, n)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "io"
+
+// Projection is an EventReader that reports only the elements matching a set
+// of paths, with their content, and the start and end tags of their
+// ancestors. Other elements are skipped by the tokenizer: their content is
+// not postprocessed and not reported to the Observer.
+//
+// Paths are slash-separated from the document element, such as
+// "feed/entry/title", where a "*" step matches any name.
+type Projection struct {
+	r       *Reader
+	paths   []string
+	path    elementPath
+	matched int
+}
+
+// NewProjection returns a Projection of r to paths.
+func NewProjection(r *Reader, paths ...string) *Projection {
+	return &Projection{r: r, paths: paths}
+}
+
+// Event returns the next event of the projection.
+func (p *Projection) Event() (Event, error) {
+	for {
+		ev, err := p.r.Event()
+		if err != nil {
+			return ev, err
+		}
+		switch ev.Type() {
+		case EventStart:
+			p.path.update(ev)
+			if p.matched > 0 {
+				return ev, nil
+			}
+			if p.match() {
+				if !isSelfClosing(ev.Bytes) {
+					p.matched = p.path.depth()
+				}
+				return ev, nil
+			}
+			if p.ancestor() {
+				return ev, nil
+			}
+			if err := p.r.skipElement(); err != nil {
+				return Event{}, err
+			}
+			if !isSelfClosing(ev.Bytes) {
+				p.path.pop()
+			}
+		case EventEnd:
+			p.path.settle()
+			if p.matched > 0 && !isSelfClosing(ev.Bytes) && p.path.depth() == p.matched {
+				p.matched = 0
+			}
+			p.path.update(ev)
+			return ev, nil
+		case EventEOF:
+			return ev, nil
+		default:
+			if p.matched > 0 {
+				return ev, nil
+			}
+		}
+	}
+}
+
+func (p *Projection) match() bool {
+	for _, pattern := range p.paths {
+		if matchPath(pattern, p.path.bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Projection) ancestor() bool {
+	for _, pattern := range p.paths {
+		if matchPathPrefix(pattern, p.path.bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipElement skips the content of the element started by the last event.
+// Only its end tag is postprocessed, which keeps options that track open
+// elements consistent.
+func (r *Reader) skipElement() error {
+	if isSelfClosing(r.last.Bytes) {
+		if r.EmitSelfClosingTag {
+			_, err := r.Event()
+			return err
+		}
+		return nil
+	}
+	return r.skipToEnd()
+}
+
+// skipToEnd skips to the end tag of the innermost open element, reading
+// the events in between without postprocessing.
+func (r *Reader) skipToEnd() error {
+	depth := 0
+	for {
+		ev, err := r.state(r)
+		if err != nil {
+			return err
+		}
+		switch ev.Type() {
+		case EventStart:
+			if !isSelfClosing(ev.Bytes) {
+				depth++
+			}
+		case EventEnd:
+			if isSelfClosing(ev.Bytes) {
+				continue
+			}
+			if depth > 0 {
+				depth--
+				continue
+			}
+			r.lastLen = len(ev.Bytes)
+			if r.slow {
+				var ok bool
+				ev, ok, err = r.postprocess(ev, nil)
+				if !ok && err == nil {
+					continue
+				}
+			}
+			if err == nil {
+				r.updateDepth(ev)
+			}
+			r.last = ev
+			return err
+		case EventEOF:
+			return io.ErrUnexpectedEOF
+		}
+	}
+}
@@ -28,7 +28,10 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 
 package gosax
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // A byteReader implements a sliding window over an io.Reader.
 type byteReader struct {
@@ -36,6 +39,16 @@ type byteReader struct {
 	offset int
 	r      io.Reader
 	err    error
+	// base is the input offset of data[0].
+	base int64
+	// alloc, if set, allocates data and takes it back when it grows.
+	alloc Allocator
+	// deadline, if set, is the time by which the current event must be read.
+	deadline time.Time
+	// maxToken, if positive, bounds the size of the window.
+	maxToken int
+
+	observer Observer
 }
 
 // release discards n bytes from the front of the window.
@@ -43,6 +56,11 @@ func (b *byteReader) release(n int) {
 	b.offset += n
 }
 
+// inputOffset returns the input offset of the front of the window.
+func (b *byteReader) inputOffset() int64 {
+	return b.base + int64(b.offset)
+}
+
 // window returns the current window.
 // The window is invalidated by calls to release or extend.
 func (b *byteReader) window() []byte {
@@ -53,6 +71,8 @@ func (b *byteReader) window() []byte {
 const (
 	newBufferSize = 4096
 	minReadSize   = newBufferSize >> 2
+
+	maxConsecutiveEmptyReads = 100
 )
 
 // extend extends the window with data from the underlying reader.
@@ -61,8 +81,21 @@ func (b *byteReader) extend() int {
 		return 0
 	}
 
+	if !b.deadline.IsZero() {
+		if err := b.checkDeadline(); err != nil {
+			b.err = err
+			return 0
+		}
+	}
+
 	remaining := len(b.data) - b.offset
+	if b.maxToken > 0 && remaining >= b.maxToken {
+		// The window holds the unfinished event.
+		b.err = &LimitError{Limit: "MaxTokenSize", Max: int64(b.maxToken), Offset: b.inputOffset()}
+		return 0
+	}
 	if remaining == 0 {
+		b.base += int64(b.offset)
 		b.data = b.data[:0]
 		b.offset = 0
 	}
@@ -76,23 +109,48 @@ func (b *byteReader) extend() int {
 		b.grow()
 	}
 	remaining += b.offset
-	n, err := b.r.Read(b.data[remaining:cap(b.data)])
+	var n int
+	var err error
+	// Some readers return (0, nil) now and then; retry a bounded number
+	// of times like bufio, rather than treating it as the end of input.
+	for i := 0; n == 0 && err == nil; i++ {
+		if i == maxConsecutiveEmptyReads {
+			err = io.ErrNoProgress
+			break
+		}
+		n, err = b.r.Read(b.data[remaining:cap(b.data)])
+	}
 	// reduce length to the existing plus the data we read.
 	b.data = b.data[:remaining+n]
 	b.err = err
+	if b.observer != nil {
+		b.observer.ObserveRefill(n)
+	}
 	return n
 }
 
 // grow grows the buffer, moving the active data to the front.
 func (b *byteReader) grow() {
-	buf := make([]byte, max(cap(b.data)*2, newBufferSize))
+	size := max(cap(b.data)*2, newBufferSize)
+	var buf []byte
+	if b.alloc != nil {
+		buf = b.alloc.Alloc(size)
+		buf = buf[:cap(buf)]
+	} else {
+		buf = make([]byte, size)
+	}
 	copy(buf, b.data[b.offset:])
+	if b.alloc != nil && b.data != nil {
+		b.alloc.Free(b.data[:0])
+	}
 	b.data = buf
+	b.base += int64(b.offset)
 	b.offset = 0
 }
 
 // compact moves the active data to the front of the buffer.
 func (b *byteReader) compact() {
 	copy(b.data, b.data[b.offset:])
+	b.base += int64(b.offset)
 	b.offset = 0
 }
@@ -0,0 +1,141 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "bytes"
+
+// trackNamespaces updates the namespace scope of TrackNamespaces with ev.
+// The frame of an element is popped at the event following its end, so
+// that the end tag can be resolved too.
+func (r *Reader) trackNamespaces(ev Event) error {
+	if ev.Type() == EventEnd && isSelfClosing(ev.Bytes) {
+		// EmitSelfClosingTag repeats the start tag, whose frame is in scope.
+		return nil
+	}
+	if r.nsSettle {
+		r.ns.pop()
+		r.nsSettle = false
+	}
+	switch ev.Type() {
+	case EventStart:
+		r.ns.push()
+		r.nsSettle = isSelfClosing(ev.Bytes)
+		_, attrs := Name(ev.Bytes)
+		if !bytes.Contains(attrs, []byte("xmlns")) {
+			return nil
+		}
+		for len(attrs) > 0 {
+			attr, rest, err := NextAttribute(attrs)
+			if err != nil {
+				return r.eventError(ev.Bytes, "%v", err)
+			}
+			if len(attr.Key) == 0 {
+				break
+			}
+			attrs = rest
+			prefix, ok := nsDecl(attr.Key)
+			if !ok || len(attr.Value) < 2 {
+				continue
+			}
+			if name, max := r.ns.checkLimits(prefix); name != "" {
+				return &LimitError{Limit: name, Max: int64(max), Offset: r.EventOffset()}
+			}
+			uri, err := UnescapeAttr(bytes.Clone(attr.Value[1 : len(attr.Value)-1]))
+			if err != nil {
+				return r.eventError(ev.Bytes, "%v", err)
+			}
+			r.ns.declare(prefix, string(uri))
+		}
+	case EventEnd:
+		r.nsSettle = len(r.ns.marks) > 0
+	}
+	return nil
+}
+
+// ResolveName returns the namespace URI and local part of the element name
+// qname, such as the name of the last start or end tag, using the bindings
+// in scope at the last event. An unprefixed name is in the default
+// namespace. If the prefix is not bound, space is the prefix itself, as
+// encoding/xml does. It requires TrackNamespaces; space must not be
+// modified.
+func (r *Reader) ResolveName(qname []byte) (space, local []byte) {
+	return r.resolve(qname, true)
+}
+
+// ResolveAttrName is like ResolveName for attribute names, which are in no
+// namespace when unprefixed.
+func (r *Reader) ResolveAttrName(qname []byte) (space, local []byte) {
+	return r.resolve(qname, false)
+}
+
+func (r *Reader) resolve(qname []byte, element bool) ([]byte, []byte) {
+	prefix, local := splitQName(qname)
+	if prefix == nil && !element {
+		if string(local) == "xmlns" {
+			return unsafeBytes(xmlnsURL), local
+		}
+		return nil, local
+	}
+	uri, ok := r.ns.lookup(string(prefix))
+	if !ok {
+		return prefix, local
+	}
+	return unsafeBytes(uri), local
+}
+
+// appendWithoutNamespaceDecls appends the start tag b to dst without its
+// namespace declarations. It reports whether b had any.
+func appendWithoutNamespaceDecls(dst, b []byte) ([]byte, bool, error) {
+	_, attrs := Name(b)
+	if !bytes.Contains(attrs, []byte("xmlns")) {
+		return dst, false, nil
+	}
+	last := 0
+	for len(attrs) > 0 {
+		attr, rest, err := NextAttribute(attrs)
+		if err != nil {
+			return dst, false, err
+		}
+		if len(attr.Key) == 0 {
+			break
+		}
+		if _, ok := nsDecl(attr.Key); ok {
+			// Drop the attribute with the whitespace before it.
+			i := cap(b) - cap(attrs)
+			for i > 0 && whitespace[b[i-1]] {
+				i--
+			}
+			dst = append(dst, b[last:i]...)
+			last = cap(b) - cap(rest)
+		}
+		attrs = rest
+	}
+	if last == 0 {
+		return dst, false, nil
+	}
+	return append(dst, b[last:]...), true, nil
+}
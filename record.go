@@ -0,0 +1,156 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The event cache format is the magic "GSXE", a version byte, then one
+// record per event: the event value and the length of its bytes as
+// uvarints, followed by the bytes. The stream ends with the EventEOF record.
+const (
+	recordMagic   = "GSXE"
+	recordVersion = 1
+)
+
+// RecordEvents writes the events of r up to and including EventEOF to w
+// in a compact binary format that ReplayEvents reads back.
+func RecordEvents(w io.Writer, r EventReader) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(recordMagic)
+	bw.WriteByte(recordVersion)
+	for {
+		e, err := r.Event()
+		if err != nil {
+			return err
+		}
+		if err := writeRecord(bw, e); err != nil {
+			return err
+		}
+		if e.Type() == EventEOF {
+			return bw.Flush()
+		}
+	}
+}
+
+// writeRecord writes the record of e.
+func writeRecord(bw *bufio.Writer, e Event) error {
+	var hdr [2 * binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(hdr[:], uint64(e.Type()))
+	n += binary.PutUvarint(hdr[n:], uint64(len(e.Bytes)))
+	bw.Write(hdr[:n])
+	_, err := bw.Write(e.Bytes)
+	return err
+}
+
+// ReplayEvents returns an EventReader that replays events recorded by
+// RecordEvents. As with Reader, an Event is only valid until the next call.
+func ReplayEvents(r io.Reader) EventReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReaderSize(r, 64*1024)
+	}
+	return &replayer{r: br}
+}
+
+type replayer struct {
+	r      *bufio.Reader
+	buf    []byte
+	header bool
+	done   bool
+	err    error
+}
+
+func (p *replayer) Event() (Event, error) {
+	if p.err != nil {
+		return Event{}, p.err
+	}
+	if p.done {
+		return Event{value: uint64(EventEOF)}, nil
+	}
+	e, err := p.next()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		p.err = err
+		return Event{}, err
+	}
+	p.done = e.Type() == EventEOF
+	return e, nil
+}
+
+func (p *replayer) next() (Event, error) {
+	if !p.header {
+		var hdr [len(recordMagic) + 1]byte
+		if _, err := io.ReadFull(p.r, hdr[:]); err != nil {
+			return Event{}, err
+		}
+		if string(hdr[:len(recordMagic)]) != recordMagic {
+			return Event{}, errors.New("gosax: not an event recording")
+		}
+		if hdr[len(recordMagic)] != recordVersion {
+			return Event{}, fmt.Errorf("gosax: unsupported event recording version %d", hdr[len(recordMagic)])
+		}
+		p.header = true
+	}
+	value, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return Event{}, err
+	}
+	n, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return Event{}, err
+	}
+	if n > uint64(cap(p.buf)) && n > 1<<20 {
+		// Grow while reading so a corrupt length cannot allocate at once.
+		buf := bytes.NewBuffer(p.buf[:0])
+		m, err := buf.ReadFrom(io.LimitReader(p.r, int64(min(n, 1<<62))))
+		if err != nil {
+			return Event{}, err
+		}
+		if uint64(m) != n {
+			return Event{}, io.ErrUnexpectedEOF
+		}
+		p.buf = buf.Bytes()
+	} else {
+		if uint64(cap(p.buf)) < n {
+			p.buf = make([]byte, n)
+		}
+		p.buf = p.buf[:n]
+		if _, err := io.ReadFull(p.r, p.buf); err != nil {
+			return Event{}, err
+		}
+	}
+	return Event{Bytes: p.buf, value: uint64(value)}, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Recorder is an EventReader that passes the events of another
+// EventReader through while keeping a copy of each one, for snapshot tests
+// and for inspecting a parse after the fact.
+type Recorder struct {
+	// Max, if positive, makes the Recorder keep only the last Max events,
+	// bounding its memory when it runs in production.
+	Max int
+
+	r      EventReader
+	events []Event
+	// next is the index in events of the oldest event once Max is reached.
+	next int
+	err  error
+}
+
+// NewRecorder returns a Recorder reading from r.
+func NewRecorder(r EventReader) *Recorder {
+	return &Recorder{r: r}
+}
+
+// Event returns the next event of the underlying EventReader and records
+// it. Errors are recorded too, and reported by Err.
+func (rec *Recorder) Event() (Event, error) {
+	e, err := rec.r.Event()
+	if err != nil {
+		rec.err = err
+		return e, err
+	}
+	if rec.Max <= 0 || len(rec.events) < rec.Max {
+		c := e
+		c.Bytes = append([]byte(nil), e.Bytes...)
+		rec.events = append(rec.events, c)
+	} else {
+		// Reuse the storage of the oldest event.
+		c := &rec.events[rec.next]
+		b := append(c.Bytes[:0], e.Bytes...)
+		*c = e
+		c.Bytes = b
+		rec.next = (rec.next + 1) % len(rec.events)
+	}
+	return e, nil
+}
+
+// Events returns the recorded events in order. They remain valid until
+// the Recorder records past Max events or is reset.
+func (rec *Recorder) Events() []Event {
+	if rec.next == 0 {
+		return rec.events
+	}
+	return append(rec.events[rec.next:len(rec.events):len(rec.events)], rec.events[:rec.next]...)
+}
+
+// Err returns the error that ended the recording, if any.
+func (rec *Recorder) Err() error {
+	return rec.err
+}
+
+// Reset discards the recorded events and the error.
+func (rec *Recorder) Reset() {
+	rec.events = rec.events[:0]
+	rec.next = 0
+	rec.err = nil
+}
+
+// WriteTo writes the recorded events to w in the format of RecordEvents, so
+// they can be replayed with ReplayEvents. A recording that did not reach
+// EventEOF replays with io.ErrUnexpectedEOF at its end.
+func (rec *Recorder) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	bw.WriteString(recordMagic)
+	bw.WriteByte(recordVersion)
+	for _, e := range rec.Events() {
+		if err := writeRecord(bw, e); err != nil {
+			return cw.n, err
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// String returns the recorded events one per line, as their type followed
+// by their bytes quoted, ending with the error if any. It suits golden
+// files.
+func (rec *Recorder) String() string {
+	var sb strings.Builder
+	for _, e := range rec.Events() {
+		fmt.Fprintf(&sb, "%s %q\n", e.Type(), e.Bytes)
+	}
+	if rec.err != nil {
+		fmt.Fprintf(&sb, "error %q\n", rec.err)
+	}
+	return sb.String()
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// RepairTruncated copies the XML document r to w up to the point where it
+// is cut off or stops being readable, then writes the end tags that close
+// the elements left open, so that w receives a well-formed prefix of r. It
+// returns the number of end tags it added.
+//
+// The incomplete token at the point of the cut is dropped, as is an
+// incomplete reference or UTF-8 sequence at the end of trailing text. Only
+// errors reading r or writing w are returned.
+func RepairTruncated(w io.Writer, r io.Reader) (int, error) {
+	xr := NewReader(r)
+	bw := bufio.NewWriter(w)
+	var open elementPath
+	for {
+		ev, err := xr.Event()
+		if err != nil {
+			if rerr := xr.reader.err; rerr != nil && rerr != io.EOF {
+				return 0, rerr
+			}
+			break
+		}
+		if ev.Type() == EventEOF {
+			break
+		}
+		b := ev.Bytes
+		if ev.Type() == EventText && xr.reader.err == io.EOF && xr.Buffered() == 0 {
+			b = trimIncompleteText(b)
+		}
+		if _, err := bw.Write(b); err != nil {
+			return 0, err
+		}
+		open.update(ev)
+	}
+	open.settle()
+	n := 0
+	for ; open.depth() > 0; n++ {
+		bw.WriteString("</")
+		bw.Write(open.top())
+		bw.WriteByte('>')
+		open.pop()
+	}
+	return n, bw.Flush()
+}
+
+// trimIncompleteText drops a reference or UTF-8 sequence cut off at the end
+// of b.
+func trimIncompleteText(b []byte) []byte {
+	if i := bytes.LastIndexByte(b, '&'); i >= 0 && bytes.IndexByte(b[i:], ';') < 0 {
+		b = b[:i]
+	}
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				b = b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"io"
+)
+
+// Resync discards input up to the next plausible markup boundary, a '<'
+// followed by a name start character, '/', '!' or '?', and resumes reading
+// text there. It returns the number of bytes discarded.
+//
+// Resync is meant for salvaging damaged documents after Event returns a
+// syntax error: the byte at which the failing event started is always
+// discarded, so repeated calls make progress. Options that track open
+// elements are not adjusted.
+func (r *Reader) Resync() (int64, error) {
+	rr := &r.reader
+	start := rr.inputOffset()
+	if len(rr.window()) > 0 || rr.extend() > 0 {
+		rr.release(1)
+	}
+	for {
+		w := rr.window()
+		i := bytes.IndexByte(w, '<')
+		if i < 0 {
+			rr.release(len(w))
+			if rr.extend() == 0 {
+				break
+			}
+			continue
+		}
+		rr.release(i)
+		if len(rr.window()) < 2 && rr.extend() == 0 {
+			break
+		}
+		if isMarkupStart(rr.window()[1]) {
+			break
+		}
+		rr.release(1)
+	}
+	r.state = (*Reader).stateInsideText
+	r.selfClosingLen = 0
+	r.last = Event{}
+	r.lastLen = 0
+	n := rr.inputOffset() - start
+	if rr.err != nil && rr.err != io.EOF {
+		return n, rr.err
+	}
+	return n, nil
+}
+
+func isMarkupStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || c >= 0x80 ||
+		c == '/' || c == '!' || c == '?'
+}
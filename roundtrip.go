@@ -0,0 +1,213 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// RoundTrip checks that documents survive being parsed, written with a
+// Writer and parsed again, as a safety net for transform pipelines.
+type RoundTrip struct {
+	// Transform writes the events of r to w. If nil, the events are copied
+	// with CopyEvents.
+	Transform func(r *Reader, w *Writer) error
+
+	// Equivalent compares the event streams under XML semantics: start tags
+	// as by EquivalentStart, adjacent text, references and CDATA sections
+	// as one unescaped text, and "<a/>" as "<a></a>". Otherwise events are
+	// compared as written.
+	Equivalent bool
+	// IgnoreComments skips comments in both streams.
+	IgnoreComments bool
+	// IgnoreWhitespace skips text consisting only of whitespace.
+	IgnoreWhitespace bool
+}
+
+// A RoundTripError reports the first difference between the events of a
+// document and those of its re-serialization.
+type RoundTripError struct {
+	// Index is the index of the differing event, counting the events that
+	// are compared.
+	Index int
+	// Offset and OutputOffset are the offsets of the differing events in
+	// the input and in the output.
+	Offset, OutputOffset int64
+	// Want and Got are the differing events as written, or empty at the
+	// end of a stream.
+	Want, Got string
+}
+
+func (e *RoundTripError) Error() string {
+	return fmt.Sprintf("gosax: round trip differs at event %d (offset %d, output offset %d): %q != %q", e.Index, e.Offset, e.OutputOffset, e.Want, e.Got)
+}
+
+// Check writes data with Transform and compares the events of data with
+// those of the output. It returns a *RoundTripError at the first
+// difference, or an error from parsing or Transform.
+func (rt *RoundTrip) Check(data []byte) error {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	r := NewReader(bytes.NewReader(data))
+	var err error
+	if rt.Transform != nil {
+		err = rt.Transform(r, w)
+	} else {
+		err = CopyEvents(w, r)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	want := rt.stream(data)
+	got := rt.stream(out.Bytes())
+	for i := 0; ; i++ {
+		okw, err := want.next()
+		if err != nil {
+			return err
+		}
+		okg, err := got.next()
+		if err != nil {
+			return fmt.Errorf("gosax: round trip output: %w", err)
+		}
+		if okw != okg || !bytes.Equal(want.key, got.key) {
+			return &RoundTripError{
+				Index:        i,
+				Offset:       want.offset,
+				OutputOffset: got.offset,
+				Want:         string(want.raw),
+				Got:          string(got.raw),
+			}
+		}
+		if !okw {
+			return nil
+		}
+	}
+}
+
+func (rt *RoundTrip) stream(data []byte) *roundTripStream {
+	r := NewReader(bytes.NewReader(data))
+	r.EmitSelfClosingTag = rt.Equivalent
+	return &roundTripStream{rt: rt, r: r}
+}
+
+// roundTripStream produces the comparison keys of the events of a document.
+type roundTripStream struct {
+	rt         *RoundTrip
+	r          *Reader
+	scope      nsScope
+	pending    Event
+	pendingOff int64
+	unread     bool
+
+	// key is the comparison key of the current event, raw the event as
+	// written and offset its input offset.
+	key    []byte
+	raw    []byte
+	offset int64
+}
+
+// next sets the key of the next event. It reports false at the end of the
+// document.
+func (s *roundTripStream) next() (bool, error) {
+	s.key = s.key[:0]
+	s.raw = s.raw[:0]
+	text := false
+	for {
+		ev, off, err := s.event()
+		if err != nil {
+			return false, err
+		}
+		t := ev.Type()
+		if t == EventComment && s.rt.IgnoreComments {
+			continue
+		}
+		if s.rt.Equivalent && (t == EventText || t == EventEntityRef || t == EventCData) {
+			if !text {
+				s.key = append(s.key, 'T')
+				s.offset = off
+				text = true
+			}
+			s.raw = append(s.raw, ev.Bytes...)
+			if t == EventCData {
+				s.key = append(s.key, trim(ev.Bytes, "<![CDATA[", "]]>")...)
+				continue
+			}
+			n := len(s.key)
+			s.key = append(s.key, ev.Bytes...)
+			v, err := Unescape(s.key[n:])
+			if err != nil {
+				return false, err
+			}
+			s.key = s.key[:n+len(v)]
+			continue
+		}
+		if text {
+			s.pending, s.pendingOff, s.unread = ev, off, true
+			if s.rt.IgnoreWhitespace && len(trimSpace(s.key[1:])) == 0 {
+				s.key = s.key[:0]
+				s.raw = s.raw[:0]
+				text = false
+				continue
+			}
+			return true, nil
+		}
+		if t == EventText && s.rt.IgnoreWhitespace && len(trimSpace(ev.Bytes)) == 0 {
+			continue
+		}
+		s.offset = off
+		s.raw = append(s.raw, ev.Bytes...)
+		switch {
+		case t == EventEOF:
+			return false, nil
+		case t == EventStart && s.rt.Equivalent:
+			s.key = append(s.key, 'S')
+			s.key, err = appendStartKey(s.key, &s.scope, ev.Bytes)
+			return true, err
+		case t == EventEnd && s.rt.Equivalent:
+			s.scope.pop()
+			s.key = append(s.key, 'E')
+			return true, nil
+		}
+		s.key = append(s.key, byte(t))
+		s.key = append(s.key, ev.Bytes...)
+		return true, nil
+	}
+}
+
+func (s *roundTripStream) event() (Event, int64, error) {
+	if s.unread {
+		s.unread = false
+		return s.pending, s.pendingOff, nil
+	}
+	ev, err := s.r.Event()
+	return ev, s.r.EventOffset(), err
+}
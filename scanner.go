@@ -0,0 +1,174 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Scanner is the low-level buffered scanner that Reader is built on.
+// It exposes a sliding window over an io.Reader, so custom tokenizers
+// (e.g. for XML-like dialects) can reuse the same buffering strategy.
+//
+// The typical loop looks for a delimiter in Window, calls Fill when more
+// data is needed and Advance once a token has been consumed.
+type Scanner struct {
+	reader byteReader
+}
+
+// NewScanner returns a new Scanner reading from r.
+// buf is used as the initial buffer and may be nil.
+func NewScanner(r io.Reader, buf []byte) *Scanner {
+	var s Scanner
+	s.reader.data = buf
+	s.Reset(r)
+	return &s
+}
+
+// Reset discards any buffered data and switches the Scanner to read from r.
+// The underlying buffer is retained.
+func (s *Scanner) Reset(r io.Reader) {
+	data := s.reader.data
+	if data != nil {
+		data = data[:0]
+	}
+	s.reader = byteReader{
+		data: data,
+		r:    r,
+	}
+}
+
+// Window returns the unconsumed bytes currently held in the buffer.
+// The window is invalidated by calls to Fill and Advance.
+func (s *Scanner) Window() []byte {
+	return s.reader.window()
+}
+
+// Fill reads more data from the underlying reader into the window.
+// It returns the number of bytes added. When it returns 0, Err reports why.
+func (s *Scanner) Fill() int {
+	return s.reader.extend()
+}
+
+// Advance consumes the first n bytes of the window.
+func (s *Scanner) Advance(n int) {
+	s.reader.release(n)
+}
+
+// Err returns the error encountered by the last Fill, if any.
+func (s *Scanner) Err() error {
+	return s.reader.err
+}
+
+// IndexByte returns the index of the first instance of c in the window,
+// filling the window as needed. It returns -1 if c is not present before
+// the underlying reader is exhausted.
+func (s *Scanner) IndexByte(c byte) int {
+	offset := 0
+	for {
+		w := s.reader.window()
+		if i := bytes.IndexByte(w[offset:], c); i >= 0 {
+			return offset + i
+		}
+		offset = len(w)
+		if s.reader.extend() == 0 {
+			return -1
+		}
+	}
+}
+
+// Index returns the index of the first instance of sep in the window,
+// filling the window as needed. It returns -1 if sep is not present before
+// the underlying reader is exhausted.
+func (s *Scanner) Index(sep []byte) int {
+	offset := 0
+	for {
+		w := s.reader.window()
+		if i := bytes.Index(w[offset:], sep); i >= 0 {
+			return offset + i
+		}
+		offset = max(len(w)-len(sep)+1, 0)
+		if s.reader.extend() == 0 {
+			return -1
+		}
+	}
+}
+
+const splat uint64 = 0x0101010101010101
+
+// IndexByte2 returns the index of the first instance of a or b in s, or -1.
+// It scans eight bytes at a time using SWAR.
+func IndexByte2(s []byte, a, b byte) int {
+	v1 := uint64(a) * splat
+	v2 := uint64(b) * splat
+	offset := 0
+	for len(s) >= 8 {
+		v := binary.LittleEndian.Uint64(s[:8])
+		if HasZeroByte(v^v1) || HasZeroByte(v^v2) {
+			break
+		}
+		s = s[8:]
+		offset += 8
+	}
+	for i, c := range s {
+		if c == a || c == b {
+			return offset + i
+		}
+	}
+	return -1
+}
+
+// IndexByte3 returns the index of the first instance of a, b or c in s, or -1.
+// It scans eight bytes at a time using SWAR.
+func IndexByte3(s []byte, a, b, c byte) int {
+	v1 := uint64(a) * splat
+	v2 := uint64(b) * splat
+	v3 := uint64(c) * splat
+	offset := 0
+	for len(s) >= 8 {
+		v := binary.LittleEndian.Uint64(s[:8])
+		if HasZeroByte(v^v1) || HasZeroByte(v^v2) || HasZeroByte(v^v3) {
+			break
+		}
+		s = s[8:]
+		offset += 8
+	}
+	for i, x := range s {
+		if x == a || x == b || x == c {
+			return offset + i
+		}
+	}
+	return -1
+}
+
+// HasZeroByte reports whether any of the eight bytes in x is zero.
+// XOR a word with a splatted byte first to test for that byte.
+func HasZeroByte(x uint64) bool {
+	return hasZeroByte(x)
+}
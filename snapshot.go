@@ -0,0 +1,263 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+)
+
+// snapshotMagic starts the binary encoding of an Index.
+const snapshotMagic = "GSXIDX01"
+
+// The binary encoding of an Index is little-endian and laid out as:
+//
+//	header:  magic, size, #records, #ids, #paths, record path (off, len)
+//	records: #records × (offset u64, length u64)
+//	ids:     #ids × (key off u32, key len u32, offset u64), sorted by key
+//	paths:   #paths × (key off u32, key len u32, offset u64, count u64), sorted by key
+//	strings: the keys and the record path, referred to by offset
+const (
+	snapshotHeaderLen = 48
+	snapshotRecordLen = 16
+	snapshotIDLen     = 16
+	snapshotPathLen   = 24
+)
+
+var errBadSnapshot = errors.New("gosax: malformed index snapshot")
+
+// MarshalBinary returns the binary encoding of ix, which can be read in
+// place by NewIndexSnapshot.
+func (ix *Index) MarshalBinary() ([]byte, error) {
+	var strs []byte
+	addString := func(s string) []byte {
+		var e [8]byte
+		binary.LittleEndian.PutUint32(e[0:], uint32(len(strs)))
+		binary.LittleEndian.PutUint32(e[4:], uint32(len(s)))
+		strs = append(strs, s...)
+		return e[:]
+	}
+	n := snapshotHeaderLen + len(ix.Records)*snapshotRecordLen + len(ix.IDs)*snapshotIDLen + len(ix.Paths)*snapshotPathLen
+	b := make([]byte, 0, n)
+	b = append(b, snapshotMagic...)
+	b = binary.LittleEndian.AppendUint64(b, uint64(ix.Size))
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(ix.Records)))
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(ix.IDs)))
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(ix.Paths)))
+	b = append(b, addString(ix.RecordPath)...)
+	for _, s := range ix.Records {
+		b = binary.LittleEndian.AppendUint64(b, uint64(s.Offset))
+		b = binary.LittleEndian.AppendUint64(b, uint64(s.Length))
+	}
+	for _, id := range sortedKeys(ix.IDs) {
+		b = append(b, addString(id)...)
+		b = binary.LittleEndian.AppendUint64(b, uint64(ix.IDs[id]))
+	}
+	for _, p := range sortedKeys(ix.Paths) {
+		info := ix.Paths[p]
+		b = append(b, addString(p)...)
+		b = binary.LittleEndian.AppendUint64(b, uint64(info.Offset))
+		b = binary.LittleEndian.AppendUint64(b, uint64(info.Count))
+	}
+	if len(strs) > math.MaxUint32 {
+		return nil, errors.New("gosax: index too large for a snapshot")
+	}
+	return append(b, strs...), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// UnmarshalBinary decodes the binary encoding of an Index.
+func (ix *Index) UnmarshalBinary(b []byte) error {
+	s, err := NewIndexSnapshot(b)
+	if err != nil {
+		return err
+	}
+	*ix = *s.Index()
+	return nil
+}
+
+// An IndexSnapshot is a read-only view of an Index encoded by MarshalBinary.
+// It reads the encoding in place, so it can be backed by a memory-mapped
+// file shared by processes, and it is safe for concurrent use.
+type IndexSnapshot struct {
+	b                   []byte
+	records, ids, paths []byte
+	strs                []byte
+
+	numRecords, numIDs, numPaths int
+}
+
+// NewIndexSnapshot returns a view of the encoding b, which must not be
+// modified while the snapshot is in use.
+func NewIndexSnapshot(b []byte) (*IndexSnapshot, error) {
+	if len(b) < snapshotHeaderLen || string(b[:8]) != snapshotMagic {
+		return nil, errBadSnapshot
+	}
+	var counts [3]uint64
+	for i := range counts {
+		counts[i] = binary.LittleEndian.Uint64(b[16+8*i:])
+	}
+	rest := b[snapshotHeaderLen:]
+	var sections [3][]byte
+	for i, size := range []uint64{snapshotRecordLen, snapshotIDLen, snapshotPathLen} {
+		if counts[i] > uint64(len(rest))/size {
+			return nil, errBadSnapshot
+		}
+		sections[i], rest = rest[:counts[i]*size], rest[counts[i]*size:]
+	}
+	s := &IndexSnapshot{
+		b:          b,
+		records:    sections[0],
+		ids:        sections[1],
+		paths:      sections[2],
+		strs:       rest,
+		numRecords: int(counts[0]),
+		numIDs:     int(counts[1]),
+		numPaths:   int(counts[2]),
+	}
+	if _, ok := s.str(b[40:]); !ok {
+		return nil, errBadSnapshot
+	}
+	for i := range s.numIDs {
+		if _, ok := s.str(s.ids[i*snapshotIDLen:]); !ok {
+			return nil, errBadSnapshot
+		}
+	}
+	for i := range s.numPaths {
+		if _, ok := s.str(s.paths[i*snapshotPathLen:]); !ok {
+			return nil, errBadSnapshot
+		}
+	}
+	return s, nil
+}
+
+// str returns the string referred to by the offset and length at e.
+func (s *IndexSnapshot) str(e []byte) ([]byte, bool) {
+	off := uint64(binary.LittleEndian.Uint32(e))
+	n := uint64(binary.LittleEndian.Uint32(e[4:]))
+	if off+n > uint64(len(s.strs)) {
+		return nil, false
+	}
+	return s.strs[off : off+n], true
+}
+
+// key returns the string of a checked entry.
+func (s *IndexSnapshot) key(e []byte) []byte {
+	k, _ := s.str(e)
+	return k
+}
+
+// Size returns the size of the indexed input.
+func (s *IndexSnapshot) Size() int64 {
+	return int64(binary.LittleEndian.Uint64(s.b[8:]))
+}
+
+// RecordPath returns the path pattern of the record elements.
+func (s *IndexSnapshot) RecordPath() string {
+	return string(s.key(s.b[40:]))
+}
+
+// NumRecords returns the number of records.
+func (s *IndexSnapshot) NumRecords() int {
+	return s.numRecords
+}
+
+// Record returns the byte range of the i-th record element.
+func (s *IndexSnapshot) Record(i int) Section {
+	e := s.records[i*snapshotRecordLen:]
+	return Section{
+		Offset: int64(binary.LittleEndian.Uint64(e)),
+		Length: int64(binary.LittleEndian.Uint64(e[8:])),
+	}
+}
+
+// ID returns the offset of the start tag of the element with the given ID.
+func (s *IndexSnapshot) ID(id string) (int64, bool) {
+	i, ok := s.search(s.ids, snapshotIDLen, s.numIDs, id)
+	if !ok {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(s.ids[i*snapshotIDLen+8:])), true
+}
+
+// Path returns the description of the elements at an element path.
+func (s *IndexSnapshot) Path(path string) (PathInfo, bool) {
+	i, ok := s.search(s.paths, snapshotPathLen, s.numPaths, path)
+	if !ok {
+		return PathInfo{}, false
+	}
+	e := s.paths[i*snapshotPathLen:]
+	return PathInfo{
+		Offset: int64(binary.LittleEndian.Uint64(e[8:])),
+		Count:  int(binary.LittleEndian.Uint64(e[16:])),
+	}, true
+}
+
+// search finds key in the n sorted entries of size in table.
+func (s *IndexSnapshot) search(table []byte, size, n int, key string) (int, bool) {
+	i := sort.Search(n, func(i int) bool {
+		return string(s.key(table[i*size:])) >= key
+	})
+	return i, i < n && string(s.key(table[i*size:])) == key
+}
+
+// Index decodes the snapshot into an Index.
+func (s *IndexSnapshot) Index() *Index {
+	ix := &Index{
+		Size:       s.Size(),
+		RecordPath: s.RecordPath(),
+		Records:    make([]Section, s.numRecords),
+		IDs:        make(map[string]int64, s.numIDs),
+		Paths:      make(map[string]PathInfo, s.numPaths),
+	}
+	for i := range ix.Records {
+		ix.Records[i] = s.Record(i)
+	}
+	for i := range s.numIDs {
+		e := s.ids[i*snapshotIDLen:]
+		ix.IDs[string(s.key(e))] = int64(binary.LittleEndian.Uint64(e[8:]))
+	}
+	for i := range s.numPaths {
+		e := s.paths[i*snapshotPathLen:]
+		ix.Paths[string(s.key(e))] = PathInfo{
+			Offset: int64(binary.LittleEndian.Uint64(e[8:])),
+			Count:  int(binary.LittleEndian.Uint64(e[16:])),
+		}
+	}
+	return ix
+}
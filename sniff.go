@@ -0,0 +1,82 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// RootInfo describes the start of a document, as read by SniffRoot.
+type RootInfo struct {
+	// Name is the qualified name of the root element and Space its
+	// namespace URI, as declared on the root element.
+	Name  string
+	Space string
+	// Encoding is the encoding declared by the XML declaration, or "".
+	Encoding string
+	// DocType is the name of the root element declared by the document
+	// type declaration, or "".
+	DocType string
+}
+
+// SniffRoot reads at most limit bytes of r, up to the start tag of the
+// root element, and describes it. It returns a reader of the whole input,
+// the bytes read by SniffRoot followed by the rest of r, for reading the
+// document again. If the root element does not start within limit bytes,
+// SniffRoot returns an error along with that reader.
+func SniffRoot(r io.Reader, limit int) (RootInfo, io.Reader, error) {
+	var head bytes.Buffer
+	xr := NewReaderSize(io.TeeReader(io.LimitReader(r, int64(limit)), &head), newBufferSize)
+	xr.TrackNamespaces = true
+	var info RootInfo
+	err := func() error {
+		for {
+			ev, err := xr.Event()
+			if err != nil {
+				return err
+			}
+			switch ev.Type() {
+			case EventDocType:
+				if dtd, err := ParseDTD(ev.Bytes); err == nil {
+					info.DocType = dtd.Name
+				}
+			case EventStart:
+				name, _ := Name(ev.Bytes)
+				space, _ := xr.ResolveName(name)
+				info.Name = string(name)
+				info.Space = string(space)
+				info.Encoding = xr.Encoding()
+				return nil
+			case EventEOF:
+				return fmt.Errorf("gosax: no root element in the first %d bytes", limit)
+			}
+		}
+	}()
+	return info, io.MultiReader(&head, r), err
+}
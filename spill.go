@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// A SpillFile stores a text event spilled by a Reader with Spill, such as
+// an *os.File.
+type SpillFile interface {
+	io.Writer
+	io.ReaderAt
+}
+
+// Spilled returns the text of the last event returned by Event, as it
+// appears in the input, when it was spilled to the SpillFile returned by
+// Spill. It returns nil for other events.
+func (r *Reader) Spilled() *io.SectionReader {
+	if r.spill == nil {
+		return nil
+	}
+	return io.NewSectionReader(r.spill, 0, r.spillLen)
+}
+
+// isTokenLimit reports whether err reports an event larger than
+// MaxTokenSize.
+func isTokenLimit(err error) bool {
+	var lerr *LimitError
+	return errors.As(err, &lerr) && lerr.Limit == "MaxTokenSize"
+}
+
+// spillEvent writes the text of ev, which the buffer holds whole, to a
+// SpillFile from Spill, and returns it as an EventText with empty Bytes.
+func (r *Reader) spillEvent(ev Event) (Event, bool, error) {
+	b := ev.Bytes
+	if r.raw != nil {
+		b = r.raw
+	}
+	f, err := r.Spill()
+	if err != nil {
+		return Event{}, false, err
+	}
+	if _, err := f.Write(b); err != nil {
+		return Event{}, false, err
+	}
+	r.raw = nil
+	r.spill, r.spillLen = f, int64(len(b))
+	return Event{value: uint64(EventText)}, true, nil
+}
+
+// spillText writes the text at the front of the window, up to the next
+// markup, to a SpillFile from Spill, and returns it as an EventText with
+// empty Bytes.
+func (r *Reader) spillText() (Event, error) {
+	rr := &r.reader
+	rr.err = nil
+	f, err := r.Spill()
+	if err != nil {
+		return Event{}, err
+	}
+	var n int64
+	for {
+		w := rr.window()
+		i := bytes.IndexByte(w, '<')
+		chunk := w
+		if i >= 0 {
+			chunk = w[:i]
+		}
+		if _, err := f.Write(chunk); err != nil {
+			return Event{}, err
+		}
+		n += int64(len(chunk))
+		rr.release(len(chunk))
+		if i >= 0 {
+			r.state = (*Reader).stateInsideMarkup
+			break
+		}
+		if rr.extend() == 0 {
+			if rr.err != io.EOF {
+				return Event{}, rr.err
+			}
+			r.state = (*Reader).stateDone
+			break
+		}
+	}
+	r.spill, r.spillLen = f, n
+	return Event{value: uint64(EventText)}, nil
+}
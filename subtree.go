@@ -0,0 +1,165 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// SubtreeReader returns an io.Reader that streams the raw bytes of the
+// element started by the last EventStart, through its matching end tag.
+// The Reader is advanced as the returned io.Reader is consumed, so the
+// element is never held in memory as a whole: text is streamed from the
+// input in pieces, without the options that check or rewrite text events,
+// and other events are held one at a time.
+//
+// The Reader must not be used until the returned io.Reader reports io.EOF.
+// After that, Event continues with the event following the end tag.
+func (r *Reader) SubtreeReader() (io.Reader, error) {
+	if r.last.Type() != EventStart {
+		return nil, errors.New("gosax: SubtreeReader called without EventStart")
+	}
+	s := &subtreeReader{
+		r:       r,
+		pending: r.last.Bytes,
+	}
+	if isSelfClosing(r.last.Bytes) {
+		s.done = true
+		s.skipEnd = r.EmitSelfClosingTag
+	} else {
+		s.inText = s.streams()
+	}
+	return s, nil
+}
+
+type subtreeReader struct {
+	r       *Reader
+	pending []byte
+	depth   int
+	skipEnd bool
+	done    bool
+	// inText is set while the input continues with text to stream.
+	inText bool
+	err    error
+}
+
+func (s *subtreeReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			if s.skipEnd {
+				s.skipEnd = false
+				if _, err := s.r.Event(); err != nil {
+					s.err = err
+					continue
+				}
+			}
+			return 0, io.EOF
+		}
+		if s.inText {
+			s.text()
+			continue
+		}
+		s.next()
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *subtreeReader) next() {
+	ev, err := s.r.Event()
+	if err != nil {
+		s.err = err
+		return
+	}
+	switch ev.Type() {
+	case EventStart:
+		if isSelfClosing(ev.Bytes) {
+			s.skipEnd = s.r.EmitSelfClosingTag
+		} else {
+			s.depth++
+		}
+	case EventEnd:
+		if s.skipEnd {
+			s.skipEnd = false
+			s.inText = s.streams()
+			return
+		}
+		if s.depth == 0 {
+			s.done = true
+		} else {
+			s.depth--
+		}
+	case EventEOF:
+		s.err = io.ErrUnexpectedEOF
+		return
+	}
+	s.pending = ev.Bytes
+	s.inText = ev.Type() != EventText && !s.skipEnd && s.streams()
+}
+
+// streams reports whether text can be streamed from the input rather than
+// read as events.
+func (s *subtreeReader) streams() bool {
+	return !s.r.Lenient && !s.r.Follow
+}
+
+// text sets pending to the text at the front of the window, up to the next
+// markup, releasing it so that the window does not grow with the text.
+func (s *subtreeReader) text() {
+	rr := &s.r.reader
+	for {
+		w := rr.window()
+		if i := bytes.IndexByte(w, '<'); i >= 0 {
+			s.pending = w[:i]
+			s.inText = false
+			rr.release(i)
+			return
+		}
+		if len(w) > 0 {
+			s.pending = w
+			rr.release(len(w))
+			return
+		}
+		if rr.extend() == 0 {
+			s.err = rr.err
+			if s.err == io.EOF || s.err == nil {
+				s.err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+	}
+}
+
+func isSelfClosing(b []byte) bool {
+	return len(b) >= 2 && b[len(b)-2] == '/'
+}
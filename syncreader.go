@@ -0,0 +1,75 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "sync"
+
+// SyncReader is an EventReader that is safe for concurrent use, for the
+// rare cases where several goroutines pull from one event stream, such as
+// work-stealing consumers. Each event is copied before the lock is
+// released, so unlike those of a Reader, the events it returns stay valid
+// and belong to the caller. Events are handed out in order, each to one
+// caller; Event.Seq tells their position in the stream.
+//
+// Once the underlying EventReader fails, every call returns the same error.
+type SyncReader struct {
+	mu  sync.Mutex
+	r   EventReader
+	err error
+}
+
+// NewSyncReader returns a SyncReader reading from r, which must not be
+// used by anything else afterwards. If r is a *Reader, NewSyncReader sets
+// its SeqNumbers, so that Event.Seq numbers the events; other
+// EventReaders must number them themselves.
+func NewSyncReader(r EventReader) *SyncReader {
+	if xr, ok := r.(*Reader); ok {
+		xr.SeqNumbers = true
+	}
+	return &SyncReader{r: r}
+}
+
+// Event returns a copy of the next event of the underlying EventReader.
+func (s *SyncReader) Event() (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return Event{}, s.err
+	}
+	e, err := s.r.Event()
+	if err != nil {
+		s.err = err
+		return Event{}, err
+	}
+	return e.clone(), nil
+}
+
+// clone returns a copy of e that does not share memory with it.
+func (e Event) clone() Event {
+	e.Bytes = append([]byte(nil), e.Bytes...)
+	return e
+}
@@ -0,0 +1,53 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "fmt"
+
+// A SyntaxError is returned by Event for input that is not well-formed.
+type SyntaxError struct {
+	Msg string
+	// Offset is the input offset of the failing event, or of the bad byte
+	// for CheckChars.
+	Offset int64
+	// PartialEvent holds the bytes of the failing event scanned so far.
+	// Like Event.Bytes, it is only valid until the next call to Event.
+	PartialEvent []byte
+}
+
+func (e *SyntaxError) Error() string {
+	return "gosax: " + e.Msg
+}
+
+// syntaxError returns a SyntaxError for the event at offset.
+func syntaxError(offset int64, partial []byte, format string, args ...any) *SyntaxError {
+	return &SyntaxError{
+		Msg:          fmt.Sprintf(format, args...),
+		Offset:       offset,
+		PartialEvent: partial,
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// A Table flattens the repeated elements of a document into rows.
+type Table struct {
+	// Row is the slash-separated path of the row elements from the document
+	// element, such as "catalog/book", where a "*" step matches any name.
+	Row string
+	// Columns are the cells of each row.
+	Columns []Column
+}
+
+// A Column selects a cell relative to the row element.
+//
+// Path is a slash-separated path of descendant elements, optionally ending
+// in an "@name" step to select an attribute instead of the text content.
+// The empty path selects the text of the row element itself, and "@id" its
+// id attribute. When several elements match, the first one is used.
+type Column struct {
+	Name string
+	Path string
+}
+
+// Rows reads r to the end and calls fn with the cells of each row, in the
+// order of t.Columns. A missing cell is empty. The cells are valid only
+// until fn returns.
+func (t *Table) Rows(r *Reader, fn func(cells [][]byte) error) error {
+	cols := make([]tableColumn, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i].path, cols[i].attr, _ = strings.Cut(c.Path, "@")
+		cols[i].path = strings.TrimSuffix(cols[i].path, "/")
+	}
+	cells := make([][]byte, len(cols))
+	var path elementPath
+	row := 0 // length of the row element's path, when inside a row
+	for {
+		ev, err := r.Event()
+		if err != nil {
+			return err
+		}
+		switch ev.Type() {
+		case EventStart:
+			path.update(ev)
+			selfClosing := isSelfClosing(ev.Bytes)
+			if row == 0 {
+				if !matchPath(t.Row, path.bytes()) {
+					continue
+				}
+				row = len(path.bytes())
+				for i := range cols {
+					cols[i].reset()
+				}
+			}
+			rel := path.bytes()[row:]
+			if len(rel) > 0 {
+				rel = rel[1:]
+			}
+			for i := range cols {
+				if err := cols[i].start(rel, path.depth(), ev.Bytes); err != nil {
+					return err
+				}
+			}
+			if selfClosing && len(rel) == 0 {
+				if err := t.emit(cols, cells, fn); err != nil {
+					return err
+				}
+				row = 0
+			}
+		case EventEnd:
+			path.settle()
+			if row == 0 || isSelfClosing(ev.Bytes) {
+				path.update(ev)
+				continue
+			}
+			for i := range cols {
+				if cols[i].depth == path.depth() {
+					cols[i].depth = 0
+				}
+			}
+			end := len(path.bytes()) == row
+			path.update(ev)
+			if end {
+				if err := t.emit(cols, cells, fn); err != nil {
+					return err
+				}
+				row = 0
+			}
+		case EventText, EventCData, EventEntityRef:
+			for i := range cols {
+				if cols[i].depth > 0 {
+					if cols[i].value, err = appendCharData(cols[i].value, ev); err != nil {
+						return err
+					}
+				}
+			}
+		case EventEOF:
+			return nil
+		}
+	}
+}
+
+func (t *Table) emit(cols []tableColumn, cells [][]byte, fn func([][]byte) error) error {
+	for i := range cols {
+		cols[i].depth = 0
+		cells[i] = cols[i].value
+	}
+	return fn(cells)
+}
+
+// WriteCSV writes the rows of r to w as CSV, preceded by a header of the
+// column names. It returns the number of rows written.
+func (t *Table) WriteCSV(w io.Writer, r *Reader) (int, error) {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		record[i] = c.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return 0, err
+	}
+	n := 0
+	err := t.Rows(r, func(cells [][]byte) error {
+		for i, c := range cells {
+			record[i] = string(c)
+		}
+		n++
+		return cw.Write(record)
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return n, err
+}
+
+type tableColumn struct {
+	path  string
+	attr  string
+	value []byte
+	set   bool
+	depth int // depth of the element whose text is being collected
+}
+
+func (c *tableColumn) reset() {
+	c.value = c.value[:0]
+	c.set = false
+	c.depth = 0
+}
+
+// start applies the start tag b at the row-relative path rel.
+func (c *tableColumn) start(rel []byte, depth int, b []byte) error {
+	if c.set || (c.path != "" || len(rel) > 0) && !matchPath(c.path, rel) {
+		return nil
+	}
+	c.set = true
+	if c.attr == "" {
+		if !isSelfClosing(b) {
+			c.depth = depth
+		}
+		return nil
+	}
+	_, b = Name(b)
+	for len(b) > 0 {
+		attr, rest, err := NextAttribute(b)
+		if err != nil {
+			return err
+		}
+		if len(attr.Key) == 0 {
+			break
+		}
+		b = rest
+		if string(attr.Key) != c.attr || len(attr.Value) < 2 {
+			continue
+		}
+		c.value = append(c.value, attr.Value[1:len(attr.Value)-1]...)
+		v, err := UnescapeAttr(c.value)
+		c.value = v
+		return err
+	}
+	return nil
+}
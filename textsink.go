@@ -0,0 +1,117 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import "io"
+
+// TextSinks is an EventReader that streams the character data of the
+// elements matching path patterns to writers, instead of reporting it as
+// events. The text is unescaped and passed on as it is read, so it never
+// has to fit in the buffer. The start and end tags of the elements are
+// still reported.
+//
+// The elements must hold only character data, CDATA sections, comments and
+// processing instructions; a child element is an error.
+type TextSinks struct {
+	r     *Reader
+	sinks []textSink
+	path  elementPath
+	// end is the end tag of the last streamed element, reported after its
+	// start tag. buf holds the bytes of both.
+	end    Event
+	queued bool
+	buf    []byte
+}
+
+type textSink struct {
+	pattern string
+	open    func(path []byte, start Event) (io.WriteCloser, error)
+}
+
+// NewTextSinks returns a TextSinks reading from r.
+func NewTextSinks(r *Reader) *TextSinks {
+	return &TextSinks{r: r}
+}
+
+// Add makes t stream the text of the elements matching pattern, such as
+// "feed/entry/content" or "*/item/body", to the writer returned by open
+// for each of them, which is closed at the end of the element. open is
+// called with the path of the element and its start tag. The first
+// pattern added that matches is used.
+func (t *TextSinks) Add(pattern string, open func(path []byte, start Event) (io.WriteCloser, error)) {
+	t.sinks = append(t.sinks, textSink{pattern, open})
+}
+
+// Event returns the next event that is not streamed to a sink.
+func (t *TextSinks) Event() (Event, error) {
+	if t.queued {
+		t.queued = false
+		t.path.update(t.end)
+		return t.end, nil
+	}
+	ev, err := t.r.Event()
+	if err != nil {
+		return ev, err
+	}
+	t.path.update(ev)
+	if ev.Type() != EventStart || isSelfClosing(ev.Bytes) {
+		return ev, nil
+	}
+	var open func(path []byte, start Event) (io.WriteCloser, error)
+	for _, s := range t.sinks {
+		if matchPath(s.pattern, t.path.bytes()) {
+			open = s.open
+			break
+		}
+	}
+	if open == nil {
+		return ev, nil
+	}
+	w, err := open(t.path.bytes(), ev)
+	if err != nil {
+		return Event{}, err
+	}
+	// Streaming overwrites the buffer holding the start tag.
+	t.buf = append(t.buf[:0], ev.Bytes...)
+	err = streamText(t.r, func(b []byte) error {
+		_, err := w.Write(b)
+		return err
+	})
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Event{}, err
+	}
+	n := len(t.buf)
+	t.buf = append(t.buf, t.r.last.Bytes...)
+	ev.Bytes = t.buf[:n]
+	t.end = t.r.last
+	t.end.Bytes = t.buf[n:]
+	t.queued = true
+	return ev, nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// An EventTimeoutError is returned by Event when reading a single event
+// takes longer than Reader.EventTimeout.
+type EventTimeoutError struct {
+	// Duration is the EventTimeout that was exceeded.
+	Duration time.Duration
+	// Offset is the input offset of the unfinished event.
+	Offset int64
+	// Type is the type of the unfinished event, as far as it was read.
+	Type EventType
+	// Buffered is the number of bytes of the event read so far.
+	Buffered int
+}
+
+func (e *EventTimeoutError) Error() string {
+	return fmt.Sprintf("gosax: %v at offset %d not read within %v", e.Type, e.Offset, e.Duration)
+}
+
+// Timeout reports true, as for a net.Error.
+func (e *EventTimeoutError) Timeout() bool { return true }
+
+// errEventDeadline is set by byteReader.extend when the deadline passed.
+var errEventDeadline = errors.New("gosax: event deadline exceeded")
+
+// readDeadliner is implemented by readers that support deadlines, such as
+// *os.File and net.Conn.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// checkDeadline returns an error once the deadline has passed, and
+// otherwise makes it apply to the next Read of the underlying reader, when
+// it supports deadlines.
+func (b *byteReader) checkDeadline() error {
+	if time.Now().After(b.deadline) {
+		return errEventDeadline
+	}
+	if d, ok := b.r.(readDeadliner); ok {
+		d.SetReadDeadline(b.deadline)
+	}
+	return nil
+}
+
+// eventTimeoutError converts the errors of a missed event deadline.
+func (r *Reader) eventTimeoutError(err error) error {
+	if err != errEventDeadline && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	w := r.reader.window()
+	return &EventTimeoutError{
+		Duration: r.EventTimeout,
+		Offset:   r.reader.inputOffset(),
+		Type:     pendingEventType(w),
+		Buffered: len(w),
+	}
+}
+
+// pendingEventType returns the type of the event starting at w.
+func pendingEventType(w []byte) EventType {
+	switch {
+	case len(w) == 0 || w[0] != '<':
+		return EventText
+	case bytes.HasPrefix(w, []byte("</")):
+		return EventEnd
+	case bytes.HasPrefix(w, []byte("<?")):
+		return EventProcessingInstruction
+	case bytes.HasPrefix(w, []byte("<!--")):
+		return EventComment
+	case bytes.HasPrefix(w, []byte("<![CDATA[")):
+		return EventCData
+	case bytes.HasPrefix(w, []byte("<!")):
+		return EventDocType
+	}
+	return EventStart
+}
@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxDeclSniff is the number of bytes searched for the end of the XML
+// declaration by DetectEncoding.
+const maxDeclSniff = 1024
+
+// detectEncoding implements DetectEncoding and CharsetReader: it sniffs
+// the start of the input and, if needed, makes the Reader read it through
+// a transcoder.
+func (r *Reader) detectEncoding() error {
+	rr := &r.reader
+	// Enough for a UTF-8 byte order mark followed by "<?xml".
+	for len(rr.window()) < 8 && rr.extend() > 0 {
+	}
+	w := rr.window()
+	var decode func(dst, src []byte, final bool) ([]byte, int)
+	skip := 0
+	switch {
+	case !r.DetectEncoding:
+	case bytes.HasPrefix(w, []byte("\xfe\xff")):
+		decode, skip = decodeUTF16BE, 2
+	case bytes.HasPrefix(w, []byte("\xff\xfe")):
+		decode, skip = decodeUTF16LE, 2
+	case bytes.HasPrefix(w, []byte("\x00<\x00?")):
+		decode = decodeUTF16BE
+	case bytes.HasPrefix(w, []byte("<\x00?\x00")):
+		decode = decodeUTF16LE
+	}
+	if decode != nil {
+		rr.r = newTranscoder(r.restart(skip), decode)
+		return nil
+	}
+	name, err := r.sniffDeclEncoding()
+	if err != nil {
+		return err
+	}
+	switch strings.ToUpper(name) {
+	case "", "UTF-8", "UTF8", "US-ASCII", "ASCII":
+		return nil
+	}
+	if r.CharsetReader != nil {
+		cr, err := r.CharsetReader(name, r.restart(0))
+		if err != nil {
+			return fmt.Errorf("gosax: charset reader for %q: %w", name, err)
+		}
+		rr.r = cr
+		return nil
+	}
+	switch strings.ToUpper(name) {
+	case "ISO-8859-1", "ISO_8859-1", "LATIN1", "L1":
+		if r.DetectEncoding {
+			rr.r = newTranscoder(r.restart(0), decodeLatin1)
+			return nil
+		}
+	}
+	return fmt.Errorf("gosax: unsupported encoding %q", name)
+}
+
+// restart empties the buffer and returns a reader of the input from the
+// start of the buffered bytes after skip, to be read through a transcoder.
+func (r *Reader) restart(skip int) io.Reader {
+	rr := &r.reader
+	head := bytes.Clone(rr.window()[skip:])
+	var rest io.Reader = rr.r
+	if rr.err != nil {
+		rest = errReader{rr.err}
+	}
+	rr.data, rr.offset, rr.base, rr.err = rr.data[:0], 0, 0, nil
+	return io.MultiReader(bytes.NewReader(head), rest)
+}
+
+// sniffDeclEncoding returns the encoding declared by the XML declaration
+// at the start of the buffered input, if any.
+func (r *Reader) sniffDeclEncoding() (string, error) {
+	rr := &r.reader
+	w := bytes.TrimPrefix(rr.window(), utf8BOM)
+	if !bytes.HasPrefix(w, []byte("<?xml")) {
+		return "", nil
+	}
+	for {
+		w = bytes.TrimPrefix(rr.window(), utf8BOM)
+		if i := bytes.Index(w, []byte("?>")); i >= 0 {
+			d, ok := parseXMLDecl(w[:i+2])
+			if !ok && isXMLDecl(w[:i+2]) {
+				return "", syntaxError(rr.inputOffset()+int64(len(rr.window())-len(w)), w[:i+2], "invalid XML declaration")
+			}
+			return d.encoding, nil
+		}
+		if len(w) >= maxDeclSniff || rr.extend() == 0 {
+			return "", nil
+		}
+	}
+}
+
+// A transcoder reads r converted to UTF-8 by decode, which appends the
+// decoding of a prefix of src to dst and returns the length of the prefix.
+// final is set at the end of the input.
+type transcoder struct {
+	r      io.Reader
+	decode func(dst, src []byte, final bool) ([]byte, int)
+	in     []byte
+	out    []byte
+	err    error
+}
+
+func newTranscoder(r io.Reader, decode func(dst, src []byte, final bool) ([]byte, int)) *transcoder {
+	return &transcoder{r: r, decode: decode, in: make([]byte, 0, 4096)}
+}
+
+func (t *transcoder) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		n, err := t.r.Read(t.in[len(t.in):cap(t.in)])
+		t.in = t.in[:len(t.in)+n]
+		var k int
+		t.out, k = t.decode(t.out[:0], t.in, err != nil)
+		t.in = t.in[:copy(t.in, t.in[k:])]
+		t.err = err
+	}
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+func decodeUTF16BE(dst, src []byte, final bool) ([]byte, int) {
+	return decodeUTF16(dst, src, final, func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) })
+}
+
+func decodeUTF16LE(dst, src []byte, final bool) ([]byte, int) {
+	return decodeUTF16(dst, src, final, func(b []byte) uint16 { return uint16(b[1])<<8 | uint16(b[0]) })
+}
+
+func decodeUTF16(dst, src []byte, final bool, unit func([]byte) uint16) ([]byte, int) {
+	i := 0
+	for i+2 <= len(src) {
+		u := rune(unit(src[i:]))
+		if utf16.IsSurrogate(u) {
+			if i+4 > len(src) && !final {
+				break
+			}
+			if i+4 <= len(src) {
+				if c := utf16.DecodeRune(u, rune(unit(src[i+2:]))); c != utf8.RuneError {
+					dst = utf8.AppendRune(dst, c)
+					i += 4
+					continue
+				}
+			}
+			u = utf8.RuneError
+		}
+		dst = utf8.AppendRune(dst, u)
+		i += 2
+	}
+	if final && i < len(src) {
+		dst = utf8.AppendRune(dst, utf8.RuneError)
+		i = len(src)
+	}
+	return dst, i
+}
+
+func decodeLatin1(dst, src []byte, _ bool) ([]byte, int) {
+	for _, c := range src {
+		dst = utf8.AppendRune(dst, rune(c))
+	}
+	return dst, len(src)
+}
@@ -0,0 +1,607 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"unicode/utf8"
+)
+
+// Writer writes XML to an io.Writer.
+// It is the output counterpart of Reader: events read by a Reader can be
+// passed through unchanged with WriteEvent, and new markup can be produced
+// with the element, attribute and text methods, which escape as needed.
+//
+// Attributes are written in the order they are given: in the order of the
+// Attr calls, or as they appear in the events passed to WriteEvent, unless
+// SortAttrs is set.
+//
+// Output is buffered; call Flush when done.
+type Writer struct {
+	// Charset is the output encoding. It must be set before anything is
+	// written. Characters the charset cannot represent are written as
+	// character references in text and attribute values; elsewhere they
+	// make Flush fail.
+	Charset Charset
+	// Escaping is the escaping policy of Text and Attr.
+	Escaping Escaping
+	// CDATAThreshold, if positive, makes Text write text of at least this
+	// many bytes that would need escaping as CDATA sections instead.
+	CDATAThreshold int
+	// SortAttrs makes the Writer sort the attributes of each start tag by
+	// name, including those of events passed to WriteEvent, for output that
+	// does not depend on the order attributes were produced in.
+	SortAttrs bool
+	// Prefixes maps namespace URIs to the prefixes StartElementNS and AttrNS
+	// declare for them. Elements in other namespaces are put in the default
+	// namespace, and attributes get generated prefixes.
+	Prefixes map[string]string
+
+	w       io.Writer
+	buf     []byte
+	out     []byte
+	names   []byte
+	ends    []int
+	open    bool
+	started bool
+	err     error
+
+	// attrs holds the attributes of the open start tag while SortAttrs is
+	// set, each starting with a space, and attrKeys their name spans.
+	attrs    []byte
+	attrKeys []attrSpan
+
+	// ns tracks the namespace declarations of the open elements, and nsSeq
+	// numbers generated prefixes.
+	ns    nsScope
+	nsSeq int
+}
+
+type attrSpan struct {
+	start, keyEnd, end int
+}
+
+// NewWriter returns a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:   w,
+		buf: make([]byte, 0, 4096),
+	}
+}
+
+// StartElement opens an element named name.
+// Attributes can be added with Attr until any other content is written.
+func (w *Writer) StartElement(name []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.closeStart()
+	w.buf = append(w.buf, '<')
+	w.buf = append(w.buf, name...)
+	w.push(name)
+	w.ns.push()
+	w.open = true
+	return w.flushIfFull()
+}
+
+// Attr adds an attribute to the element opened by the last StartElement.
+// value is escaped.
+func (w *Writer) Attr(key, value []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if !w.open {
+		return errors.New("gosax: Attr called outside of a start tag")
+	}
+	if prefix, ok := nsDecl(key); ok {
+		w.ns.declare(prefix, string(value))
+	}
+	return w.attr(key, value)
+}
+
+func (w *Writer) attr(key, value []byte) error {
+	if w.SortAttrs {
+		span := attrSpan{start: len(w.attrs)}
+		w.attrs = append(w.attrs, ' ')
+		w.attrs = append(w.attrs, key...)
+		span.keyEnd = len(w.attrs)
+		w.attrs = append(w.attrs, '=', '"')
+		start := len(w.attrs)
+		w.attrs = w.Escaping.appendAttr(w.attrs, value)
+		w.attrs = w.Charset.appendCharRefs(w.attrs, start, "", "")
+		w.attrs = append(w.attrs, '"')
+		span.end = len(w.attrs)
+		w.attrKeys = append(w.attrKeys, span)
+		return nil
+	}
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, key...)
+	w.buf = append(w.buf, '=', '"')
+	start := len(w.buf)
+	w.buf = w.Escaping.appendAttr(w.buf, value)
+	w.buf = w.Charset.appendCharRefs(w.buf, start, "", "")
+	w.buf = append(w.buf, '"')
+	return w.flushIfFull()
+}
+
+// EndElement closes the innermost open element.
+func (w *Writer) EndElement() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.ends) == 0 {
+		return errors.New("gosax: EndElement without open element")
+	}
+	w.closeStart()
+	w.ns.pop()
+	w.buf = append(w.buf, '<', '/')
+	w.buf = append(w.buf, w.pop()...)
+	w.buf = append(w.buf, '>')
+	return w.flushIfFull()
+}
+
+// Text writes character data. b is escaped.
+func (w *Writer) Text(b []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.CDATAThreshold > 0 && len(b) >= w.CDATAThreshold && needsEscape(b) {
+		return w.CData(b)
+	}
+	w.closeStart()
+	start := len(w.buf)
+	w.buf = w.Escaping.appendText(w.buf, b)
+	w.buf = w.Charset.appendCharRefs(w.buf, start, "", "")
+	return w.flushIfFull()
+}
+
+// CData writes b as a CDATA section.
+// Occurrences of "]]>" are split across two sections.
+func (w *Writer) CData(b []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.closeStart()
+	start := len(w.buf)
+	for {
+		i := bytes.Index(b, []byte("]]>"))
+		if i < 0 {
+			break
+		}
+		w.buf = append(w.buf, "<![CDATA["...)
+		w.buf = append(w.buf, b[:i+2]...)
+		w.buf = append(w.buf, "]]>"...)
+		b = b[i+2:]
+	}
+	w.buf = append(w.buf, "<![CDATA["...)
+	w.buf = append(w.buf, b...)
+	w.buf = append(w.buf, "]]>"...)
+	w.buf = w.Charset.appendCharRefs(w.buf, start, "]]>", "<![CDATA[")
+	return w.flushIfFull()
+}
+
+// Comment writes b as a comment.
+func (w *Writer) Comment(b []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if bytes.Contains(b, []byte("--")) || bytes.HasSuffix(b, []byte("-")) {
+		return fmt.Errorf("gosax: invalid comment: %q", b)
+	}
+	w.closeStart()
+	w.buf = append(w.buf, "<!--"...)
+	w.buf = append(w.buf, b...)
+	w.buf = append(w.buf, "-->"...)
+	return w.flushIfFull()
+}
+
+// ProcInst writes a processing instruction.
+func (w *Writer) ProcInst(target, inst []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if bytes.Contains(inst, []byte("?>")) {
+		return fmt.Errorf("gosax: invalid processing instruction: %q", inst)
+	}
+	if string(target) == "xml" && w.Charset != UTF8 {
+		inst = setEncodingDecl(inst, w.Charset.String())
+	}
+	w.closeStart()
+	w.buf = append(w.buf, "<?"...)
+	w.buf = append(w.buf, target...)
+	if len(inst) > 0 {
+		w.buf = append(w.buf, ' ')
+		w.buf = append(w.buf, inst...)
+	}
+	w.buf = append(w.buf, "?>"...)
+	return w.flushIfFull()
+}
+
+// XMLDecl writes an XML declaration naming the Charset of w.
+func (w *Writer) XMLDecl() error {
+	return w.ProcInst([]byte("xml"), []byte(`version="1.0" encoding="`+w.Charset.String()+`"`))
+}
+
+// Directive writes b as a directive such as DOCTYPE.
+func (w *Writer) Directive(b []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.closeStart()
+	w.buf = append(w.buf, "<!"...)
+	w.buf = append(w.buf, b...)
+	w.buf = append(w.buf, '>')
+	return w.flushIfFull()
+}
+
+// Raw writes b without escaping. b must be well-formed markup.
+func (w *Writer) Raw(b []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.closeStart()
+	w.buf = append(w.buf, b...)
+	return w.flushIfFull()
+}
+
+// WriteEvent writes an event read by a Reader.
+// The bytes of the event are written unchanged.
+func (w *Writer) WriteEvent(e Event) error {
+	if w.err != nil {
+		return w.err
+	}
+	switch e.Type() {
+	case EventStart:
+		w.closeStart()
+		if w.SortAttrs {
+			w.appendSortedTag(e.Bytes)
+		} else {
+			w.appendTag(e.Bytes)
+		}
+		if !isSelfClosing(e.Bytes) {
+			name, _ := Name(e.Bytes)
+			w.push(name)
+			w.declareNamespaces(e.Bytes)
+		}
+	case EventEnd:
+		if isSelfClosing(e.Bytes) {
+			// EmitSelfClosingTag reports the same tag twice.
+			return nil
+		}
+		if len(w.ends) == 0 {
+			return errors.New("gosax: EndElement without open element")
+		}
+		w.closeStart()
+		w.pop()
+		w.ns.pop()
+		w.buf = append(w.buf, e.Bytes...)
+	case EventEOF:
+		return nil
+	case EventText:
+		if e.Unescaped() {
+			return w.Text(e.Bytes)
+		}
+		w.closeStart()
+		start := len(w.buf)
+		w.buf = append(w.buf, e.Bytes...)
+		w.buf = w.Charset.appendCharRefs(w.buf, start, "", "")
+	case EventCData:
+		w.closeStart()
+		start := len(w.buf)
+		w.buf = append(w.buf, e.Bytes...)
+		w.buf = w.Charset.appendCharRefs(w.buf, start, "]]>", "<![CDATA[")
+	case EventProcessingInstruction, EventXMLDecl:
+		if w.Charset != UTF8 && bytes.HasPrefix(e.Bytes, []byte("<?xml")) && len(e.Bytes) > 7 && whitespace[e.Bytes[5]] {
+			return w.ProcInst([]byte("xml"), trimSpace(e.Bytes[6:len(e.Bytes)-2]))
+		}
+		w.closeStart()
+		w.buf = append(w.buf, e.Bytes...)
+	default:
+		w.closeStart()
+		w.buf = append(w.buf, e.Bytes...)
+	}
+	return w.flushIfFull()
+}
+
+// WriteToken writes an encoding/xml token.
+// Name.Space is treated as a prefix, as produced by StartElement and EndElement.
+// An xml.EndElement closes the innermost open element regardless of its name.
+func (w *Writer) WriteToken(t xml.Token) error {
+	switch t := t.(type) {
+	case xml.StartElement:
+		if err := w.StartElement(tokenName(t.Name)); err != nil {
+			return err
+		}
+		for _, attr := range t.Attr {
+			if err := w.Attr(tokenName(attr.Name), []byte(attr.Value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case xml.EndElement:
+		return w.EndElement()
+	case xml.CharData:
+		return w.Text(t)
+	case xml.Comment:
+		return w.Comment(t)
+	case xml.ProcInst:
+		return w.ProcInst([]byte(t.Target), t.Inst)
+	case xml.Directive:
+		return w.Directive(t)
+	default:
+		return fmt.Errorf("gosax: unsupported token type %T", t)
+	}
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if w.Charset == UTF8 {
+		_, w.err = w.w.Write(w.buf)
+		w.buf = w.buf[:0]
+		return w.err
+	}
+	w.out = w.out[:0]
+	if !w.started {
+		w.out = append(w.out, w.Charset.bom()...)
+		w.started = true
+	}
+	w.out, w.err = w.Charset.encode(w.out, w.buf)
+	w.buf = w.buf[:0]
+	if w.err != nil {
+		return w.err
+	}
+	_, w.err = w.w.Write(w.out)
+	return w.err
+}
+
+// appendTag appends the start tag b, replacing characters the charset
+// cannot represent in attribute values with character references.
+func (w *Writer) appendTag(b []byte) {
+	if w.Charset.maxRune() == utf8.MaxRune {
+		w.buf = append(w.buf, b...)
+		return
+	}
+	for {
+		i := bytes.IndexAny(b, `"'`)
+		if i < 0 {
+			break
+		}
+		j := bytes.IndexByte(b[i+1:], b[i])
+		if j < 0 {
+			break
+		}
+		w.buf = append(w.buf, b[:i+1]...)
+		start := len(w.buf)
+		w.buf = append(w.buf, b[i+1:i+1+j]...)
+		w.buf = w.Charset.appendCharRefs(w.buf, start, "", "")
+		b = b[i+1+j:]
+	}
+	w.buf = append(w.buf, b...)
+}
+
+func (w *Writer) flushIfFull() error {
+	if len(w.buf) < 4096 {
+		return nil
+	}
+	return w.Flush()
+}
+
+func (w *Writer) closeStart() {
+	if w.open {
+		w.appendSortedAttrs(w.attrs, w.attrKeys)
+		w.attrs = w.attrs[:0]
+		w.attrKeys = w.attrKeys[:0]
+		w.buf = append(w.buf, '>')
+		w.open = false
+	}
+}
+
+// appendSortedAttrs appends the attributes held in b, sorted by name.
+func (w *Writer) appendSortedAttrs(b []byte, spans []attrSpan) {
+	slices.SortStableFunc(spans, func(x, y attrSpan) int {
+		return bytes.Compare(b[x.start+1:x.keyEnd], b[y.start+1:y.keyEnd])
+	})
+	for _, a := range spans {
+		w.buf = append(w.buf, b[a.start:a.end]...)
+	}
+}
+
+// appendSortedTag appends the start tag b with its attributes sorted by
+// name. Attribute values are kept as written.
+func (w *Writer) appendSortedTag(b []byte) {
+	name, rest := Name(b)
+	w.attrs = w.attrs[:0]
+	w.attrKeys = w.attrKeys[:0]
+	for len(rest) > 0 {
+		attr, next, err := NextAttribute(rest)
+		if err != nil || len(attr.Key) == 0 {
+			break
+		}
+		rest = next
+		span := attrSpan{start: len(w.attrs)}
+		w.attrs = append(w.attrs, ' ')
+		w.attrs = append(w.attrs, attr.Key...)
+		span.keyEnd = len(w.attrs)
+		w.attrs = append(w.attrs, '=')
+		w.attrs = append(w.attrs, attr.Value...)
+		span.end = len(w.attrs)
+		w.attrKeys = append(w.attrKeys, span)
+	}
+	start := len(w.buf)
+	w.buf = append(w.buf, '<')
+	w.buf = append(w.buf, name...)
+	w.appendSortedAttrs(w.attrs, w.attrKeys)
+	w.attrs = w.attrs[:0]
+	w.attrKeys = w.attrKeys[:0]
+	if isSelfClosing(b) {
+		w.buf = append(w.buf, '/')
+	}
+	w.buf = append(w.buf, '>')
+	if w.Charset.maxRune() != utf8.MaxRune {
+		tag := bytes.Clone(w.buf[start:])
+		w.buf = w.buf[:start]
+		w.appendTag(tag)
+	}
+}
+
+func (w *Writer) push(name []byte) {
+	w.names = append(w.names, name...)
+	w.ends = append(w.ends, len(w.names))
+}
+
+func (w *Writer) pop() []byte {
+	n := len(w.ends)
+	end := w.ends[n-1]
+	begin := 0
+	if n > 1 {
+		begin = w.ends[n-2]
+	}
+	w.ends = w.ends[:n-1]
+	name := w.names[begin:end]
+	w.names = w.names[:begin]
+	return name
+}
+
+func tokenName(n xml.Name) []byte {
+	if n.Space == "" {
+		return []byte(n.Local)
+	}
+	return []byte(n.Space + ":" + n.Local)
+}
+
+// Escaping is the escaping policy of a Writer for text and attribute values.
+type Escaping uint8
+
+const (
+	// EscapeDefault escapes <, > and & in text, and additionally " and
+	// literal tabs and line breaks in attribute values. Carriage returns
+	// are escaped everywhere so they survive line-end normalization.
+	EscapeDefault Escaping = iota
+	// EscapeMinimal escapes only <, & and, in attribute values, ".
+	// > is escaped only where it would end "]]>".
+	EscapeMinimal
+	// EscapeAggressive escapes like EscapeDefault, plus ' in attribute
+	// values and all non-ASCII characters as character references.
+	EscapeAggressive
+)
+
+var (
+	textEscapes = [256]string{
+		'<': "&lt;", '>': "&gt;", '&': "&amp;", '\r': "&#xD;",
+	}
+	attrEscapes = [256]string{
+		'<': "&lt;", '>': "&gt;", '&': "&amp;", '"': "&quot;",
+		'\t': "&#x9;", '\n': "&#xA;", '\r': "&#xD;",
+	}
+	minimalTextEscapes = [256]string{
+		'<': "&lt;", '&': "&amp;",
+	}
+	minimalAttrEscapes = [256]string{
+		'<': "&lt;", '&': "&amp;", '"': "&quot;",
+	}
+	aggressiveEscapes = [256]string{
+		'<': "&lt;", '>': "&gt;", '&': "&amp;", '"': "&quot;", '\'': "&apos;",
+		'\t': "&#x9;", '\n': "&#xA;", '\r': "&#xD;",
+	}
+)
+
+// appendText appends b escaped as character data.
+func (e Escaping) appendText(dst, b []byte) []byte {
+	switch e {
+	case EscapeMinimal:
+		start := len(dst)
+		dst = appendEscaped(dst, b, &minimalTextEscapes)
+		// Keep "]]>" out of character data, including across calls.
+		from := max(start-2, 0)
+		if bytes.Contains(dst[from:], []byte("]]>")) {
+			tail := bytes.ReplaceAll(dst[from:], []byte("]]>"), []byte("]]&gt;"))
+			dst = append(dst[:from], tail...)
+		}
+		return dst
+	case EscapeAggressive:
+		start := len(dst)
+		dst = appendEscaped(dst, b, &textEscapes)
+		return USASCII.appendCharRefs(dst, start, "", "")
+	}
+	return appendEscaped(dst, b, &textEscapes)
+}
+
+// appendAttr appends b escaped as an attribute value in double quotes.
+func (e Escaping) appendAttr(dst, b []byte) []byte {
+	switch e {
+	case EscapeMinimal:
+		return appendEscaped(dst, b, &minimalAttrEscapes)
+	case EscapeAggressive:
+		start := len(dst)
+		dst = appendEscaped(dst, b, &aggressiveEscapes)
+		return USASCII.appendCharRefs(dst, start, "", "")
+	}
+	return appendEscaped(dst, b, &attrEscapes)
+}
+
+// needsEscape reports whether b contains characters escaped in text.
+func needsEscape(b []byte) bool {
+	for _, c := range b {
+		if textEscapes[c] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func escapeText(dst, b []byte) []byte {
+	return appendEscaped(dst, b, &textEscapes)
+}
+
+func escapeAttr(dst, b []byte) []byte {
+	return appendEscaped(dst, b, &attrEscapes)
+}
+
+func appendEscaped(dst, b []byte, escapes *[256]string) []byte {
+	last := 0
+	for i, c := range b {
+		esc := escapes[c]
+		if esc == "" {
+			continue
+		}
+		dst = append(dst, b[last:i]...)
+		dst = append(dst, esc...)
+		last = i + 1
+	}
+	return append(dst, b[last:]...)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// StartElementNS opens an element named by a namespace URI and a local
+// name, declaring a prefix for the namespace unless one is in scope. The
+// prefix is taken from Prefixes; by default the element is put in the
+// default namespace. An empty space names an element in no namespace.
+func (w *Writer) StartElementNS(space, local string) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.closeStart()
+	w.ns.push()
+	prefix, declare := w.elementPrefix(space)
+	name := qualify(prefix, local)
+	w.buf = append(w.buf, '<')
+	w.buf = append(w.buf, name...)
+	w.push(name)
+	w.open = true
+	if declare {
+		return w.declare(prefix, space)
+	}
+	return w.flushIfFull()
+}
+
+// AttrNS adds an attribute named by a namespace URI and a local name to
+// the element opened by the last StartElement or StartElementNS,
+// declaring a prefix for the namespace unless one is in scope. The prefix
+// is taken from Prefixes, or generated. value is escaped.
+func (w *Writer) AttrNS(space, local string, value []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if !w.open {
+		return errors.New("gosax: AttrNS called outside of a start tag")
+	}
+	if space == "" {
+		return w.attr([]byte(local), value)
+	}
+	prefix, ok := w.boundPrefix(space)
+	if !ok {
+		prefix = w.newPrefix(space)
+		if err := w.declare(prefix, space); err != nil {
+			return err
+		}
+	}
+	return w.attr(qualify(prefix, local), value)
+}
+
+// elementPrefix returns the prefix of an element in the namespace space,
+// and whether it has to be declared.
+func (w *Writer) elementPrefix(space string) (string, bool) {
+	if p, ok := w.Prefixes[space]; ok && space != "" {
+		uri, _ := w.ns.lookup(p)
+		return p, uri != space
+	}
+	if uri, _ := w.ns.lookup(""); uri == space {
+		return "", false
+	}
+	if p, ok := w.boundPrefix(space); ok {
+		return p, false
+	}
+	return "", true
+}
+
+// boundPrefix returns a non-empty prefix bound to space in scope.
+func (w *Writer) boundPrefix(space string) (string, bool) {
+	if space == xmlURL {
+		return "xml", true
+	}
+	var prefix string
+	w.ns.visible(func(b nsBinding) {
+		if prefix == "" && b.prefix != "" && b.uri == space {
+			prefix = b.prefix
+		}
+	})
+	return prefix, prefix != ""
+}
+
+// newPrefix returns an unbound prefix for space: the one in Prefixes if it
+// is free, and otherwise a generated one.
+func (w *Writer) newPrefix(space string) string {
+	if p, ok := w.Prefixes[space]; ok && p != "" {
+		if _, bound := w.ns.lookup(p); !bound {
+			return p
+		}
+	}
+	for {
+		w.nsSeq++
+		p := "ns" + strconv.Itoa(w.nsSeq)
+		if _, bound := w.ns.lookup(p); !bound {
+			return p
+		}
+	}
+}
+
+// declare writes and records the declaration of prefix for space.
+func (w *Writer) declare(prefix, space string) error {
+	w.ns.declare(prefix, space)
+	key := []byte("xmlns")
+	if prefix != "" {
+		key = append(key, ':')
+		key = append(key, prefix...)
+	}
+	return w.attr(key, []byte(space))
+}
+
+// declareNamespaces pushes a namespace frame with the declarations of the
+// start tag b.
+func (w *Writer) declareNamespaces(b []byte) {
+	w.ns.push()
+	_, attrs := Name(b)
+	if !bytes.Contains(attrs, []byte("xmlns")) {
+		return
+	}
+	for len(attrs) > 0 {
+		attr, rest, err := NextAttribute(attrs)
+		if err != nil || len(attr.Key) == 0 {
+			return
+		}
+		attrs = rest
+		if prefix, ok := nsDecl(attr.Key); ok && len(attr.Value) >= 2 {
+			if uri, err := UnescapeAttr(bytes.Clone(attr.Value[1 : len(attr.Value)-1])); err == nil {
+				w.ns.declare(prefix, string(uri))
+			}
+		}
+	}
+}
+
+// qualify returns the qualified name of local with prefix.
+func qualify(prefix, local string) []byte {
+	if prefix == "" {
+		return []byte(local)
+	}
+	return []byte(prefix + ":" + local)
+}
@@ -0,0 +1,404 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+)
+
+const xincludeURL = "http://www.w3.org/2001/XInclude"
+
+// XInclude is an EventReader that performs XInclude processing: each
+// xi:include element is replaced by the events of the resource it
+// references, or by the content of its xi:fallback child when the resource
+// cannot be opened.
+//
+// Both parse="xml" and parse="text" are supported. Included documents are
+// read with a Reader, which inherits EmitSelfClosingTag when the underlying
+// EventReader is a Reader, and are processed recursively. An inclusion loop
+// is an error. The xpointer attribute and xml:base/xml:lang fixup are not
+// supported.
+type XInclude struct {
+	// Base is the URI of the document read by the underlying EventReader.
+	// Relative hrefs are resolved against it.
+	Base string
+
+	open   func(href string) (io.ReadCloser, error)
+	frames []*xincludeFrame
+	text   []byte
+}
+
+type xincludeFrame struct {
+	r        EventReader
+	c        io.Closer
+	href     string
+	scope    *nsScope
+	depth    int
+	pending  *Event
+	popScope bool
+}
+
+// NewXInclude returns an XInclude reading events from r.
+// open is called with the resolved href of each included resource.
+func NewXInclude(r EventReader, open func(href string) (io.ReadCloser, error)) *XInclude {
+	return &XInclude{
+		open:   open,
+		frames: []*xincludeFrame{{r: r, scope: &nsScope{}}},
+	}
+}
+
+// Event returns the next event of the document with inclusions applied.
+func (x *XInclude) Event() (Event, error) {
+	for {
+		f := x.frames[len(x.frames)-1]
+		ev, err := f.next()
+		if err != nil {
+			return ev, err
+		}
+		switch ev.Type() {
+		case EventEOF:
+			if len(x.frames) == 1 {
+				return ev, nil
+			}
+			if err := x.pop(); err != nil {
+				return Event{}, err
+			}
+			continue
+		case EventProcessingInstruction, EventXMLDecl, EventDocType, EventText:
+			// The prolog of an included document is not part of its content.
+			if f.c != nil && f.depth == 0 && (ev.Type() != EventProcessingInstruction || isXMLDecl(ev.Bytes)) {
+				continue
+			}
+		case EventStart:
+			selfClosing := isSelfClosing(ev.Bytes)
+			f.scope.push()
+			if err := declareNamespaces(f.scope, ev.Bytes); err != nil {
+				return Event{}, err
+			}
+			if isXInclude(f.scope, ev.Bytes, "include") {
+				if err := x.include(f, ev, selfClosing); err != nil {
+					return Event{}, err
+				}
+				continue
+			}
+			if selfClosing {
+				f.scope.pop()
+			} else {
+				f.depth++
+			}
+		case EventEnd:
+			if !isSelfClosing(ev.Bytes) {
+				f.scope.pop()
+				f.depth--
+			}
+		}
+		return ev, nil
+	}
+}
+
+// include processes the xi:include element start, which has been pushed
+// onto the scope of f.
+func (x *XInclude) include(f *xincludeFrame, start Event, selfClosing bool) error {
+	var href, parse string
+	hasHref := false
+	rest := start.Bytes
+	_, rest = Name(rest)
+	for len(rest) > 0 {
+		attr, r, err := NextAttribute(rest)
+		if err != nil {
+			return err
+		}
+		rest = r
+		if len(attr.Key) == 0 {
+			break
+		}
+		switch string(attr.Key) {
+		case "href", "parse", "xpointer":
+			v, err := attrValue(attr.Value)
+			if err != nil {
+				return err
+			}
+			switch string(attr.Key) {
+			case "href":
+				href, hasHref = v, true
+			case "parse":
+				parse = v
+			case "xpointer":
+				return fmt.Errorf("gosax: xi:include xpointer is not supported")
+			}
+		}
+	}
+	if !hasHref || href == "" {
+		return fmt.Errorf("gosax: xi:include without href")
+	}
+	if parse != "" && parse != "xml" && parse != "text" {
+		return fmt.Errorf("gosax: xi:include has invalid parse attribute %q", parse)
+	}
+
+	fallback, err := x.readFallback(f, selfClosing)
+	if err != nil {
+		return err
+	}
+
+	target, err := x.resolve(f, href)
+	if err != nil {
+		return err
+	}
+	if parse != "text" {
+		if target == x.Base {
+			return fmt.Errorf("gosax: xi:include loop on %q", target)
+		}
+		for _, fr := range x.frames {
+			if fr.href == target && fr.c != nil {
+				return fmt.Errorf("gosax: xi:include loop on %q", target)
+			}
+		}
+	}
+	rc, err := x.open(target)
+	if err == nil && parse == "text" {
+		var b []byte
+		b, err = io.ReadAll(rc)
+		rc.Close()
+		if err == nil {
+			x.text = escapeText(x.text[:0], b)
+			f.scope.pop()
+			x.frames = append(x.frames, &xincludeFrame{
+				r:     &eventSlice{events: []Event{{Bytes: x.text, value: uint64(EventText)}}},
+				href:  f.href,
+				scope: f.scope,
+			})
+			return nil
+		}
+	}
+	if err != nil {
+		if fallback == nil {
+			f.scope.pop()
+			return fmt.Errorf("gosax: xi:include %q: %w", target, err)
+		}
+		// The fallback keeps the scope of the xi:include element.
+		x.frames = append(x.frames, &xincludeFrame{
+			r:        &eventSlice{events: fallback},
+			href:     f.href,
+			scope:    f.scope,
+			depth:    f.depth + 1,
+			popScope: true,
+		})
+		return nil
+	}
+	f.scope.pop()
+	ir := NewReader(rc)
+	if root, ok := x.frames[0].r.(*Reader); ok {
+		ir.EmitSelfClosingTag = root.EmitSelfClosingTag
+	}
+	x.frames = append(x.frames, &xincludeFrame{
+		r:     ir,
+		c:     rc,
+		href:  target,
+		scope: &nsScope{},
+	})
+	return nil
+}
+
+// readFallback consumes the content of an xi:include element and returns a
+// copy of the content of its xi:fallback child, or nil if there is none.
+func (x *XInclude) readFallback(f *xincludeFrame, selfClosing bool) ([]Event, error) {
+	if selfClosing {
+		// Consume the end event a Reader with EmitSelfClosingTag reports.
+		ev, err := f.next()
+		if err != nil {
+			return nil, err
+		}
+		if ev.Type() != EventEnd || !isSelfClosing(ev.Bytes) {
+			f.pending = &ev
+		}
+		return nil, nil
+	}
+	var fallback []Event
+	depth := 0
+	inFallback := false
+	for {
+		ev, err := f.next()
+		if err != nil {
+			return nil, err
+		}
+		switch ev.Type() {
+		case EventEOF:
+			return nil, io.ErrUnexpectedEOF
+		case EventStart:
+			if depth == 0 && !inFallback {
+				f.scope.push()
+				if err := declareNamespaces(f.scope, ev.Bytes); err != nil {
+					return nil, err
+				}
+				isFallback := isXInclude(f.scope, ev.Bytes, "fallback")
+				f.scope.pop()
+				if isFallback {
+					if fallback != nil {
+						return nil, fmt.Errorf("gosax: xi:include has more than one xi:fallback")
+					}
+					fallback = []Event{}
+					if !isSelfClosing(ev.Bytes) {
+						inFallback = true
+						depth++
+					}
+					continue
+				}
+			}
+			if !isSelfClosing(ev.Bytes) {
+				depth++
+			}
+		case EventEnd:
+			if isSelfClosing(ev.Bytes) {
+				if depth == 0 {
+					continue
+				}
+				break
+			}
+			if depth == 0 {
+				return fallback, nil
+			}
+			depth--
+			if depth == 0 && inFallback {
+				inFallback = false
+				continue
+			}
+		}
+		if inFallback {
+			fallback = append(fallback, Event{Bytes: bytes.Clone(ev.Bytes), value: ev.value})
+		}
+	}
+}
+
+func (x *XInclude) resolve(f *xincludeFrame, href string) (string, error) {
+	base := f.href
+	if base == "" {
+		base = x.Base
+	}
+	if base == "" {
+		return href, nil
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	h, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	if b.Scheme == "" && b.Host == "" && !path.IsAbs(b.Path) && !h.IsAbs() && !path.IsAbs(h.Path) {
+		// A relative base is a file path; keep the result relative.
+		return path.Join(path.Dir(b.Path), h.Path), nil
+	}
+	return b.ResolveReference(h).String(), nil
+}
+
+func (x *XInclude) pop() error {
+	f := x.frames[len(x.frames)-1]
+	x.frames = x.frames[:len(x.frames)-1]
+	if f.popScope {
+		f.scope.pop()
+	}
+	if f.c != nil {
+		return f.c.Close()
+	}
+	return nil
+}
+
+func (f *xincludeFrame) next() (Event, error) {
+	if f.pending != nil {
+		ev := *f.pending
+		f.pending = nil
+		return ev, nil
+	}
+	return f.r.Event()
+}
+
+// isXInclude reports whether the start tag b is the XInclude element local.
+func isXInclude(s *nsScope, b []byte, local string) bool {
+	name, _ := Name(b)
+	prefix, l := splitQName(name)
+	if string(l) != local {
+		return false
+	}
+	uri, _ := s.lookup(string(prefix))
+	return uri == xincludeURL
+}
+
+// declareNamespaces declares the namespaces of the start tag b in s.
+func declareNamespaces(s *nsScope, b []byte) error {
+	_, rest := Name(b)
+	for len(rest) > 0 {
+		attr, r, err := NextAttribute(rest)
+		if err != nil {
+			return err
+		}
+		rest = r
+		if len(attr.Key) == 0 {
+			break
+		}
+		if prefix, ok := nsDecl(attr.Key); ok {
+			v, err := attrValue(attr.Value)
+			if err != nil {
+				return err
+			}
+			s.declare(prefix, v)
+		}
+	}
+	return nil
+}
+
+// attrValue returns the normalized value of a quoted attribute value.
+func attrValue(b []byte) (string, error) {
+	if len(b) < 2 {
+		return "", fmt.Errorf("gosax: invalid attribute value: %q", b)
+	}
+	v, err := UnescapeAttr(bytes.Clone(b[1 : len(b)-1]))
+	return string(v), err
+}
+
+func isXMLDecl(b []byte) bool {
+	return len(b) > 6 && string(b[:5]) == "<?xml" && whitespace[b[5]]
+}
+
+// eventSlice is an EventReader over a slice of events.
+type eventSlice struct {
+	events []Event
+	i      int
+}
+
+func (s *eventSlice) Event() (Event, error) {
+	if s.i == len(s.events) {
+		return Event{value: uint64(EventEOF)}, nil
+	}
+	ev := s.events[s.i]
+	s.i++
+	return ev, nil
+}
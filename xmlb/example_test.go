@@ -29,8 +29,10 @@ package xmlb_test
 import (
 	"fmt"
 	"io"
+	"log"
 	"strings"
 
+	"github.com/orisano/gosax"
 	"github.com/orisano/gosax/xmlb"
 )
 
@@ -63,3 +65,130 @@ func Example() {
 	// EndElement element
 	// EndElement root
 }
+
+func ExampleDecoder_Attr() {
+	r := strings.NewReader(`<trkpt lat="35.6" lon="139.7" name="Tokyo &amp; Yokohama"/>`)
+	d := xmlb.NewDecoder(r, make([]byte, 64*1024))
+	tok, err := d.Token()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if tok.Type() == xmlb.StartElement {
+		for i := 0; i < 2; i++ {
+			name, err := d.Attr("name")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(name))
+		}
+		lat, _ := d.Attr("lat")
+		fmt.Println(string(lat))
+		if _, err := d.Attr("ele"); err == xmlb.ErrNoAttributes {
+			fmt.Println("no ele")
+		}
+	}
+	// Output:
+	// Tokyo & Yokohama
+	// Tokyo & Yokohama
+	// 35.6
+	// no ele
+}
+
+func ExampleDecoder_Attr_startElement() {
+	r := strings.NewReader(`<e a="x&amp;y" b="1"/>`)
+	d := xmlb.NewDecoder(r, make([]byte, 64*1024))
+	tok, err := d.Token()
+	if err != nil {
+		log.Fatal(err)
+	}
+	a, err := d.Attr("a")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(a))
+	// Attr leaves the token as read.
+	se, err := tok.StartElement()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, attr := range se.Attr {
+		fmt.Println(attr.Name.Local, attr.Value)
+	}
+	// Output:
+	// x&y
+	// a x&y
+	// b 1
+}
+
+func ExampleNewDecoderReader() {
+	r := gosax.NewReader(strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom"><entry/></feed><feed/>`))
+	r.TrackNamespaces = true
+	r.CheckWellFormed = true
+	d := xmlb.NewDecoderReader(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		if tok.Type() == xmlb.StartElement {
+			qname, _ := gosax.Name(tok.Bytes)
+			space, local := d.Reader().ResolveName(qname)
+			fmt.Printf("{%s}%s\n", space, local)
+		}
+	}
+	// Output:
+	// {http://www.w3.org/2005/Atom}feed
+	// {http://www.w3.org/2005/Atom}entry
+	// gosax: second root element <feed>
+}
+
+func ExampleToken_CharData() {
+	r := gosax.NewReader(strings.NewReader(`<p>&amp;lt;</p>`))
+	r.UnescapeText = true
+	d := xmlb.NewDecoderReader(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if tok.Type() == xmlb.CharData {
+			text, err := tok.CharData()
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(text))
+		}
+	}
+	// Output:
+	// &lt;
+}
+
+func ExampleToken_CharData_cdata() {
+	r := strings.NewReader(`<p><![CDATA[<b>&amp;</b>]]></p>`)
+	d := xmlb.NewDecoder(r, make([]byte, 64*1024))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if tok.Type() == xmlb.CharData {
+			text, err := tok.CharData()
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(text))
+		}
+	}
+	// Output:
+	// <b>&amp;</b>
+}
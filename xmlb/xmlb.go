@@ -52,16 +52,44 @@ const (
 
 type Decoder struct {
 	r *gosax.Reader
+
+	last gosax.Event
+	// attrs caches the attributes of last, parsed by the first call to Attr.
+	attrs       []cachedAttr
+	attrsParsed bool
+	// values holds the values unescaped by Attr, leaving last as read.
+	values []byte
+}
+
+type cachedAttr struct {
+	key     []byte
+	value   []byte
+	decoded bool
 }
 
 func NewDecoder(r io.Reader, buf []byte) *Decoder {
-	gr := gosax.NewReaderBuf(r, buf)
-	gr.EmitSelfClosingTag = true
-	return &Decoder{gr}
+	return NewDecoderReader(gosax.NewReaderBuf(r, buf))
+}
+
+// NewDecoderReader returns a Decoder reading tokens from r, whose options,
+// such as TrackNamespaces or CheckWellFormed, can be set before the first
+// call to Token. It sets r.EmitSelfClosingTag, since an empty element is
+// reported as a StartElement and an EndElement token.
+func NewDecoderReader(r *gosax.Reader) *Decoder {
+	r.EmitSelfClosingTag = true
+	return &Decoder{r: r}
+}
+
+// Reader returns the Reader the Decoder reads from.
+func (d *Decoder) Reader() *gosax.Reader {
+	return d.r
 }
 
 func (d *Decoder) Token() (Token, error) {
 	ev, err := d.r.Event()
+	d.last = ev
+	d.attrsParsed = false
+	d.values = d.values[:0]
 	if err == nil && ev.Type() == gosax.EventEOF {
 		err = io.EOF
 	}
@@ -72,9 +100,69 @@ func (d *Decoder) Token() (Token, error) {
 }
 
 func (d *Decoder) Skip() error {
+	d.last = gosax.Event{}
+	d.attrsParsed = false
+	d.values = d.values[:0]
 	return gosax.Skip(d.r)
 }
 
+// Attr returns the unescaped value of the named attribute of the last
+// StartElement token. Values are unescaped once and cached until the next
+// call to Token, so repeated lookups of the same attribute are cheap.
+//
+// Unlike AttributesBytes.Get, which unescapes in place, Attr unescapes
+// into a buffer of the Decoder, so the token is left as read. The value
+// is valid until the next call to Token.
+func (d *Decoder) Attr(key string) ([]byte, error) {
+	if !d.attrsParsed {
+		if err := d.parseAttrs(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range d.attrs {
+		a := &d.attrs[i]
+		if string(a.key) != key {
+			continue
+		}
+		if !a.decoded {
+			start := len(d.values)
+			d.values = append(d.values, a.value...)
+			v, err := gosax.UnescapeAttr(d.values[start:])
+			if err != nil {
+				d.values = d.values[:start]
+				return nil, err
+			}
+			d.values = d.values[:start+len(v)]
+			a.value = v
+			a.decoded = true
+		}
+		return a.value, nil
+	}
+	return nil, ErrNoAttributes
+}
+
+func (d *Decoder) parseAttrs() error {
+	d.attrs = d.attrs[:0]
+	if d.last.Type() != gosax.EventStart {
+		d.attrsParsed = true
+		return nil
+	}
+	_, b := gosax.Name(d.last.Bytes)
+	for len(b) > 0 {
+		attr, rest, err := gosax.NextAttribute(b)
+		if err != nil {
+			return err
+		}
+		b = rest
+		if len(attr.Value) < 2 {
+			continue
+		}
+		d.attrs = append(d.attrs, cachedAttr{key: attr.Key, value: attr.Value[1 : len(attr.Value)-1]})
+	}
+	d.attrsParsed = true
+	return nil
+}
+
 type Token gosax.Event
 
 func (t Token) Type() uint8 {
@@ -83,11 +171,11 @@ func (t Token) Type() uint8 {
 		return StartElement
 	case gosax.EventEnd:
 		return EndElement
-	case gosax.EventText:
+	case gosax.EventText, gosax.EventEntityRef:
 		return CharData
 	case gosax.EventCData:
 		return CharData
-	case gosax.EventProcessingInstruction:
+	case gosax.EventProcessingInstruction, gosax.EventXMLDecl:
 		return ProcInst
 	case gosax.EventComment:
 		return Comment
@@ -131,7 +219,7 @@ func (a AttributesBytes) Get(key string) ([]byte, error) {
 		if string(attr.Key) != key {
 			continue
 		}
-		v, err := gosax.Unescape(attr.Value[1 : len(attr.Value)-1])
+		v, err := gosax.UnescapeAttr(attr.Value[1 : len(attr.Value)-1])
 		if err != nil {
 			return nil, err
 		}
@@ -158,6 +246,12 @@ func (t Token) StartElement() (xml.StartElement, error) {
 	return gosax.StartElement(t.Bytes)
 }
 
+// StartElementInterner is like StartElement, but converts the names and
+// attribute values with in.
+func (t Token) StartElementInterner(in gosax.Interner) (xml.StartElement, error) {
+	return gosax.StartElementInterner(t.Bytes, in)
+}
+
 func (t Token) StartElementBytes() StartElementBytes {
 	name, attrs := gosax.Name(t.Bytes)
 	p := bytes.IndexByte(name, ':')
@@ -173,9 +267,17 @@ func (t Token) EndElement() xml.EndElement {
 	return gosax.EndElement(t.Bytes)
 }
 
+// EndElementInterner is like EndElement, but converts the name with in.
+func (t Token) EndElementInterner(in gosax.Interner) xml.EndElement {
+	return gosax.EndElementInterner(t.Bytes, in)
+}
+
 func (t Token) CharData() (xml.CharData, error) {
-	switch t.Type() {
-	case gosax.EventText:
+	switch gosax.Event(t).Type() {
+	case gosax.EventText, gosax.EventEntityRef:
+		if gosax.Event(t).Unescaped() {
+			return t.Bytes, nil
+		}
 		return gosax.CharData(t.Bytes)
 	case gosax.EventCData:
 		return bytes.TrimSuffix(bytes.TrimPrefix(t.Bytes, []byte("<![CDATA[")), []byte("]]>")), nil
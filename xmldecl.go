@@ -0,0 +1,156 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package gosax
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// xmlDecl holds the byte order mark and XML declaration of a document.
+type xmlDecl struct {
+	version  string
+	encoding string
+	// standalone is 1 for "yes", -1 for "no" and 0 if not declared.
+	standalone int8
+	bom        bool
+}
+
+// prolog records the byte order mark and XML declaration at the start of
+// the input, from the first events, and returns ev with the type set by
+// EmitXMLDecl.
+func (r *Reader) prolog(ev Event) Event {
+	switch ev.Type() {
+	case EventText:
+		if r.seq == 0 && bytes.HasPrefix(ev.Bytes, utf8BOM) {
+			r.decl.bom = true
+		}
+	case EventProcessingInstruction:
+		if r.seq == 0 || r.decl.bom {
+			d, ok := parseXMLDecl(ev.Bytes)
+			if ok {
+				d.bom = r.decl.bom
+				r.decl = d
+				if r.EmitXMLDecl {
+					ev.value = uint64(EventXMLDecl)
+				}
+			}
+		}
+	}
+	return ev
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Encoding returns the encoding declared by the XML declaration, as
+// written. If none was declared, it returns "UTF-8" when the input starts
+// with a UTF-8 byte order mark, and "" otherwise. It is known once the
+// prolog has been read.
+func (r *Reader) Encoding() string {
+	if r.decl.encoding == "" && r.decl.bom {
+		return "UTF-8"
+	}
+	return r.decl.encoding
+}
+
+// XMLVersion returns the version declared by the XML declaration, such as
+// "1.0", or "" if the input has no XML declaration.
+func (r *Reader) XMLVersion() string {
+	return r.decl.version
+}
+
+// Standalone returns the standalone document declaration; ok is false if
+// the XML declaration does not have one.
+func (r *Reader) Standalone() (standalone, ok bool) {
+	return r.decl.standalone > 0, r.decl.standalone != 0
+}
+
+// HasBOM reports whether the input starts with a UTF-8 byte order mark,
+// which Event reports as text.
+func (r *Reader) HasBOM() bool {
+	return r.decl.bom
+}
+
+// parseXMLDecl parses the XML declaration b.
+func parseXMLDecl(b []byte) (xmlDecl, bool) {
+	var d xmlDecl
+	b = trim(b, "<?", "?>")
+	if len(b) < 4 || string(b[:3]) != "xml" || !whitespace[b[3]] {
+		return d, false
+	}
+	b = b[4:]
+	for {
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) == 0 {
+			return d, d.version != ""
+		}
+		attr, rest, err := NextAttribute(b)
+		if err != nil || len(attr.Key) == 0 || len(attr.Value) < 2 {
+			return d, false
+		}
+		b = rest
+		v := string(attr.Value[1 : len(attr.Value)-1])
+		switch string(attr.Key) {
+		case "version":
+			d.version = v
+		case "encoding":
+			d.encoding = v
+		case "standalone":
+			switch v {
+			case "yes":
+				d.standalone = 1
+			case "no":
+				d.standalone = -1
+			}
+		}
+	}
+}
+
+// XMLDecl holds the fields of an XML declaration, as written.
+type XMLDecl struct {
+	Version  string
+	Encoding string
+	// Standalone is "yes", "no" or "" if not declared.
+	Standalone string
+}
+
+// ParseXMLDecl parses the XML declaration b, such as the bytes of an
+// EventXMLDecl event.
+func ParseXMLDecl(b []byte) (XMLDecl, error) {
+	d, ok := parseXMLDecl(b)
+	if !ok {
+		return XMLDecl{}, fmt.Errorf("gosax: invalid XML declaration %q", b)
+	}
+	x := XMLDecl{Version: d.version, Encoding: d.encoding}
+	switch d.standalone {
+	case 1:
+		x.Standalone = "yes"
+	case -1:
+		x.Standalone = "no"
+	}
+	return x, nil
+}
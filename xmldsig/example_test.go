@@ -0,0 +1,178 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package xmldsig_test
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/orisano/gosax/xmldsig"
+)
+
+// envelopedDoc is signed with an enveloped signature over the whole
+// document, with the HMAC key "secret".
+const envelopedDoc = `<root xmlns="urn:r"><item ID="a1">hello &amp; bye</item>` +
+	`<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` +
+	`<ds:SignedInfo>` +
+	`<ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/>` +
+	`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#hmac-sha256"/>` +
+	`<ds:Reference URI="">` +
+	`<ds:Transforms>` +
+	`<ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/>` +
+	`<ds:Transform Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/>` +
+	`</ds:Transforms>` +
+	`<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/>` +
+	`<ds:DigestValue>aIHtPrsm9AXTHKlOcdlqAJsD96C2H/NtgA6GqnDedWw=</ds:DigestValue>` +
+	`</ds:Reference>` +
+	`</ds:SignedInfo>` +
+	`<ds:SignatureValue>mjtgCrMybLgkOI1K4jytNFlCsNrBq7R48s8fYcRIBd0=</ds:SignatureValue>` +
+	`</ds:Signature></root>`
+
+// idDoc is signed with a signature over the element with ID "a1", with the
+// HMAC key "secret".
+const idDoc = `<root xmlns="urn:r"><item ID="a1">hello</item><item ID="a2">forged</item>` +
+	`<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` +
+	`<ds:SignedInfo>` +
+	`<ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/>` +
+	`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#hmac-sha256"/>` +
+	`<ds:Reference URI="#a1">` +
+	`<ds:Transforms><ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/></ds:Transforms>` +
+	`<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/>` +
+	`<ds:DigestValue><![CDATA[jzB0GG/d43el3JHWpdk76Euc7FWmh0KQc6YuvmCFHV0=]]></ds:DigestValue>` +
+	`</ds:Reference>` +
+	`<x:Reference xmlns:x="urn:x" URI="#a2"/>` +
+	`</ds:SignedInfo>` +
+	`<ds:SignatureValue>ZWHV85GaUpj5td7euqMQKHdC9N33SDbV9E8uuR37+RU=</ds:SignatureValue>` +
+	`</ds:Signature></root>`
+
+// hmacVerifier verifies envelopedDoc and idDoc.
+var hmacVerifier = &xmldsig.Verifier{
+	Key: func(*xmldsig.KeyInfo) (crypto.PublicKey, error) {
+		return []byte("secret"), nil
+	},
+}
+
+func ExampleVerifier_Verify() {
+	v := &xmldsig.Verifier{
+		Key: func(*xmldsig.KeyInfo) (crypto.PublicKey, error) {
+			return []byte("secret"), nil
+		},
+	}
+	sig, err := v.Verify([]byte(envelopedDoc))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, ref := range sig.References {
+		fmt.Printf("%q %s\n", ref.URI, ref.DigestMethod)
+		fmt.Printf("%s\n", ref.Data)
+	}
+	// Output:
+	// "" http://www.w3.org/2001/04/xmlenc#sha256
+	// <root xmlns="urn:r"><item ID="a1">hello &amp; bye</item></root>
+}
+
+func ExampleVerifier_Verify_id() {
+	sig, err := hmacVerifier.Verify([]byte(idDoc))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, ref := range sig.References {
+		fmt.Printf("%q %s\n", ref.URI, ref.Data)
+	}
+	// Output:
+	// "#a1" <item xmlns="urn:r" ID="a1">hello</item>
+}
+
+func ExampleVerifier_Verify_tamperedDigest() {
+	doc := strings.Replace(envelopedDoc, "hello", "HELLO", 1)
+	_, err := hmacVerifier.Verify([]byte(doc))
+	fmt.Println(errors.Is(err, xmldsig.ErrDigestMismatch))
+	fmt.Println(err)
+	// Output:
+	// true
+	// xmldsig: digest mismatch for reference ""
+}
+
+func ExampleVerifier_Verify_tamperedSignedInfo() {
+	// The SignedInfo now refers to the forged element, but the signature
+	// value is over the original.
+	doc := strings.Replace(idDoc, `URI="#a1"`, `URI="#a2"`, 1)
+	_, err := hmacVerifier.Verify([]byte(doc))
+	fmt.Println(errors.Is(err, xmldsig.ErrInvalidSignature))
+	// Output:
+	// true
+}
+
+func ExampleVerifier_Verify_wrongKey() {
+	v := &xmldsig.Verifier{
+		Key: func(*xmldsig.KeyInfo) (crypto.PublicKey, error) {
+			return []byte("guess"), nil
+		},
+	}
+	_, err := v.Verify([]byte(envelopedDoc))
+	fmt.Println(errors.Is(err, xmldsig.ErrInvalidSignature))
+	// Output:
+	// true
+}
+
+func ExampleVerifier_Verify_duplicateID() {
+	// The forged element claims the ID of the signed one.
+	doc := strings.Replace(idDoc, `ID="a2"`, `ID="a1"`, 1)
+	_, err := hmacVerifier.Verify([]byte(doc))
+	fmt.Println(err)
+	// Output:
+	// xmldsig: duplicate ID "a1"
+}
+
+func ExampleVerifier_Verify_wrapped() {
+	// The signed element is moved into a wrapper the application ignores,
+	// and a forged element takes its place. The signature still verifies,
+	// so the application must use the Data of the references, which is
+	// what was signed, rather than look the elements up again.
+	doc := strings.Replace(idDoc,
+		`<item ID="a1">hello</item><item ID="a2">forged</item>`,
+		`<item ID="a2">forged</item><wrapper><item ID="a1">hello</item></wrapper>`, 1)
+	sig, err := hmacVerifier.Verify([]byte(doc))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, ref := range sig.References {
+		fmt.Printf("%q %s\n", ref.URI, ref.Data)
+	}
+	// Output:
+	// "#a1" <item xmlns="urn:r" ID="a1">hello</item>
+}
+
+func ExampleVerifier_Verify_noSignature() {
+	_, err := hmacVerifier.Verify([]byte(`<root><item ID="a1">hello</item></root>`))
+	fmt.Println(errors.Is(err, xmldsig.ErrNoSignature))
+	// Output:
+	// true
+}
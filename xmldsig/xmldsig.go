@@ -0,0 +1,742 @@
+/*
+Copyright (c) 2024, Nao Yonashiro
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package xmldsig verifies enveloped XML Signatures.
+//
+// It builds on the streaming canonicalization of gosax. References to the
+// whole document (URI="") and to elements by ID (URI="#id") are supported,
+// together with the enveloped-signature and canonicalization transforms.
+// Key material is supplied by the caller through Verifier.Key.
+//
+// A valid signature only vouches for the referenced data: callers should
+// consume Reference.Data, the data that was verified, rather than look the
+// referenced elements up in the document again, which invites signature
+// wrapping attacks.
+package xmldsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/orisano/gosax"
+)
+
+// Namespace is the XML Signature namespace.
+const Namespace = "http://www.w3.org/2000/09/xmldsig#"
+
+const envelopedSignature = Namespace + "enveloped-signature"
+
+// excC14NNamespace is the namespace of the InclusiveNamespaces element.
+const excC14NNamespace = "http://www.w3.org/2001/10/xml-exc-c14n#"
+
+var digestMethods = map[string]crypto.Hash{
+	Namespace + "sha1":                              crypto.SHA1,
+	"http://www.w3.org/2001/04/xmlenc#sha256":       crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmlenc#sha512":       crypto.SHA512,
+}
+
+type signatureMethod struct {
+	hash crypto.Hash
+	kind string
+}
+
+var signatureMethods = map[string]signatureMethod{
+	Namespace + "rsa-sha1":                                 {crypto.SHA1, "rsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256":    {crypto.SHA256, "rsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha384":    {crypto.SHA384, "rsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":    {crypto.SHA512, "rsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha1":    {crypto.SHA1, "ecdsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256":  {crypto.SHA256, "ecdsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384":  {crypto.SHA384, "ecdsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512":  {crypto.SHA512, "ecdsa"},
+	"http://www.w3.org/2021/04/xmldsig-more#eddsa-ed25519": {0, "ed25519"},
+	Namespace + "hmac-sha1":                                {crypto.SHA1, "hmac"},
+	"http://www.w3.org/2001/04/xmldsig-more#hmac-sha256":   {crypto.SHA256, "hmac"},
+	"http://www.w3.org/2001/04/xmldsig-more#hmac-sha384":   {crypto.SHA384, "hmac"},
+	"http://www.w3.org/2001/04/xmldsig-more#hmac-sha512":   {crypto.SHA512, "hmac"},
+}
+
+var (
+	ErrNoSignature      = errors.New("xmldsig: no signature")
+	ErrDigestMismatch   = errors.New("xmldsig: digest mismatch")
+	ErrInvalidSignature = errors.New("xmldsig: invalid signature")
+)
+
+// KeyInfo is the key material carried by a signature.
+type KeyInfo struct {
+	// Raw is the KeyInfo element as it appears in the document, or nil.
+	Raw []byte
+	// Certificates are the X509Certificate values of the KeyInfo.
+	// They are not validated in any way.
+	Certificates []*x509.Certificate
+}
+
+// Verifier verifies enveloped XML Signatures.
+type Verifier struct {
+	// Key returns the key to check the signature with: an *rsa.PublicKey,
+	// *ecdsa.PublicKey, ed25519.PublicKey, or a []byte for HMAC methods.
+	// Certificates found in info must be validated before they are trusted.
+	Key func(info *KeyInfo) (crypto.PublicKey, error)
+
+	// IDAttributes lists the attributes that identify elements referenced
+	// as "#id". The default is ID, Id and id.
+	IDAttributes []string
+}
+
+// Signature describes a verified signature.
+type Signature struct {
+	CanonicalizationMethod string
+	SignatureMethod        string
+	References             []Reference
+	KeyInfo                *KeyInfo
+}
+
+// Reference is a verified reference of a signature.
+type Reference struct {
+	URI          string
+	Transforms   []string
+	DigestMethod string
+	// Data is the canonical form of the referenced data, over which the
+	// digest was verified.
+	Data []byte
+}
+
+// Verify verifies the first signature in doc.
+// It checks the signature value over the canonicalized SignedInfo and the
+// digest of every reference.
+func (v *Verifier) Verify(doc []byte) (*Signature, error) {
+	raw, ctx, err := findSignature(doc)
+	if err != nil {
+		return nil, err
+	}
+	si, err := parseSignature(raw, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	method, ok := signatureMethods[si.method]
+	if !ok {
+		return nil, fmt.Errorf("xmldsig: unsupported signature method %q", si.method)
+	}
+	mode, ok := gosax.ModeFromURI(si.c14n)
+	if !ok {
+		return nil, fmt.Errorf("xmldsig: unsupported canonicalization method %q", si.c14n)
+	}
+	var signed bytes.Buffer
+	if err := canonicalize(&signed, si.signedInfo, ctx, mode, si.c14nPrefixes); err != nil {
+		return nil, err
+	}
+	if v.Key == nil {
+		return nil, errors.New("xmldsig: no Key function")
+	}
+	key, err := v.Key(si.keyInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(method, key, signed.Bytes(), si.value); err != nil {
+		return nil, err
+	}
+
+	sig := &Signature{
+		CanonicalizationMethod: si.c14n,
+		SignatureMethod:        si.method,
+		KeyInfo:                si.keyInfo,
+	}
+	for i := range si.refs {
+		ref := &si.refs[i]
+		data, err := v.verifyReference(doc, ref)
+		if err != nil {
+			return nil, err
+		}
+		sig.References = append(sig.References, Reference{
+			URI:          ref.uri,
+			Transforms:   ref.transforms,
+			DigestMethod: ref.digestMethod,
+			Data:         data,
+		})
+	}
+	return sig, nil
+}
+
+func verifySignature(method signatureMethod, key crypto.PublicKey, signed, value []byte) error {
+	var digest []byte
+	if method.hash != 0 && method.kind != "hmac" {
+		h := method.hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+	switch method.kind {
+	case "rsa":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("xmldsig: RSA method with %T key", key)
+		}
+		if rsa.VerifyPKCS1v15(pub, method.hash, digest, value) != nil {
+			return ErrInvalidSignature
+		}
+	case "ecdsa":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("xmldsig: ECDSA method with %T key", key)
+		}
+		if len(value)%2 != 0 {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(value[:len(value)/2])
+		s := new(big.Int).SetBytes(value[len(value)/2:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrInvalidSignature
+		}
+	case "ed25519":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("xmldsig: Ed25519 method with %T key", key)
+		}
+		if !ed25519.Verify(pub, signed, value) {
+			return ErrInvalidSignature
+		}
+	case "hmac":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("xmldsig: HMAC method with %T key", key)
+		}
+		mac := hmac.New(method.hash.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), value) {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+// verifyReference checks the digest of ref and returns the canonical form
+// of the data it references.
+func (v *Verifier) verifyReference(doc []byte, ref *refInfo) ([]byte, error) {
+	hashFn, ok := digestMethods[ref.digestMethod]
+	if !ok {
+		return nil, fmt.Errorf("xmldsig: unsupported digest method %q", ref.digestMethod)
+	}
+	var id string
+	switch {
+	case ref.uri == "":
+	case strings.HasPrefix(ref.uri, "#") && !strings.HasPrefix(ref.uri, "#xpointer("):
+		id = ref.uri[1:]
+	default:
+		return nil, fmt.Errorf("xmldsig: unsupported reference URI %q", ref.uri)
+	}
+
+	mode := gosax.C14N10
+	enveloped := false
+	var prefixes []string
+	for i, t := range ref.transforms {
+		if t == envelopedSignature {
+			enveloped = true
+			continue
+		}
+		m, ok := gosax.ModeFromURI(t)
+		if !ok {
+			return nil, fmt.Errorf("xmldsig: unsupported transform %q", t)
+		}
+		mode = m
+		prefixes = ref.prefixes[i]
+	}
+	// Same-document references select node-sets without comments.
+	if mode == gosax.C14N10WithComments || mode == gosax.C14N11WithComments || mode == gosax.ExcC14N10WithComments {
+		mode--
+	}
+
+	h := hashFn.New()
+	var data bytes.Buffer
+	if err := v.digestNodes(io.MultiWriter(h, &data), doc, id, enveloped, mode, prefixes); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(h.Sum(nil), ref.digestValue) != 1 {
+		return nil, fmt.Errorf("%w for reference %q", ErrDigestMismatch, ref.uri)
+	}
+	return data.Bytes(), nil
+}
+
+// digestNodes canonicalizes the whole document, or the element whose ID
+// is id, into w. With enveloped, the verified Signature element is omitted.
+func (v *Verifier) digestNodes(w io.Writer, doc []byte, id string, enveloped bool, mode gosax.Mode, prefixes []string) error {
+	idAttrs := v.IDAttributes
+	if len(idAttrs) == 0 {
+		idAttrs = []string{"ID", "Id", "id"}
+	}
+
+	var c *gosax.Canonicalizer
+	if id == "" {
+		c = gosax.NewCanonicalizer(w, mode)
+		c.InclusiveNamespaces = prefixes
+	}
+	var sc scope
+	depth := 0
+	activeDepth := -1
+	skipDepth := -1
+	signatures := 0
+	found := false
+
+	r := gosax.NewReader(bytes.NewReader(doc))
+	r.EmitSelfClosingTag = true
+	for {
+		ev, err := r.Event()
+		if err != nil {
+			return err
+		}
+		if ev.Type() == gosax.EventEOF {
+			break
+		}
+		switch ev.Type() {
+		case gosax.EventStart:
+			se, err := gosax.StartElement(ev.Bytes)
+			if err != nil {
+				return err
+			}
+			if id != "" && hasID(&se, idAttrs, id) {
+				if found {
+					return fmt.Errorf("xmldsig: duplicate ID %q", id)
+				}
+				found = true
+				c = gosax.NewCanonicalizer(w, mode)
+				c.InclusiveNamespaces = prefixes
+				for _, b := range sc.visible() {
+					c.DeclareNamespace(b.prefix, b.uri)
+				}
+				activeDepth = depth
+			}
+			sc.push(&se)
+			if sc.is(se.Name, "Signature") {
+				signatures++
+				if enveloped && signatures == 1 && skipDepth < 0 {
+					skipDepth = depth
+				}
+			}
+			depth++
+		case gosax.EventEnd:
+			depth--
+			sc.pop()
+		}
+		if c != nil && skipDepth < 0 && (id == "" || activeDepth >= 0) {
+			if err := c.WriteEvent(ev); err != nil {
+				return err
+			}
+		}
+		if ev.Type() == gosax.EventEnd {
+			if depth == skipDepth {
+				skipDepth = -1
+			}
+			if depth == activeDepth {
+				activeDepth = -1
+			}
+		}
+	}
+	if id != "" && !found {
+		return fmt.Errorf("xmldsig: no element with ID %q", id)
+	}
+	return c.Flush()
+}
+
+func hasID(se *xml.StartElement, idAttrs []string, id string) bool {
+	for _, a := range se.Attr {
+		if a.Name.Space != "" || a.Value != id {
+			continue
+		}
+		for _, name := range idAttrs {
+			if a.Name.Local == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canonicalize writes the canonical form of the element in raw, which
+// appeared in a context where ctx namespace bindings were in scope.
+func canonicalize(w io.Writer, raw []byte, ctx []binding, mode gosax.Mode, prefixes []string) error {
+	c := gosax.NewCanonicalizer(w, mode)
+	c.InclusiveNamespaces = prefixes
+	for _, b := range ctx {
+		c.DeclareNamespace(b.prefix, b.uri)
+	}
+	r := gosax.NewReader(bytes.NewReader(raw))
+	for {
+		ev, err := r.Event()
+		if err != nil {
+			return err
+		}
+		if ev.Type() == gosax.EventEOF {
+			return c.Flush()
+		}
+		if err := c.WriteEvent(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// findSignature returns the raw bytes of the first Signature element in doc
+// and the namespace bindings in scope for its children.
+func findSignature(doc []byte) ([]byte, []binding, error) {
+	var sc scope
+	r := gosax.NewReader(bytes.NewReader(doc))
+	r.EmitSelfClosingTag = true
+	for {
+		ev, err := r.Event()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch ev.Type() {
+		case gosax.EventEOF:
+			return nil, nil, ErrNoSignature
+		case gosax.EventStart:
+			se, err := gosax.StartElement(ev.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			sc.push(&se)
+			if sc.is(se.Name, "Signature") {
+				ctx := sc.visible()
+				sr, err := r.SubtreeReader()
+				if err != nil {
+					return nil, nil, err
+				}
+				raw, err := io.ReadAll(sr)
+				return raw, ctx, err
+			}
+		case gosax.EventEnd:
+			sc.pop()
+		}
+	}
+}
+
+type signatureInfo struct {
+	signedInfo []byte
+	// ctx holds the namespace bindings in scope for the children of
+	// SignedInfo.
+	ctx          []binding
+	c14n         string
+	c14nPrefixes []string
+	method       string
+	refs         []refInfo
+	value        []byte
+	keyInfo      *KeyInfo
+}
+
+type refInfo struct {
+	uri          string
+	transforms   []string
+	prefixes     [][]string
+	digestMethod string
+	digestValue  []byte
+}
+
+func parseSignature(raw []byte, ctx []binding) (*signatureInfo, error) {
+	var si signatureInfo
+	var value, keyInfo []byte
+	var certs [][]byte
+	err := walk(raw, ctx, func(r *gosax.Reader, sc *scope, path []string, se *xml.StartElement, text []byte) error {
+		switch strings.Join(path, "/") {
+		case "Signature/SignedInfo":
+			if se == nil {
+				break
+			}
+			si.ctx = sc.visible()
+			sr, err := r.SubtreeReader()
+			if err != nil {
+				return err
+			}
+			if si.signedInfo, err = io.ReadAll(sr); err != nil {
+				return err
+			}
+			return errConsumed
+		case "Signature/SignatureValue":
+			value = append(value, text...)
+		case "Signature/KeyInfo":
+			if se == nil {
+				break
+			}
+			sr, err := r.SubtreeReader()
+			if err != nil {
+				return err
+			}
+			keyInfo, err = io.ReadAll(sr)
+			if err != nil {
+				return err
+			}
+			err = walk(keyInfo, sc.visible(), func(_ *gosax.Reader, _ *scope, path []string, se *xml.StartElement, text []byte) error {
+				if len(path) > 0 && path[len(path)-1] == "X509Certificate" {
+					if se != nil {
+						certs = append(certs, nil)
+					} else if len(certs) > 0 {
+						certs[len(certs)-1] = append(certs[len(certs)-1], text...)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return errConsumed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if si.signedInfo == nil {
+		return nil, errors.New("xmldsig: missing SignedInfo")
+	}
+	if si.value, err = decodeBase64(value); err != nil {
+		return nil, fmt.Errorf("xmldsig: SignatureValue: %w", err)
+	}
+	if keyInfo != nil {
+		si.keyInfo = &KeyInfo{Raw: keyInfo}
+		for _, b := range certs {
+			der, err := decodeBase64(b)
+			if err != nil {
+				return nil, fmt.Errorf("xmldsig: X509Certificate: %w", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, err
+			}
+			si.keyInfo.Certificates = append(si.keyInfo.Certificates, cert)
+		}
+	}
+	if err := parseSignedInfo(&si); err != nil {
+		return nil, err
+	}
+	return &si, nil
+}
+
+func parseSignedInfo(si *signatureInfo) error {
+	var digests [][]byte
+	err := walk(si.signedInfo, si.ctx, func(_ *gosax.Reader, _ *scope, path []string, se *xml.StartElement, text []byte) error {
+		p := strings.Join(path, "/")
+		if se == nil {
+			if p == "SignedInfo/Reference/DigestValue" {
+				digests[len(digests)-1] = append(digests[len(digests)-1], text...)
+			}
+			return nil
+		}
+		switch p {
+		case "SignedInfo/CanonicalizationMethod":
+			si.c14n = attr(se, "Algorithm")
+		case "SignedInfo/CanonicalizationMethod/ec:InclusiveNamespaces":
+			si.c14nPrefixes = strings.Fields(attr(se, "PrefixList"))
+		case "SignedInfo/SignatureMethod":
+			si.method = attr(se, "Algorithm")
+		case "SignedInfo/Reference":
+			si.refs = append(si.refs, refInfo{uri: attr(se, "URI")})
+			digests = append(digests, nil)
+		case "SignedInfo/Reference/Transforms/Transform":
+			ref := &si.refs[len(si.refs)-1]
+			ref.transforms = append(ref.transforms, attr(se, "Algorithm"))
+			ref.prefixes = append(ref.prefixes, nil)
+		case "SignedInfo/Reference/Transforms/Transform/ec:InclusiveNamespaces":
+			ref := &si.refs[len(si.refs)-1]
+			ref.prefixes[len(ref.prefixes)-1] = strings.Fields(attr(se, "PrefixList"))
+		case "SignedInfo/Reference/DigestMethod":
+			si.refs[len(si.refs)-1].digestMethod = attr(se, "Algorithm")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(si.refs) == 0 {
+		return errors.New("xmldsig: no Reference")
+	}
+	for i, b := range digests {
+		if si.refs[i].digestValue, err = decodeBase64(b); err != nil {
+			return fmt.Errorf("xmldsig: DigestValue: %w", err)
+		}
+	}
+	return nil
+}
+
+// errConsumed is returned by a walk callback that read the current element
+// with SubtreeReader.
+var errConsumed = errors.New("xmldsig: element consumed")
+
+// walk calls fn for each start element and each run of character data in
+// raw, which appeared in a context where ctx namespace bindings were in
+// scope. path holds the names leading to it: the local name of elements in
+// the XML Signature namespace, "ec:" and the local name in the exclusive
+// canonicalization namespace, and "{uri}" and the local name otherwise. se
+// is nil for text. sc holds the bindings in scope.
+func walk(raw []byte, ctx []binding, fn func(r *gosax.Reader, sc *scope, path []string, se *xml.StartElement, text []byte) error) error {
+	var path []string
+	sc := scope{bindings: append([]binding(nil), ctx...)}
+	r := gosax.NewReader(bytes.NewReader(raw))
+	r.EmitSelfClosingTag = true
+	for {
+		ev, err := r.Event()
+		if err != nil {
+			return err
+		}
+		var text []byte
+		switch ev.Type() {
+		case gosax.EventEOF:
+			return nil
+		case gosax.EventStart:
+			se, err := gosax.StartElement(ev.Bytes)
+			if err != nil {
+				return err
+			}
+			sc.push(&se)
+			path = append(path, sc.pathName(se.Name))
+			if err := fn(r, &sc, path, &se, nil); err == errConsumed {
+				path = path[:len(path)-1]
+				sc.pop()
+			} else if err != nil {
+				return err
+			}
+			continue
+		case gosax.EventEnd:
+			path = path[:len(path)-1]
+			sc.pop()
+			continue
+		case gosax.EventText:
+			if text, err = gosax.CharData(ev.Bytes); err != nil {
+				return err
+			}
+		case gosax.EventCData:
+			text = bytes.TrimSuffix(bytes.TrimPrefix(ev.Bytes, []byte("<![CDATA[")), []byte("]]>"))
+		default:
+			continue
+		}
+		if err := fn(r, &sc, path, nil, text); err != nil {
+			return err
+		}
+	}
+}
+
+func attr(se *xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func decodeBase64(b []byte) ([]byte, error) {
+	b = bytes.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, b)
+	return base64.StdEncoding.DecodeString(string(b))
+}
+
+type binding struct {
+	prefix string
+	uri    string
+}
+
+// scope tracks namespace bindings while walking a document.
+type scope struct {
+	bindings []binding
+	marks    []int
+}
+
+func (s *scope) push(se *xml.StartElement) {
+	s.marks = append(s.marks, len(s.bindings))
+	for _, a := range se.Attr {
+		switch {
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			s.bindings = append(s.bindings, binding{"", a.Value})
+		case a.Name.Space == "xmlns":
+			s.bindings = append(s.bindings, binding{a.Name.Local, a.Value})
+		}
+	}
+}
+
+func (s *scope) pop() {
+	n := len(s.marks) - 1
+	s.bindings = s.bindings[:s.marks[n]]
+	s.marks = s.marks[:n]
+}
+
+func (s *scope) lookup(prefix string) string {
+	for i := len(s.bindings) - 1; i >= 0; i-- {
+		if s.bindings[i].prefix == prefix {
+			return s.bindings[i].uri
+		}
+	}
+	return ""
+}
+
+// pathName returns the name of an element in the path given to the
+// callback of walk.
+func (s *scope) pathName(name xml.Name) string {
+	switch uri := s.lookup(name.Space); uri {
+	case Namespace:
+		return name.Local
+	case excC14NNamespace:
+		return "ec:" + name.Local
+	default:
+		return "{" + uri + "}" + name.Local
+	}
+}
+
+// is reports whether name is the XML Signature element local.
+func (s *scope) is(name xml.Name, local string) bool {
+	return name.Local == local && s.lookup(name.Space) == Namespace
+}
+
+// visible returns the bindings in scope, without shadowed ones.
+func (s *scope) visible() []binding {
+	var bs []binding
+	seen := map[string]bool{}
+	for i := len(s.bindings) - 1; i >= 0; i-- {
+		b := s.bindings[i]
+		if !seen[b.prefix] {
+			seen[b.prefix] = true
+			bs = append(bs, b)
+		}
+	}
+	return bs
+}